@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"gb-emulator/internal/apu"
+	"gb-emulator/internal/frontend"
+	"gb-emulator/internal/gb"
+	"gb-emulator/internal/gbs"
+	"gb-emulator/internal/record"
+)
+
+// runGBS handles the gbs subcommands: list, play and render
+func runGBS(args []string) error {
+	if len(args) == 0 {
+		return usageError(fmt.Errorf("usage: gb-emulator gbs list|play|render [options] <file.gbs>"))
+	}
+
+	switch args[0] {
+	case "list":
+		return runGBSList(args[1:])
+	case "play":
+		return runGBSPlay(args[1:])
+	case "render":
+		return runGBSRender(args[1:])
+	}
+
+	return usageError(fmt.Errorf("unknown gbs subcommand: %s", args[0]))
+}
+
+// loadGBS parses the file given as argument
+func loadGBS(flags *flag.FlagSet) (*gbs.File, error) {
+	if flags.NArg() != 1 {
+		return nil, badArguments(flags)
+	}
+
+	data, err := gb.ReadFileBytes(flags.Arg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	return gbs.Parse(data)
+}
+
+// runGBSList prints the file information and its tracks
+func runGBSList(args []string) error {
+	flags := flag.NewFlagSet("gbs list", flag.ExitOnError)
+	flags.Parse(args)
+
+	file, err := loadGBS(flags)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Title:     %s\n", file.Title)
+	fmt.Printf("Author:    %s\n", file.Author)
+	fmt.Printf("Copyright: %s\n", file.Copyright)
+
+	driver := "VBlank"
+	if file.UsesTimer() {
+		driver = fmt.Sprintf("timer (TMA %02X, TAC %02X)", file.TMA, file.TAC)
+	}
+	fmt.Printf("Play:      %s\n", driver)
+
+	fmt.Printf("Tracks:    %d\n", file.Songs)
+	for track := 1; track <= file.Songs; track++ {
+		marker := ""
+		if track == file.FirstSong {
+			marker = " (default)"
+		}
+		fmt.Printf("  %3d%s\n", track, marker)
+	}
+
+	return nil
+}
+
+// runGBSPlay plays a track in a window
+func runGBSPlay(args []string) error {
+	flags := flag.NewFlagSet("gbs play", flag.ExitOnError)
+	track := flags.Int("track", 0, "track to play, 0 for the file's default")
+	flags.Parse(args)
+
+	file, err := loadGBS(flags)
+	if err != nil {
+		return err
+	}
+	if *track == 0 {
+		*track = file.FirstSong
+	}
+
+	return frontend.StartGBS(gbs.NewPlayer(file), *track, frontend.DefaultOptions())
+}
+
+// runGBSRender renders a track to WAV without a window
+func runGBSRender(args []string) error {
+	flags := flag.NewFlagSet("gbs render", flag.ExitOnError)
+	track := flags.Int("track", 0, "track to record, 0 for the file's default")
+	seconds := flags.Float64("seconds", 150, "length in seconds")
+	fade := flags.Float64("fade", 8, "seconds of fade-out at the end")
+	output := flags.String("o", "out.wav", "output WAV file")
+	vgmOutput := flags.String("vgm", "", "log the track as VGM instead of WAV")
+	loop := flags.Float64("loop", -1, "with -vgm, second where the loop starts")
+	stems := flags.Bool("stems", false, "also record each channel to its own file")
+	qualityName := flags.String("quality", apu.DefaultQuality.String(), "synthesis quality: fast, medium, high, best")
+	sampleRate := flags.Int("rate", apu.DefaultSampleRate, "sample rate")
+	flags.Parse(args)
+
+	quality, err := apu.ParseQuality(*qualityName)
+	if err != nil {
+		return usageError(err)
+	}
+
+	file, err := loadGBS(flags)
+	if err != nil {
+		return err
+	}
+	if *track == 0 {
+		*track = file.FirstSong
+	}
+
+	player := gbs.NewPlayer(file)
+	if err := player.Start(*track); err != nil {
+		return err
+	}
+	player.GB.APU.SetQuality(quality)
+
+	if *vgmOutput != "" {
+		return renderGBSVGM(player, *vgmOutput, *seconds, *loop)
+	}
+
+	recorder, err := record.Start(player.GB.APU, *output, *sampleRate, *stems)
+	if err != nil {
+		return err
+	}
+	recorder.Limit = int(*seconds * float64(*sampleRate))
+	recorder.FadeOut = min(recorder.Limit, int(*fade*float64(*sampleRate)))
+
+	const cyclesPerFlush = apu.ClockFrequency / 60
+	for !recorder.Done() {
+		if err := player.Run(cyclesPerFlush); err != nil {
+			recorder.Close()
+			return err
+		}
+		if err := recorder.Flush(); err != nil {
+			recorder.Close()
+			return err
+		}
+	}
+
+	if err := recorder.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("track %d recorded to %s\n", *track, *output)
+	return nil
+}
+
+// renderGBSVGM logs the sound registers of the track to a VGM file
+func renderGBSVGM(player *gbs.Player, output string, seconds float64, loop float64) error {
+	file := player.File
+	tag := record.GD3{
+		Track:   fmt.Sprintf("%s #%d", file.Title, player.Track()),
+		Game:    file.Title,
+		System:  "Nintendo Game Boy",
+		Author:  file.Author,
+		Date:    file.Copyright,
+		Creator: "gb-emulator",
+	}
+
+	vgm := record.StartVGM(player.GB.APU, output, tag)
+	if err := logVGM(vgm, player.Run, seconds, loop); err != nil {
+		vgm.Close()
+		return err
+	}
+
+	if err := vgm.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("track %d logged to %s\n", player.Track(), output)
+	return nil
+}
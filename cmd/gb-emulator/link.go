@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"gb-emulator/internal/config"
+	"gb-emulator/internal/frontend"
+	"gb-emulator/internal/gb"
+	"gb-emulator/internal/infrared"
+	"gb-emulator/internal/link"
+	"gb-emulator/internal/printer"
+)
+
+// connectLink plugs a device into the serial port of the machine: a BGB link
+// cable waiting for a partner when host is set or connecting to one when
+// address is set, or a printer saving to printerDir. It returns nil when
+// nothing is connected
+func connectLink(machine *gb.GB, host string, address string, printerDir string) (io.Closer, error) {
+	devices := 0
+	for _, option := range []string{host, address, printerDir} {
+		if option != "" {
+			devices++
+		}
+	}
+
+	switch {
+	case devices > 1:
+		return nil, usageError(fmt.Errorf("-link-host, -link-connect and -printer can't be used together"))
+	case printerDir != "":
+		device := printer.New(printerDir)
+		device.OnSave = func(path string, err error) {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				return
+			}
+			fmt.Fprintln(os.Stderr, "print written to", path)
+		}
+		machine.Serial.Connect(device)
+		return device, nil
+	case host != "":
+		fmt.Fprintf(os.Stderr, "waiting for a connection on %s...\n", host)
+		return link.Listen(host, machine.Serial)
+	case address != "":
+		return link.Dial(address, machine.Serial)
+	}
+	return nil, nil
+}
+
+// connectInfrared puts what the mode names in front of the infrared port
+func connectInfrared(machine *gb.GB, mode string) error {
+	switch mode {
+	case "none":
+		machine.IR.Connect(nil)
+	case "loopback":
+		machine.IR.Connect(&infrared.Loopback{})
+	default:
+		return fmt.Errorf("unknown infrared mode: %q", mode)
+	}
+	return nil
+}
+
+// runLink runs two Game Boys connected by a link cable in the same window
+func runLink(args []string) error {
+	flags := flag.NewFlagSet("link", flag.ExitOnError)
+	bootRom := flags.String("boot", "", "boot ROM")
+	allowOpposite := flags.Bool("allow-opposite", false, "allow pressing left+right and up+down at the same time")
+	ir := flags.Bool("ir", false, "also point the infrared ports of the two CGBs at each other")
+	keys := playerKeyFlags(flags, 2)
+	configDir := flags.String("config", defaultConfigDir(), "directory of config.json, empty to read no files")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: gb-emulator link [options] <rom> [player 2 rom]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return badArguments(flags)
+	}
+
+	options, err := linkOptions(keys, *allowOpposite, *configDir)
+	if err != nil {
+		return err
+	}
+
+	players, err := newPlayers(*bootRom, flags.Args(), 2)
+	if err != nil {
+		return err
+	}
+
+	linked := gb.NewLinked(players[0], players[1])
+	if *ir {
+		linked.ConnectInfrared()
+	}
+
+	return frontend.StartLinked(linked, options)
+}
+
+// runFour runs up to four Game Boys connected through a DMG-07 adapter in
+// the same window
+func runFour(args []string) error {
+	flags := flag.NewFlagSet("four", flag.ExitOnError)
+	bootRom := flags.String("boot", "", "boot ROM")
+	count := flags.Int("players", link.AdapterPorts, "number of players (2-4)")
+	allowOpposite := flags.Bool("allow-opposite", false, "allow pressing left+right and up+down at the same time")
+	keys := playerKeyFlags(flags, link.AdapterPorts)
+	configDir := flags.String("config", defaultConfigDir(), "directory of config.json, empty to read no files")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: gb-emulator four [options] <rom> [player 2 to 4 roms]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || flags.NArg() > *count {
+		return badArguments(flags)
+	}
+
+	options, err := linkOptions(keys, *allowOpposite, *configDir)
+	if err != nil {
+		return err
+	}
+
+	players, err := newPlayers(*bootRom, flags.Args(), *count)
+	if err != nil {
+		return err
+	}
+
+	linked, err := gb.NewFourPlayer(players...)
+	if err != nil {
+		return err
+	}
+
+	return frontend.StartLinked(linked, options)
+}
+
+// playerKeyFlags adds the -keys flag of the first player and -keys2 to
+// -keysN for the others
+func playerKeyFlags(flags *flag.FlagSet, players int) []*string {
+	keys := []*string{flags.String("keys", "", "key bindings of player 1")}
+	for player := 2; player <= players; player++ {
+		name := fmt.Sprintf("keys%d", player)
+		keys = append(keys, flags.String(name, "", fmt.Sprintf("key bindings of player %d", player)))
+	}
+	return keys
+}
+
+// linkOptions returns the frontend options with the key bindings of every
+// player, from the command line and the config files
+func linkOptions(keys []*string, allowOpposite bool, configDir string) (frontend.Options, error) {
+	options := frontend.DefaultOptions()
+	options.AllowOppositeDirections = allowOpposite
+
+	var settings config.Config
+	if err := setKeys(&settings, keys); err != nil {
+		return options, usageError(err)
+	}
+
+	c, err := config.NewStore(configDir, settings).Load()
+	if err != nil {
+		return options, err
+	}
+	return options, options.ApplyConfig(c)
+}
+
+// newPlayers creates the machines of a local link. Players without a ROM of
+// their own use the last one given
+func newPlayers(bootRom string, roms []string, count int) ([]*gb.GB, error) {
+	players := make([]*gb.GB, count)
+	for i := range players {
+		rom := roms[min(i, len(roms)-1)]
+
+		var err error
+		if players[i], err = gb.LoadFiles(bootRom, rom, gb.ModelAuto); err != nil {
+			return nil, err
+		}
+	}
+	return players, nil
+}
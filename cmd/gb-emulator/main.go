@@ -0,0 +1,379 @@
+// Command gb-emulator runs Game Boy ROMs
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"gb-emulator/internal/apu"
+	"gb-emulator/internal/config"
+	"gb-emulator/internal/frontend"
+	"gb-emulator/internal/gb"
+	"gb-emulator/internal/link"
+	"gb-emulator/internal/record"
+)
+
+// Exit codes
+const (
+	exitOK    = 0
+	exitError = 1 // the emulation or a file failed
+	exitUsage = 2 // wrong arguments, the same code the flag package uses
+)
+
+// errUsage marks the errors caused by the arguments
+var errUsage = errors.New("wrong usage")
+
+// usageError wraps an invalid argument so it exits with exitUsage
+func usageError(err error) error {
+	return fmt.Errorf("%w: %w", errUsage, err)
+}
+
+// badArguments shows the usage of a command, the arguments don't match it
+func badArguments(flags *flag.FlagSet) error {
+	flags.Usage()
+	return errUsage
+}
+
+func main() {
+	err := run(os.Args[1:])
+
+	switch {
+	case err == nil:
+		os.Exit(exitOK)
+	case errors.Is(err, errUsage):
+		// the usage was already shown when nothing else is said
+		if err != errUsage {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(exitUsage)
+	default:
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(exitError)
+	}
+}
+
+// commands are the subcommands, the first argument selects them
+var commands = map[string]func(args []string) error{
+	"record": runRecord,
+	"vgm":    runVGM,
+	"gbs":    runGBS,
+	"link":   runLink,
+	"four":   runFour,
+	"info":   runInfo,
+}
+
+func run(args []string) error {
+	if len(args) > 0 {
+		if command, exists := commands[args[0]]; exists {
+			return command(args[1:])
+		}
+	}
+
+	return runPlay(args)
+}
+
+// printCommands lists the ways to run the emulator
+func printCommands(flags *flag.FlagSet) {
+	fmt.Fprintln(flags.Output(), "usage: gb-emulator [options] <rom>")
+	fmt.Fprintln(flags.Output(), "       gb-emulator record [options] <rom>")
+	fmt.Fprintln(flags.Output(), "       gb-emulator vgm [options] <rom>")
+	fmt.Fprintln(flags.Output(), "       gb-emulator gbs list|play|render [options] <file.gbs>")
+	fmt.Fprintln(flags.Output(), "       gb-emulator link [options] <rom> [player 2 rom]")
+	fmt.Fprintln(flags.Output(), "       gb-emulator four [options] <rom> [player 2 to 4 roms]")
+	fmt.Fprintln(flags.Output(), "       gb-emulator info <rom>")
+}
+
+// runPlay runs a ROM in a window
+func runPlay(args []string) error {
+	flags := flag.NewFlagSet("gb-emulator", flag.ExitOnError)
+	romPath := flags.String("rom", "", "ROM to run, instead of passing it as an argument")
+	bootRom := flags.String("boot", "", "boot ROM")
+	model := flags.String("model", "auto", "emulated hardware: auto, dmg, cgb or sgb")
+	scale := flags.Int("scale", 0, "window scale (512x480 by default, or twice the border with SGB)")
+	palette := flags.String("palette", "", "palette of DMG games: green, pocket, light, contrast or RGB colours (#E0F8D0,#88C070,#346856,#081820)")
+	saveDir := flags.String("save-dir", ".", "directory of the battery saves and the save state slots")
+	recordDir := flags.String("record-dir", ".", "directory where the recordings are written")
+	colourCorrection := flags.Bool("colour-correction", false, "mimic the colours of the CGB screen")
+	mute := flags.Bool("mute", false, "start muted")
+	logLevel := flags.String("log-level", "info", "message level: debug, info, warn or error")
+	keys := playerKeyFlags(flags, config.Players)
+	serialOut := flags.Bool("serial", false, "print the bytes sent through the serial port to the standard output")
+	allowOpposite := flags.Bool("allow-opposite", false, "allow pressing left+right and up+down at the same time")
+	linkHost := flags.String("link-host", "", fmt.Sprintf("wait for another emulator or BGB on the address (for example :%d)", link.DefaultPort))
+	printerDir := flags.String("printer", "", "connect a Game Boy Printer that writes the prints as PNG to the directory")
+	linkConnect := flags.String("link-connect", "", fmt.Sprintf("connect the link cable to another emulator or BGB (for example 192.168.1.10:%d)", link.DefaultPort))
+	ir := flags.String("ir", "none", "what the CGB infrared port sees: none (nothing) or loopback (its own LED)")
+	configDir := flags.String("config", defaultConfigDir(), "directory of config.json and games/XX.json, empty to read no files")
+	flags.Usage = func() {
+		printCommands(flags)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if err := setLogLevel(*logLevel); err != nil {
+		return err
+	}
+
+	rom, err := romArgument(flags, *romPath)
+	if err != nil {
+		return err
+	}
+
+	options := frontend.DefaultOptions()
+	options.AllowOppositeDirections = *allowOpposite
+
+	// the options on the command line win over the config files
+	set := setFlags(flags)
+	var settings config.Config
+	if set["model"] {
+		settings.Model = *model
+	}
+	if set["scale"] {
+		settings.Scale = *scale
+	}
+	if set["palette"] {
+		settings.Palette = *palette
+	}
+	if set["save-dir"] {
+		settings.Paths.SaveDir = *saveDir
+	}
+	if set["colour-correction"] {
+		settings.ColourCorrection = colourCorrection
+	}
+	if set["record-dir"] {
+		settings.Paths.RecordDir = *recordDir
+	}
+	if set["mute"] {
+		settings.Audio.Mute = mute
+	}
+	if err := setKeys(&settings, keys); err != nil {
+		return usageError(err)
+	}
+	options.Config = config.NewStore(*configDir, settings)
+
+	// the per-game file is chosen by the header, before the machine exists
+	data, err := gb.ReadFileBytes(rom)
+	if err != nil {
+		return err
+	}
+	header, err := gb.ParseCartridgeHeader(data)
+	if err != nil {
+		return err
+	}
+	options.Config.SetGame(header.HeaderChecksum)
+
+	if settings, err = options.Config.Load(); err != nil {
+		return err
+	}
+
+	hardware := gb.ModelAuto
+	if settings.Model != "" {
+		if hardware, err = gb.ParseModel(settings.Model); err != nil {
+			return err
+		}
+	}
+	if *bootRom == "" {
+		*bootRom = settings.BootROM(hardware.Resolve(header))
+	}
+
+	machine, err := gb.LoadFiles(*bootRom, rom, hardware)
+	if err != nil {
+		return err
+	}
+	slog.Debug("ROM loaded", "title", machine.Header.Title, "model", hardware, "cgb", machine.Header.IsCGB(), "sgb", machine.Header.IsSGB())
+
+	if err := connectInfrared(machine, *ir); err != nil {
+		return usageError(err)
+	}
+
+	// test ROMs print their results through the serial port
+	if *serialOut {
+		machine.Serial.Output = os.Stdout
+	}
+
+	cable, err := connectLink(machine, *linkHost, *linkConnect, *printerDir)
+	if err != nil {
+		return err
+	}
+	if cable != nil {
+		defer cable.Close()
+	}
+
+	return frontend.StartGameWithOptions(machine, options)
+}
+
+// setLogLevel hides the messages below the level named debug, info, warn or
+// error
+func setLogLevel(name string) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return usageError(fmt.Errorf("unknown log level: %q", name))
+	}
+
+	slog.SetLogLoggerLevel(level)
+	return nil
+}
+
+// setFlags returns the names of the options given on the command line
+func setFlags(flags *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+// setKeys adds the key bindings of the -keys options to the command line
+// settings and checks them
+func setKeys(settings *config.Config, keys []*string) error {
+	for i, spec := range keys {
+		bindings, err := config.KeysFromSpec(*spec)
+		if err != nil {
+			return err
+		}
+		settings.SetKeys(i+1, bindings)
+	}
+
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	// the key names are only known by the frontend
+	options := frontend.DefaultOptions()
+	return options.ApplyConfig(*settings)
+}
+
+// defaultConfigDir returns the directory of the config files, or "" when the
+// system has no user config directory
+func defaultConfigDir() string {
+	dir, err := config.DefaultDir()
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+// romArgument returns the ROM given with -rom or as the only argument
+func romArgument(flags *flag.FlagSet, romPath string) (string, error) {
+	switch {
+	case romPath != "" && flags.NArg() == 0:
+		return romPath, nil
+	case romPath == "" && flags.NArg() == 1:
+		return flags.Arg(0), nil
+	}
+	return "", badArguments(flags)
+}
+
+// runRecord runs the ROM without a window and records its audio to WAV
+func runRecord(args []string) error {
+	flags := flag.NewFlagSet("record", flag.ExitOnError)
+	bootRom := flags.String("boot", "", "boot ROM")
+	seconds := flags.Float64("seconds", 10, "seconds of audio to record")
+	output := flags.String("o", "out.wav", "output WAV file")
+	stems := flags.Bool("stems", false, "also record each channel to its own file")
+	qualityName := flags.String("quality", apu.DefaultQuality.String(), "synthesis quality: fast, medium, high, best")
+	sampleRate := flags.Int("rate", apu.DefaultSampleRate, "sample rate")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: gb-emulator record [options] <rom>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	quality, err := apu.ParseQuality(*qualityName)
+	if err != nil {
+		return usageError(err)
+	}
+
+	machine, err := loadMachine(flags, *bootRom, gb.ModelAuto)
+	if err != nil {
+		return err
+	}
+	machine.APU.SetQuality(quality)
+
+	recorder, err := record.Start(machine.APU, *output, *sampleRate, *stems)
+	if err != nil {
+		return err
+	}
+	recorder.Limit = int(*seconds * float64(*sampleRate))
+
+	// the length is counted in samples, so the file is exact whatever the
+	// amount of emulation run between two flushes
+	const cyclesPerFlush = apu.ClockFrequency / 60
+	for !recorder.Done() {
+		if err := machine.RunFor(cyclesPerFlush); err != nil {
+			recorder.Close()
+			return err
+		}
+		if err := recorder.Flush(); err != nil {
+			recorder.Close()
+			return err
+		}
+	}
+
+	if err := recorder.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d samples recorded to %s\n", recorder.Limit, *output)
+	return nil
+}
+
+// runVGM runs the ROM without a window and logs its sound registers to VGM
+func runVGM(args []string) error {
+	flags := flag.NewFlagSet("vgm", flag.ExitOnError)
+	bootRom := flags.String("boot", "", "boot ROM")
+	seconds := flags.Float64("seconds", 60, "seconds to log")
+	loop := flags.Float64("loop", -1, "second where the loop starts (no loop by default)")
+	output := flags.String("o", "out.vgm", "output VGM file")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: gb-emulator vgm [options] <rom>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	machine, err := loadMachine(flags, *bootRom, gb.ModelAuto)
+	if err != nil {
+		return err
+	}
+
+	vgm := record.StartVGM(machine.APU, *output, machine.VGMTag())
+	if err := logVGM(vgm, machine.RunFor, *seconds, *loop); err != nil {
+		vgm.Close()
+		return err
+	}
+
+	if err := vgm.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%.1f seconds logged to %s\n", *seconds, *output)
+	return nil
+}
+
+// logVGM runs the emulation for the given seconds, setting the loop start
+// when its time is reached. A negative loop means no loop
+func logVGM(vgm *record.VGMRecorder, run func(cycles uint64) error, seconds float64, loop float64) error {
+	total := uint64(seconds * apu.ClockFrequency)
+	loopStart := uint64(max(loop, 0) * apu.ClockFrequency)
+
+	if loop >= 0 {
+		if err := run(loopStart); err != nil {
+			return err
+		}
+		vgm.MarkLoop()
+	}
+
+	return run(total - min(total, loopStart))
+}
+
+// loadMachine creates the Game Boy and loads the ROM given as argument
+func loadMachine(flags *flag.FlagSet, bootRom string, model gb.Model) (*gb.GB, error) {
+	if flags.NArg() != 1 {
+		return nil, badArguments(flags)
+	}
+
+	return gb.LoadFiles(bootRom, flags.Arg(0), model)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"gb-emulator/internal/config"
+	"gb-emulator/internal/gb"
+)
+
+// runInfo prints the cartridge header of a ROM
+func runInfo(args []string) error {
+	flags := flag.NewFlagSet("info", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: gb-emulator info <rom>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return badArguments(flags)
+	}
+
+	data, err := gb.ReadFileBytes(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	header, err := gb.ParseCartridgeHeader(data)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Title:       %s\n", header.Title)
+	fmt.Printf("Type:        %02X\n", header.CartridgeType)
+	fmt.Printf("CGB:         %s\n", yesNo(header.IsCGB()))
+	fmt.Printf("SGB:         %s\n", yesNo(header.IsSGB()))
+	fmt.Printf("Checksum:    %02X (header), %04X (global)\n", header.HeaderChecksum, header.GlobalChecksum)
+	fmt.Printf("Size:        %d KiB\n", len(data)/1024)
+	fmt.Printf("Config:      games/%s\n", config.GameFile(header.HeaderChecksum))
+
+	return nil
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
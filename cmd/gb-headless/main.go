@@ -0,0 +1,185 @@
+// Command gb-headless runs a Game Boy ROM without a window, for continuous
+// integration and scripts. It doesn't depend on Ebiten, so it works on
+// machines without a display
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gb-emulator/internal/gb"
+	"gb-emulator/internal/headless"
+	"gb-emulator/internal/ppu"
+)
+
+// Exit codes
+const (
+	exitOK       = 0
+	exitError    = 1 // the emulation or a file failed
+	exitUsage    = 2 // wrong arguments, the same code the flag package uses
+	exitTimeout  = 3 // the condition didn't hold within the frame limit
+	exitMismatch = 4 // the last frame doesn't have the expected hash
+)
+
+var (
+	// errUsage marks the errors caused by the arguments
+	errUsage    = errors.New("wrong usage")
+	errTimeout  = errors.New("the condition wasn't met before the frame limit")
+	errMismatch = errors.New("the hash of the last frame doesn't match")
+)
+
+func main() {
+	err := run(os.Args[1:])
+
+	switch {
+	case err == nil:
+		os.Exit(exitOK)
+	case errors.Is(err, errUsage):
+		if err != errUsage {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(exitUsage)
+	case errors.Is(err, errTimeout):
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitTimeout)
+	case errors.Is(err, errMismatch):
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitMismatch)
+	default:
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(exitError)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("gb-headless", flag.ExitOnError)
+	bootRom := flags.String("boot", "", "boot ROM")
+	model := flags.String("model", "auto", "emulated hardware: auto, dmg, cgb or sgb")
+	palette := flags.String("palette", "", "palette of DMG games: green, pocket, light, contrast or RGB colours")
+	colourCorrection := flags.Bool("colour-correction", false, "mimic the colours of the CGB screen")
+	frames := flags.Int("frames", 3600, "maximum frames to run (60 per emulated second)")
+	untilPC := flags.String("until-pc", "", "stop when the PC reaches the address (for example 0x0150)")
+	untilSerial := flags.String("until-serial", "", "stop when the serial port output contains the text")
+	untilMem := flags.String("until-mem", "", "stop when the memory holds the value, ADDRESS=VALUE (for example 0xA000=0x00)")
+	pngPath := flags.String("png", "", "write the last frame as PNG")
+	border := flags.Bool("border", false, "include the Super Game Boy border in the PNG and the hash")
+	expectHash := flags.String("expect-hash", "", "expected hash of the last frame, another hash exits with code 4")
+	serialOut := flags.Bool("serial", false, "print the bytes sent through the serial port to the standard error")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: gb-headless [options] <rom>")
+		flags.PrintDefaults()
+		fmt.Fprintln(flags.Output(), "exit codes: 0 success, 1 error, 2 wrong usage, 3 condition not met, 4 different hash")
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || *frames <= 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	conditions, err := parseConditions(*untilPC, *untilSerial, *untilMem)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	hardware, err := gb.ParseModel(*model)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	machine, err := gb.LoadFiles(*bootRom, flags.Arg(0), hardware)
+	if err != nil {
+		return err
+	}
+
+	if *palette != "" {
+		colours, err := ppu.ParseDMGPalette(*palette)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errUsage, err)
+		}
+		machine.SetPalette(colours)
+	}
+
+	machine.PPU.ColourCorrection = *colourCorrection
+
+	if *serialOut {
+		machine.Serial.Output = os.Stderr
+	}
+
+	runner := headless.New(machine, *frames)
+	runner.Conditions = conditions
+
+	result, err := runner.Run()
+	if err != nil {
+		return err
+	}
+
+	hash := headless.FrameHash(machine, *border)
+	fmt.Printf("frames: %d\n", result.Frames)
+	fmt.Printf("time:   %s (%.1fx)\n", result.Elapsed.Round(time.Millisecond), result.Speed())
+	fmt.Printf("hash:   %s\n", hash)
+
+	if *pngPath != "" {
+		if err := machine.SaveScreenshot(*pngPath, *border); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case len(conditions) > 0 && !result.Met:
+		return errTimeout
+	case *expectHash != "" && !strings.EqualFold(*expectHash, hash):
+		return errMismatch
+	}
+
+	return nil
+}
+
+// parseConditions builds the stop conditions of the flags that are set
+func parseConditions(pc, serial, mem string) ([]headless.Condition, error) {
+	var conditions []headless.Condition
+
+	if pc != "" {
+		address, err := parseNumber(pc, 16)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, headless.PCEquals(uint16(address)))
+	}
+
+	if serial != "" {
+		conditions = append(conditions, headless.SerialContains(serial))
+	}
+
+	if mem != "" {
+		address, value, found := strings.Cut(mem, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid memory condition %q, expected ADDRESS=VALUE", mem)
+		}
+		parsedAddress, err := parseNumber(address, 16)
+		if err != nil {
+			return nil, err
+		}
+		parsedValue, err := parseNumber(value, 8)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, headless.MemoryEquals(uint16(parsedAddress), byte(parsedValue)))
+	}
+
+	return conditions, nil
+}
+
+// parseNumber reads a decimal or 0x prefixed hexadecimal number
+func parseNumber(text string, bits int) (uint64, error) {
+	value, err := strconv.ParseUint(strings.TrimSpace(text), 0, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", text)
+	}
+	return value, nil
+}
@@ -2,13 +2,15 @@ module gb-emulator
 
 go 1.25
 
+require github.com/hajimehoshi/ebiten/v2 v2.9.3
+
 require (
 	github.com/ebitengine/gomobile v0.0.0-20250923094054-ea854a63cce1 // indirect
 	github.com/ebitengine/hideconsole v1.0.0 // indirect
+	github.com/ebitengine/oto/v3 v3.4.0 // indirect
 	github.com/ebitengine/purego v0.9.0 // indirect
 	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20200707082815-5321531c36a2 // indirect
 	github.com/hajimehoshi/ebiten v1.12.13 // indirect
-	github.com/hajimehoshi/ebiten/v2 v2.9.3 // indirect
 	github.com/jezek/xgb v1.1.1 // indirect
 	golang.org/x/exp v0.0.0-20190731235908-ec7cb31e5a56 // indirect
 	golang.org/x/image v0.31.0 // indirect
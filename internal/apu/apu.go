@@ -0,0 +1,607 @@
+// Package apu implements the Game Boy Audio Processing Unit
+package apu
+
+import "gb-emulator/internal/memory"
+
+// Documentation
+// * https://gbdev.io/pandocs/Audio.html
+// * https://gbdev.io/pandocs/Audio_Registers.html
+// * https://gbdev.gg8.se/wiki/articles/Gameboy_sound_hardware
+
+const (
+	// ClockFrequency is the number of T-cycles per second at normal speed
+	ClockFrequency = 4194304
+
+	DefaultSampleRate = 48000
+)
+
+// Sound registers
+const (
+	NR10Address = 0xFF10
+	NR11Address = 0xFF11
+	NR12Address = 0xFF12
+	NR13Address = 0xFF13
+	NR14Address = 0xFF14
+	NR21Address = 0xFF16
+	NR22Address = 0xFF17
+	NR23Address = 0xFF18
+	NR24Address = 0xFF19
+	NR30Address = 0xFF1A
+	NR31Address = 0xFF1B
+	NR32Address = 0xFF1C
+	NR33Address = 0xFF1D
+	NR34Address = 0xFF1E
+	NR41Address = 0xFF20
+	NR42Address = 0xFF21
+	NR43Address = 0xFF22
+	NR44Address = 0xFF23
+	NR50Address = 0xFF24
+	NR51Address = 0xFF25
+	NR52Address = 0xFF26
+
+	WaveRamStartAddress = 0xFF30
+	WaveRamEndAddress   = 0xFF3F
+
+	PCM12Address = 0xFF76 // CGB: digital output of channels 1 and 2
+	PCM34Address = 0xFF77 // CGB: digital output of channels 3 and 4
+)
+
+const (
+	nrx4Trigger      byte = 1 << 7
+	nrx4LengthEnable byte = 1 << 6
+	nr52PowerOn      byte = 1 << 7
+
+	registerCount     = NR52Address - NR10Address + 1
+	frameSequencerLen = 8
+
+	// flushCycles is how often the synth delivers its samples, about 1ms
+	flushCycles = ClockFrequency / 1024
+)
+
+// readMasks are ORed to the register values on reads, write only and unused
+// bits read as 1
+var readMasks = [registerCount]byte{
+	0x80, 0x3F, 0x00, 0xFF, 0xBF, // NR10-NR14
+	0xFF, 0x3F, 0x00, 0xFF, 0xBF, // unused, NR21-NR24
+	0x7F, 0xFF, 0x9F, 0xFF, 0xBF, // NR30-NR34
+	0xFF, 0xFF, 0x00, 0x00, 0xBF, // unused, NR41-NR44
+	0x00, 0x00, 0x70, // NR50-NR52
+}
+
+// Channel identifies one of the four sound channels
+type Channel int
+
+const (
+	ChannelSquare1 Channel = iota
+	ChannelSquare2
+	ChannelWave
+	ChannelNoise
+
+	ChannelCount = 4
+)
+
+var channelNames = [ChannelCount]string{"square1", "square2", "wave", "noise"}
+
+func (c Channel) String() string {
+	return channelNames[c]
+}
+
+// allChannels is the channel mask of the full mix
+const allChannels byte = 1<<ChannelCount - 1
+
+// Sample is a stereo output frame
+type Sample struct {
+	Left  int16
+	Right int16
+}
+
+// APU holds the four sound channels, the frame sequencer and the mixer
+type APU struct {
+	mem *memory.Memory
+
+	CGB bool // CGB hardware, changes some register quirks
+
+	powered   bool
+	registers [registerCount]byte
+	frameStep int // next frame sequencer step
+
+	square1 squareChannel
+	square2 squareChannel
+	wave    waveChannel
+	noise   noiseChannel
+
+	sampleRate int
+	rateRatio  float64 // AdjustRate ratio
+	quality    Quality
+	synth      *synth
+	clock      int // T-cycles since the last synth frame
+	samples    []Sample
+	taps       []*Tap
+
+	muted byte // channel masks of the debug mute and solo, bit 0 for channel 1
+	solo  byte
+	scope *scope
+
+	cycles uint64 // T-cycles run since power on
+
+	// OnWrite is called with every write to the sound registers and wave RAM,
+	// with the APU cycle it happened at
+	OnWrite func(cycle uint64, address uint16, value byte)
+}
+
+// New creates an APU producing samples at the given rate and maps its registers
+func New(mem *memory.Memory, sampleRate int) *APU {
+	a := &APU{
+		mem:     mem,
+		square1: newSquareChannel(true),
+		square2: newSquareChannel(false),
+		wave:    newWaveChannel(),
+		noise:   newNoiseChannel(),
+		quality: DefaultQuality,
+		synth:   newSynth(DefaultQuality),
+	}
+	a.SetSampleRate(sampleRate)
+
+	mem.MapIO(NR10Address, WaveRamEndAddress, a)
+
+	return a
+}
+
+// EnableCGB selects the CGB register behaviour and maps the PCM registers
+func (a *APU) EnableCGB() {
+	a.setCGB(true)
+}
+
+// setCGB selects the DMG or CGB register behaviour, the PCM registers only
+// exist on CGB
+func (a *APU) setCGB(cgb bool) {
+	a.CGB = cgb
+	if cgb {
+		a.mem.MapIO(PCM12Address, PCM34Address, a)
+	} else {
+		a.mem.MapIO(PCM12Address, PCM34Address, nil)
+	}
+	a.updateRate()
+}
+
+// SetSampleRate changes the output sample rate
+func (a *APU) SetSampleRate(sampleRate int) {
+	a.flush()
+	a.sampleRate = sampleRate
+	a.rateRatio = 1
+	a.updateRate()
+}
+
+// AdjustRate scales the number of samples produced per emulated second by
+// ratio, letting a frontend keep its buffer level or change the pitch
+func (a *APU) AdjustRate(ratio float64) {
+	a.flush()
+	a.rateRatio = ratio
+	a.updateRate()
+}
+
+// updateRate configures the synths for the sample rate and the model, the
+// taps keep their own sample rate
+func (a *APU) updateRate() {
+	a.synth.setRate(float64(a.sampleRate)*a.rateRatio/ClockFrequency, a.CGB)
+	for _, tap := range a.taps {
+		tap.updateRate(a.CGB)
+	}
+}
+
+// SampleRate returns the output sample rate
+func (a *APU) SampleRate() int {
+	return a.sampleRate
+}
+
+// Cycles returns the number of T-cycles the APU has run, at normal speed
+func (a *APU) Cycles() uint64 {
+	return a.cycles
+}
+
+// SetQuality changes the band-limited synthesis quality of the output and of
+// every tap
+func (a *APU) SetQuality(quality Quality) {
+	a.flush()
+	a.quality = quality
+	a.synth.setQuality(quality)
+	for _, tap := range a.taps {
+		tap.synth.setQuality(quality)
+	}
+}
+
+// Quality returns the band-limited synthesis quality
+func (a *APU) Quality() Quality {
+	return a.quality
+}
+
+// SetHighPass enables or disables the high-pass filter that removes the DC
+// offset of the DACs, like the capacitor on the audio output of the hardware
+func (a *APU) SetHighPass(enabled bool) {
+	a.synth.highPass = enabled
+	for _, tap := range a.taps {
+		tap.synth.highPass = enabled
+	}
+}
+
+// Step advances the channels by the given number of T-cycles. The APU always
+// runs at normal speed, even in CGB double speed mode.
+// The channels are stepped from one output change to the next, so every
+// amplitude change reaches the synth at its exact cycle
+func (a *APU) Step(cycles int) {
+	for cycles > 0 {
+		chunk := min(cycles, a.nextChange())
+
+		if a.scope != nil {
+			outputs, _ := a.channelOutputs()
+			a.scope.advance(chunk, outputs)
+		}
+
+		a.stepChannels(chunk)
+		a.clock += chunk
+		a.cycles += uint64(chunk)
+		cycles -= chunk
+
+		a.updateOutput()
+	}
+
+	if a.clock >= flushCycles {
+		a.flush()
+	}
+}
+
+// nextChange returns the number of T-cycles until a channel may change its output
+func (a *APU) nextChange() int {
+	next := flushCycles
+	if !a.powered {
+		return next
+	}
+
+	if a.square1.enabled {
+		next = min(next, a.square1.timer)
+	}
+	if a.square2.enabled {
+		next = min(next, a.square2.timer)
+	}
+	if a.wave.enabled {
+		next = min(next, a.wave.timer)
+	}
+	if a.noise.enabled && a.noise.clockShift < 14 {
+		next = min(next, a.noise.timer)
+	}
+
+	return max(next, 1)
+}
+
+func (a *APU) stepChannels(cycles int) {
+	if !a.powered {
+		return
+	}
+
+	a.square1.step(cycles)
+	a.square2.step(cycles)
+	a.wave.step(cycles)
+	a.noise.step(cycles)
+}
+
+// ClockFrameSequencer advances the 512 Hz frame sequencer. It's driven by the
+// falling edge of DIV bit 4 (bit 5 in double speed)
+func (a *APU) ClockFrameSequencer() {
+	if !a.powered {
+		return
+	}
+
+	if a.frameStep%2 == 0 { // 256 Hz
+		a.clockLengths()
+	}
+	if a.frameStep == 2 || a.frameStep == 6 { // 128 Hz
+		a.square1.clockSweep()
+	}
+	if a.frameStep == 7 { // 64 Hz
+		a.square1.envelope.clock()
+		a.square2.envelope.clock()
+		a.noise.envelope.clock()
+	}
+
+	a.frameStep = (a.frameStep + 1) % frameSequencerLen
+	a.updateOutput()
+}
+
+func (a *APU) clockLengths() {
+	if a.square1.length.clock() {
+		a.square1.enabled = false
+	}
+	if a.square2.length.clock() {
+		a.square2.enabled = false
+	}
+	if a.wave.length.clock() {
+		a.wave.enabled = false
+	}
+	if a.noise.length.clock() {
+		a.noise.enabled = false
+	}
+}
+
+// lengthExtraClock reports whether the next frame sequencer step leaves the
+// length counters alone, which triggers the extra length clocking quirk
+func (a *APU) lengthExtraClock() bool {
+	return a.frameStep%2 == 1
+}
+
+// channelOutputs returns the digital output of the four channels and whether
+// their DACs are powered
+func (a *APU) channelOutputs() (outputs [4]byte, dacs [4]bool) {
+	outputs = [4]byte{a.square1.output(), a.square2.output(), a.wave.output(), a.noise.output()}
+	dacs = [4]bool{a.square1.dacEnabled(), a.square2.dacEnabled(), a.wave.dacEnabled(), a.noise.dacEnabled()}
+	return outputs, dacs
+}
+
+// mix converts the audible channel outputs to analog and applies NR51 panning
+// and NR50 volume. The result of each side goes from -1 to 1
+func (a *APU) mix() (left float64, right float64) {
+	return a.mixChannels(a.audibleChannels())
+}
+
+// mixChannels mixes the channels selected by the mask (bit 0 for channel 1)
+func (a *APU) mixChannels(channels byte) (left float64, right float64) {
+	if !a.powered {
+		return 0, 0
+	}
+
+	outputs, dacs := a.channelOutputs()
+	panning := a.registers[NR51Address-NR10Address]
+
+	for i := range outputs {
+		if !dacs[i] || channels&(1<<i) == 0 {
+			continue
+		}
+
+		// the DAC maps 0-15 to 1..-1
+		analog := 1 - float64(outputs[i])/7.5
+
+		if panning&(0x10<<i) != 0 {
+			left += analog
+		}
+		if panning&(0x01<<i) != 0 {
+			right += analog
+		}
+	}
+
+	volume := a.registers[NR50Address-NR10Address]
+	left *= float64(volume>>4&0x07+1) / 8 / 4
+	right *= float64(volume&0x07+1) / 8 / 4
+
+	return left, right
+}
+
+// updateOutput sends the current mixer output to the synth
+func (a *APU) updateOutput() {
+	left, right := a.mix()
+	a.synth.update(a.clock, left, right)
+
+	for _, tap := range a.taps {
+		left, right := a.mixChannels(tap.channels)
+		tap.synth.update(a.clock, left, right)
+	}
+}
+
+// flush moves the samples finished by the synth to the output buffer
+func (a *APU) flush() {
+	a.samples = a.synth.endFrame(a.clock, a.samples)
+	for _, tap := range a.taps {
+		tap.samples = tap.synth.endFrame(a.clock, tap.samples)
+	}
+	a.clock = 0
+
+	// nobody is draining the output, keep only the last second
+	if len(a.samples) > a.sampleRate {
+		a.samples = append(a.samples[:0], a.samples[len(a.samples)-a.sampleRate:]...)
+	}
+}
+
+// TakeSamples returns the samples produced since the last call
+func (a *APU) TakeSamples() []Sample {
+	a.flush()
+
+	samples := make([]Sample, len(a.samples))
+	copy(samples, a.samples)
+	a.samples = a.samples[:0]
+	return samples
+}
+
+func toInt16(value float64) int16 {
+	return int16(max(-1, min(1, value)) * 32767)
+}
+
+// ReadIO implements memory.IODevice
+func (a *APU) ReadIO(address uint16) byte {
+	switch {
+	case address == NR52Address:
+		return a.readNR52()
+	case address < NR52Address:
+		offset := address - NR10Address
+		return a.registers[offset] | readMasks[offset]
+	case address >= WaveRamStartAddress && address <= WaveRamEndAddress:
+		return a.wave.readRam(address-WaveRamStartAddress, a.CGB)
+	case address == PCM12Address:
+		return a.square2.output()<<4 | a.square1.output()
+	case address == PCM34Address:
+		return a.noise.output()<<4 | a.wave.output()
+	}
+
+	return 0xFF
+}
+
+func (a *APU) readNR52() byte {
+	status := readMasks[NR52Address-NR10Address]
+	if a.powered {
+		status |= nr52PowerOn
+	}
+
+	for i, enabled := range a.channelsEnabled() {
+		if enabled {
+			status |= 1 << i
+		}
+	}
+
+	return status
+}
+
+// WriteIO implements memory.IODevice
+func (a *APU) WriteIO(address uint16, value byte) {
+	if a.OnWrite != nil && address <= WaveRamEndAddress {
+		a.OnWrite(a.cycles, address, value)
+	}
+
+	switch {
+	case address == NR52Address:
+		a.writeNR52(value)
+		a.updateOutput()
+		return
+	case address >= WaveRamStartAddress && address <= WaveRamEndAddress:
+		a.wave.writeRam(address-WaveRamStartAddress, value, a.CGB)
+		return
+	case address > NR52Address:
+		return
+	}
+
+	if !a.powered {
+		// while powered off only the DMG length counters can be written
+		if a.CGB || !isLengthRegister(address) {
+			return
+		}
+		if address != NR31Address {
+			value &= 0x3F
+		}
+	}
+
+	a.registers[address-NR10Address] = value
+	a.writeRegister(address, value)
+	a.updateOutput()
+}
+
+func isLengthRegister(address uint16) bool {
+	return address == NR11Address || address == NR21Address || address == NR31Address || address == NR41Address
+}
+
+func (a *APU) writeRegister(address uint16, value byte) {
+	switch address {
+	case NR10Address:
+		a.square1.writeSweep(value)
+	case NR11Address:
+		a.writeSquareLength(&a.square1, value)
+	case NR12Address:
+		a.writeSquareEnvelope(&a.square1, value)
+	case NR13Address:
+		a.square1.frequency = a.square1.frequency&0x700 | uint16(value)
+	case NR14Address:
+		a.writeSquareControl(&a.square1, value)
+	case NR21Address:
+		a.writeSquareLength(&a.square2, value)
+	case NR22Address:
+		a.writeSquareEnvelope(&a.square2, value)
+	case NR23Address:
+		a.square2.frequency = a.square2.frequency&0x700 | uint16(value)
+	case NR24Address:
+		a.writeSquareControl(&a.square2, value)
+	case NR30Address:
+		a.wave.dacOn = value&0x80 != 0
+		if !a.wave.dacOn {
+			a.wave.enabled = false
+		}
+	case NR31Address:
+		a.wave.length.load(int(value))
+	case NR32Address:
+		a.wave.volumeCode = value >> 5 & 0x03
+	case NR33Address:
+		a.wave.frequency = a.wave.frequency&0x700 | uint16(value)
+	case NR34Address:
+		a.wave.frequency = a.wave.frequency&0x00FF | uint16(value&0x07)<<8
+		if a.wave.length.writeControl(value, a.lengthExtraClock()) {
+			a.wave.enabled = false
+		}
+		if value&nrx4Trigger != 0 {
+			a.wave.trigger()
+		}
+	case NR41Address:
+		a.noise.length.load(int(value & 0x3F))
+	case NR42Address:
+		a.noise.envelope.write(value)
+		if !a.noise.dacEnabled() {
+			a.noise.enabled = false
+		}
+	case NR43Address:
+		a.noise.writePolynomial(value)
+	case NR44Address:
+		if a.noise.length.writeControl(value, a.lengthExtraClock()) {
+			a.noise.enabled = false
+		}
+		if value&nrx4Trigger != 0 {
+			a.noise.trigger()
+		}
+	}
+}
+
+func (a *APU) writeSquareLength(channel *squareChannel, value byte) {
+	if a.powered {
+		channel.duty = value >> 6
+	}
+	channel.length.load(int(value & 0x3F))
+}
+
+func (a *APU) writeSquareEnvelope(channel *squareChannel, value byte) {
+	channel.envelope.write(value)
+	if !channel.dacEnabled() {
+		channel.enabled = false
+	}
+}
+
+func (a *APU) writeSquareControl(channel *squareChannel, value byte) {
+	channel.frequency = channel.frequency&0x00FF | uint16(value&0x07)<<8
+	if channel.length.writeControl(value, a.lengthExtraClock()) {
+		channel.enabled = false
+	}
+	if value&nrx4Trigger != 0 {
+		channel.trigger()
+	}
+}
+
+func (a *APU) lengthCounters() [4]*lengthCounter {
+	return [4]*lengthCounter{&a.square1.length, &a.square2.length, &a.wave.length, &a.noise.length}
+}
+
+// writeNR52 powers the APU on or off. Powering off clears every register
+func (a *APU) writeNR52(value byte) {
+	powered := value&nr52PowerOn != 0
+
+	switch {
+	case a.powered && !powered:
+		lengths := a.lengthCounters()
+		savedValues := [4]int{lengths[0].value, lengths[1].value, lengths[2].value, lengths[3].value}
+
+		for address := uint16(NR10Address); address < NR52Address; address++ {
+			a.registers[address-NR10Address] = 0
+			a.writeRegister(address, 0)
+		}
+		a.square1.enabled = false
+		a.square2.enabled = false
+		a.wave.enabled = false
+		a.noise.enabled = false
+
+		// the DMG keeps its length counters, the CGB clears them
+		for i, length := range lengths {
+			if a.CGB {
+				length.value = 0
+			} else {
+				length.value = savedValues[i]
+			}
+		}
+	case !a.powered && powered:
+		a.frameStep = 0
+		a.square1.dutyStep = 0
+		a.square2.dutyStep = 0
+		a.wave.sampleBuffer = 0
+	}
+
+	a.powered = powered
+}
@@ -0,0 +1,197 @@
+package apu
+
+import (
+	"testing"
+
+	"gb-emulator/internal/memory"
+)
+
+func newPoweredAPU() *APU {
+	a := New(&memory.Memory{}, DefaultSampleRate)
+	a.WriteIO(NR52Address, nr52PowerOn)
+	return a
+}
+
+func TestReadMasks(t *testing.T) {
+	a := newPoweredAPU()
+
+	for address := uint16(NR10Address); address < NR52Address; address++ {
+		offset := address - NR10Address
+
+		a.WriteIO(address, 0x00)
+		if got := a.ReadIO(address); got != readMasks[offset] {
+			t.Errorf("register %04X after writing 00 reads %02X, want %02X", address, got, readMasks[offset])
+		}
+	}
+
+	// the readable bits keep their value
+	a.WriteIO(NR50Address, 0x5A)
+	if got := a.ReadIO(NR50Address); got != 0x5A {
+		t.Errorf("NR50 %02X, want 5A", got)
+	}
+	a.WriteIO(NR12Address, 0xF3)
+	if got := a.ReadIO(NR12Address); got != 0xF3 {
+		t.Errorf("NR12 %02X, want F3", got)
+	}
+}
+
+func TestNR52ChannelStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		writes  [][2]uint16
+		channel Channel
+	}{
+		{"square1", [][2]uint16{{NR12Address, 0xF0}, {NR14Address, 0x80}}, ChannelSquare1},
+		{"square2", [][2]uint16{{NR22Address, 0xF0}, {NR24Address, 0x80}}, ChannelSquare2},
+		{"wave", [][2]uint16{{NR30Address, 0x80}, {NR34Address, 0x80}}, ChannelWave},
+		{"noise", [][2]uint16{{NR42Address, 0xF0}, {NR44Address, 0x80}}, ChannelNoise},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := newPoweredAPU()
+			if got := a.ReadIO(NR52Address); got != 0xF0 {
+				t.Fatalf("NR52 before the trigger %02X, want F0", got)
+			}
+
+			for _, write := range test.writes {
+				a.WriteIO(write[0], byte(write[1]))
+			}
+			want := 0xF0 | byte(1)<<test.channel
+			if got := a.ReadIO(NR52Address); got != want {
+				t.Errorf("NR52 after the trigger %02X, want %02X", got, want)
+			}
+		})
+	}
+}
+
+func TestTriggerWithDACOff(t *testing.T) {
+	a := newPoweredAPU()
+
+	// a trigger doesn't enable a channel with its DAC off
+	a.WriteIO(NR12Address, 0x00)
+	a.WriteIO(NR14Address, 0x80)
+	if got := a.ReadIO(NR52Address) & 0x0F; got != 0 {
+		t.Errorf("channels %X after triggering with the DAC off, want 0", got)
+	}
+
+	// turning the DAC off disables the playing channel
+	tests := []struct {
+		name    string
+		trigger [][2]uint16
+		dacOff  [2]uint16
+	}{
+		{"square1", [][2]uint16{{NR12Address, 0xF0}, {NR14Address, 0x80}}, [2]uint16{NR12Address, 0x07}},
+		{"square2", [][2]uint16{{NR22Address, 0x08}, {NR24Address, 0x80}}, [2]uint16{NR22Address, 0x00}},
+		{"wave", [][2]uint16{{NR30Address, 0x80}, {NR34Address, 0x80}}, [2]uint16{NR30Address, 0x00}},
+		{"noise", [][2]uint16{{NR42Address, 0xF0}, {NR44Address, 0x80}}, [2]uint16{NR42Address, 0x00}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := newPoweredAPU()
+			for _, write := range test.trigger {
+				a.WriteIO(write[0], byte(write[1]))
+			}
+			a.WriteIO(test.dacOff[0], byte(test.dacOff[1]))
+
+			if got := a.ReadIO(NR52Address) & 0x0F; got != 0 {
+				t.Errorf("channels %X after turning the DAC off, want 0", got)
+			}
+		})
+	}
+}
+
+func TestPowerOff(t *testing.T) {
+	a := newPlayingAPU(DefaultQuality)
+
+	a.WriteIO(NR52Address, 0x00)
+	if got := a.ReadIO(NR52Address); got != 0x70 {
+		t.Errorf("NR52 after the power off %02X, want 70", got)
+	}
+	for address := uint16(NR10Address); address < NR52Address; address++ {
+		offset := address - NR10Address
+		if got := a.ReadIO(address); got != readMasks[offset] {
+			t.Errorf("register %04X after the power off %02X, want %02X", address, got, readMasks[offset])
+		}
+	}
+
+	// wave RAM isn't cleared
+	if got := a.ReadIO(WaveRamStartAddress + 1); got != 0x11 {
+		t.Errorf("wave RAM after the power off %02X, want 11", got)
+	}
+}
+
+func TestWritesWhilePoweredOff(t *testing.T) {
+	tests := []struct {
+		name    string
+		cgb     bool
+		address uint16
+		value   byte
+		want    byte // stored value
+	}{
+		{"DMG NR11 keeps the length", false, NR11Address, 0xFF, 0x3F},
+		{"DMG NR21 keeps the length", false, NR21Address, 0xC5, 0x05},
+		{"DMG NR31", false, NR31Address, 0x42, 0x42},
+		{"DMG NR41", false, NR41Address, 0xFF, 0x3F},
+		{"DMG NR12", false, NR12Address, 0xF0, 0x00},
+		{"DMG NR50", false, NR50Address, 0x77, 0x00},
+		{"CGB NR11", true, NR11Address, 0xFF, 0x00},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := New(&memory.Memory{}, DefaultSampleRate)
+			if test.cgb {
+				a.EnableCGB()
+			}
+
+			a.WriteIO(test.address, test.value)
+			if got := a.registers[test.address-NR10Address]; got != test.want {
+				t.Errorf("register %04X %02X, want %02X", test.address, got, test.want)
+			}
+		})
+	}
+}
+
+func TestWaveRAM(t *testing.T) {
+	a := newPoweredAPU()
+
+	for i := range uint16(waveRamSize) {
+		a.WriteIO(WaveRamStartAddress+i, byte(0xF0-i))
+	}
+	for i := range uint16(waveRamSize) {
+		if got := a.ReadIO(WaveRamStartAddress + i); got != byte(0xF0-i) {
+			t.Errorf("wave RAM %04X %02X, want %02X", WaveRamStartAddress+i, got, byte(0xF0-i))
+		}
+	}
+
+	// while the channel plays, the CGB sees the byte being played
+	a.EnableCGB()
+	a.WriteIO(NR30Address, 0x80)
+	a.WriteIO(NR34Address, 0x80)
+	want := a.wave.ram[a.wave.position/2]
+	if got := a.ReadIO(WaveRamStartAddress + 9); got != want {
+		t.Errorf("wave RAM while playing %02X, want %02X", got, want)
+	}
+}
+
+func TestPCMRegistersOnlyOnCGB(t *testing.T) {
+	mem := &memory.Memory{}
+	a := New(mem, DefaultSampleRate)
+
+	mem.Write(PCM12Address, 0x5A)
+	if got := mem.Read(PCM12Address); got != 0x5A {
+		t.Errorf("PCM12 on DMG %02X, want 5A from plain memory", got)
+	}
+
+	a.EnableCGB()
+	a.WriteIO(NR52Address, nr52PowerOn)
+	if got := mem.Read(PCM12Address); got != 0x00 {
+		t.Errorf("PCM12 on CGB with the channels off %02X, want 00", got)
+	}
+	mem.Write(PCM12Address, 0x5A)
+	if got := mem.Read(PCM12Address); got != 0x00 {
+		t.Errorf("PCM12 is read only, read %02X after a write, want 00", got)
+	}
+}
@@ -0,0 +1,255 @@
+package apu
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Documentation
+// * http://www.slack.net/~ant/bl-synth/ (band-limited sound synthesis)
+// * https://github.com/LIJI32/SameBoy/blob/master/Core/apu.c (high-pass filter)
+
+// Quality selects the band-limited step used to turn the channel output into samples
+type Quality int
+
+const (
+	QualityFast   Quality = iota // nearest sample, cheapest but aliased
+	QualityMedium                // 8-tap band-limited step
+	QualityHigh                  // 16-tap band-limited step
+	QualityBest                  // 32-tap band-limited step
+)
+
+// DefaultQuality is the synthesis quality of a new APU
+const DefaultQuality = QualityHigh
+
+var qualityNames = map[Quality]string{
+	QualityFast:   "fast",
+	QualityMedium: "medium",
+	QualityHigh:   "high",
+	QualityBest:   "best",
+}
+
+// qualityWidths is the number of output samples each amplitude change spreads over
+var qualityWidths = map[Quality]int{
+	QualityFast:   1,
+	QualityMedium: 8,
+	QualityHigh:   16,
+	QualityBest:   32,
+}
+
+func (q Quality) String() string {
+	if name, exists := qualityNames[q]; exists {
+		return name
+	}
+	return fmt.Sprintf("Quality(%d)", int(q))
+}
+
+// ParseQuality converts a quality name ("fast", "medium", "high", "best") to a Quality
+func ParseQuality(name string) (Quality, error) {
+	for quality, qualityName := range qualityNames {
+		if strings.EqualFold(name, qualityName) {
+			return quality, nil
+		}
+	}
+	return DefaultQuality, fmt.Errorf("unknown audio quality %q", name)
+}
+
+const (
+	blipPhases = 64   // sub-sample resolution of an amplitude change
+	blipCutoff = 0.45 // low-pass cutoff, relative to the output sample rate
+)
+
+// blipKernels holds the band-limited impulse of every quality. They're built
+// once and only read afterwards, so APUs running in several goroutines share them
+var blipKernels = newBlipKernels()
+
+func newBlipKernels() map[Quality][][]float64 {
+	kernels := map[Quality][][]float64{}
+	for quality, width := range qualityWidths {
+		kernels[quality] = blipKernel(width)
+	}
+	return kernels
+}
+
+// blipKernel returns, for each sub-sample phase, the taps of a windowed sinc
+// impulse normalized so a step always ends at its full amplitude
+func blipKernel(width int) [][]float64 {
+	kernel := make([][]float64, blipPhases)
+	for phase := range kernel {
+		taps := make([]float64, width)
+
+		if width == 1 {
+			taps[0] = 1
+			kernel[phase] = taps
+			continue
+		}
+
+		fraction := float64(phase) / blipPhases
+		sum := 0.0
+		for t := range taps {
+			distance := float64(t) - fraction - float64(width/2-1)
+			taps[t] = sinc(2*blipCutoff*distance) * blackman((distance+float64(width)/2)/float64(width))
+			sum += taps[t]
+		}
+		for t := range taps {
+			taps[t] /= sum
+		}
+
+		kernel[phase] = taps
+	}
+
+	return kernel
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+func blackman(x float64) float64 {
+	if x < 0 || x > 1 {
+		return 0
+	}
+	return 0.42 - 0.5*math.Cos(2*math.Pi*x) + 0.08*math.Cos(4*math.Pi*x)
+}
+
+// blipBuffer places amplitude changes at their exact emulated cycle as
+// band-limited steps, and integrates them into output samples
+type blipBuffer struct {
+	factor     float64 // output samples per clock
+	position   float64 // output sample (relative to deltas[0]) of clock 0 of the current frame
+	deltas     []float64
+	kernel     [][]float64
+	integrator float64
+}
+
+func newBlipBuffer(quality Quality) *blipBuffer {
+	return &blipBuffer{kernel: blipKernels[quality]}
+}
+
+// addDelta adds an amplitude change at the given clock of the current frame
+func (b *blipBuffer) addDelta(clock int, delta float64) {
+	position := b.position + float64(clock)*b.factor
+	index := int(position)
+	taps := b.kernel[int((position-float64(index))*blipPhases)]
+
+	if needed := index + len(taps); needed > len(b.deltas) {
+		b.deltas = append(b.deltas, make([]float64, needed-len(b.deltas))...)
+	}
+
+	for t, tap := range taps {
+		b.deltas[index+t] += delta * tap
+	}
+}
+
+// endFrame makes the samples up to the given clock available, the next frame
+// starts at that clock
+func (b *blipBuffer) endFrame(clocks int) {
+	b.position += float64(clocks) * b.factor
+}
+
+// read integrates the available samples and appends them to out
+func (b *blipBuffer) read(out []float64) []float64 {
+	available := int(b.position)
+
+	if available > len(b.deltas) {
+		b.deltas = append(b.deltas, make([]float64, available-len(b.deltas))...)
+	}
+
+	for _, delta := range b.deltas[:available] {
+		b.integrator += delta
+		out = append(out, b.integrator)
+	}
+
+	remaining := copy(b.deltas, b.deltas[available:])
+	clear(b.deltas[remaining:])
+	b.deltas = b.deltas[:remaining]
+	b.position -= float64(available)
+
+	return out
+}
+
+// High-pass filter charge factors per T-cycle, the capacitor that removes the
+// DC offset of the DACs discharges faster on CGB
+const (
+	dmgChargeFactor = 0.999958
+	cgbChargeFactor = 0.998943
+)
+
+// synth converts mixer levels into filtered stereo samples
+type synth struct {
+	left, right         *blipBuffer
+	lastLeft, lastRight float64
+
+	highPass       bool
+	chargeFactor   float64 // per output sample
+	capacitorLeft  float64
+	capacitorRight float64
+	leftLevels     []float64
+	rightLevels    []float64
+}
+
+func newSynth(quality Quality) *synth {
+	return &synth{left: newBlipBuffer(quality), right: newBlipBuffer(quality), highPass: true}
+}
+
+// setQuality changes the band-limited step of the next amplitude changes, the
+// ones already placed keep their step
+func (s *synth) setQuality(quality Quality) {
+	s.left.kernel = blipKernels[quality]
+	s.right.kernel = blipKernels[quality]
+}
+
+// setRate configures the number of output samples per T-cycle
+func (s *synth) setRate(samplesPerClock float64, cgb bool) {
+	s.left.factor = samplesPerClock
+	s.right.factor = samplesPerClock
+
+	charge := dmgChargeFactor
+	if cgb {
+		charge = cgbChargeFactor
+	}
+	s.chargeFactor = math.Pow(charge, 1/samplesPerClock)
+}
+
+// update records the mixer output at the given clock of the current frame
+func (s *synth) update(clock int, left float64, right float64) {
+	if left != s.lastLeft {
+		s.left.addDelta(clock, left-s.lastLeft)
+		s.lastLeft = left
+	}
+	if right != s.lastRight {
+		s.right.addDelta(clock, right-s.lastRight)
+		s.lastRight = right
+	}
+}
+
+// endFrame closes the frame at the given clock and appends the finished
+// samples to out
+func (s *synth) endFrame(clocks int, out []Sample) []Sample {
+	s.left.endFrame(clocks)
+	s.right.endFrame(clocks)
+
+	s.leftLevels = s.left.read(s.leftLevels[:0])
+	s.rightLevels = s.right.read(s.rightLevels[:0])
+
+	for i := range min(len(s.leftLevels), len(s.rightLevels)) {
+		left, right := s.leftLevels[i], s.rightLevels[i]
+		if s.highPass {
+			left = s.filter(left, &s.capacitorLeft)
+			right = s.filter(right, &s.capacitorRight)
+		}
+		out = append(out, Sample{Left: toInt16(left), Right: toInt16(right)})
+	}
+
+	return out
+}
+
+func (s *synth) filter(in float64, capacitor *float64) float64 {
+	out := in - *capacitor
+	*capacitor = in - out*s.chargeFactor
+	return out
+}
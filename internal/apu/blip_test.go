@@ -0,0 +1,104 @@
+package apu
+
+import (
+	"testing"
+
+	"gb-emulator/internal/memory"
+)
+
+// newPlayingAPU returns an APU with all four channels playing
+func newPlayingAPU(quality Quality) *APU {
+	a := New(&memory.Memory{}, DefaultSampleRate)
+	a.SetQuality(quality)
+
+	writes := []struct {
+		address uint16
+		value   byte
+	}{
+		{NR52Address, 0x80},
+		{NR50Address, 0x77},
+		{NR51Address, 0xFF},
+		{NR10Address, 0x00},
+		{NR11Address, 0x80},
+		{NR12Address, 0xF0},
+		{NR13Address, 0x00},
+		{NR14Address, 0x87},
+		{NR21Address, 0x40},
+		{NR22Address, 0xF0},
+		{NR23Address, 0x80},
+		{NR24Address, 0x86},
+		{NR30Address, 0x80},
+		{NR32Address, 0x20},
+		{NR33Address, 0x00},
+		{NR34Address, 0x87},
+		{NR42Address, 0xF0},
+		{NR43Address, 0x21},
+		{NR44Address, 0x80},
+	}
+	for i := range uint16(waveRamSize) {
+		a.WriteIO(WaveRamStartAddress+i, byte(i*0x11))
+	}
+	for _, write := range writes {
+		a.WriteIO(write.address, write.value)
+	}
+
+	return a
+}
+
+// BenchmarkSynth measures the cost of one video frame of audio, including the
+// synthesis, at every quality
+func BenchmarkSynth(b *testing.B) {
+	const cyclesPerStep = 4 // one M-cycle, like the emulator loop
+	const cyclesPerFrame = 70224
+	const cyclesPerFrameSequencer = ClockFrequency / 512
+
+	for quality := QualityFast; quality <= QualityBest; quality++ {
+		b.Run(quality.String(), func(b *testing.B) {
+			a := newPlayingAPU(quality)
+			cycle := 0
+
+			for b.Loop() {
+				for range cyclesPerFrame / cyclesPerStep {
+					a.Step(cyclesPerStep)
+					if cycle += cyclesPerStep; cycle%cyclesPerFrameSequencer == 0 {
+						a.ClockFrameSequencer()
+					}
+				}
+				a.TakeSamples()
+			}
+
+			emulated := float64(b.N) * cyclesPerFrame / ClockFrequency
+			b.ReportMetric(emulated/b.Elapsed().Seconds(), "x-realtime")
+		})
+	}
+}
+
+func TestSetQualityChangesTaps(t *testing.T) {
+	a := newPlayingAPU(QualityFast)
+	tap := a.AddTap(DefaultSampleRate)
+
+	a.SetQuality(QualityBest)
+
+	for name, synth := range map[string]*synth{"output": a.synth, "tap": tap.synth} {
+		if width := len(synth.left.kernel[0]); width != qualityWidths[QualityBest] {
+			t.Errorf("%s: step of %d taps, want %d", name, width, qualityWidths[QualityBest])
+		}
+	}
+}
+
+func TestTapFollowsTheModel(t *testing.T) {
+	a := newPlayingAPU(DefaultQuality)
+	tap := a.AddTap(DefaultSampleRate)
+
+	a.EnableCGB()
+	a.AdjustRate(1.01)
+
+	want := newSynth(DefaultQuality)
+	want.setRate(float64(DefaultSampleRate)/ClockFrequency, true)
+	if tap.synth.chargeFactor != want.chargeFactor {
+		t.Errorf("tap charge factor %v, want the CGB one %v", tap.synth.chargeFactor, want.chargeFactor)
+	}
+	if tap.synth.left.factor != want.left.factor {
+		t.Errorf("tap samples per clock %v, want %v unaffected by AdjustRate", tap.synth.left.factor, want.left.factor)
+	}
+}
@@ -0,0 +1,253 @@
+package apu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Debugging helpers: channel mute and solo, register state and oscilloscope.
+// Mute and solo only change what is heard, recordings and register logs keep
+// every channel
+
+// SetMuted mutes or unmutes a channel
+func (a *APU) SetMuted(channel Channel, muted bool) {
+	a.muted = setChannelBit(a.muted, channel, muted)
+	a.updateOutput()
+}
+
+// Muted reports whether a channel is muted
+func (a *APU) Muted(channel Channel) bool {
+	return a.muted&(1<<channel) != 0
+}
+
+// SetSolo adds or removes a channel from the solo group. While any channel
+// is soloed only the soloed channels are heard
+func (a *APU) SetSolo(channel Channel, solo bool) {
+	a.solo = setChannelBit(a.solo, channel, solo)
+	a.updateOutput()
+}
+
+// Solo reports whether a channel is soloed
+func (a *APU) Solo(channel Channel) bool {
+	return a.solo&(1<<channel) != 0
+}
+
+// ClearMuteSolo makes every channel audible again
+func (a *APU) ClearMuteSolo() {
+	a.muted = 0
+	a.solo = 0
+	a.updateOutput()
+}
+
+// Audible reports whether a channel reaches the output after mute and solo
+func (a *APU) Audible(channel Channel) bool {
+	return a.audibleChannels()&(1<<channel) != 0
+}
+
+func (a *APU) audibleChannels() byte {
+	if a.solo != 0 {
+		return a.solo
+	}
+	return allChannels &^ a.muted
+}
+
+func setChannelBit(mask byte, channel Channel, set bool) byte {
+	if set {
+		return mask | 1<<channel
+	}
+	return mask &^ (1 << channel)
+}
+
+// ChannelState is a snapshot of the registers and internal state of a channel
+type ChannelState struct {
+	Channel   Channel
+	Enabled   bool // NR52 status
+	DAC       bool
+	Audible   bool // not muted by mute or solo
+	Output    byte // current digital output, 0-15
+	Frequency uint16
+	Hz        float64 // tone frequency, or LFSR clock for the noise channel
+
+	Duty byte // square channels
+
+	Volume           byte // envelope volume, or wave output level (NR32 bits 5-6)
+	EnvelopeIncrease bool
+	EnvelopePeriod   byte
+
+	Length        int // remaining length counter steps
+	LengthEnabled bool
+
+	SweepPeriod byte // channel 1
+	SweepNegate bool
+	SweepShift  byte
+
+	NoiseShift   byte // channel 4
+	NoiseNarrow  bool
+	NoiseDivisor byte
+}
+
+// ChannelStates returns the state of the four channels
+func (a *APU) ChannelStates() [ChannelCount]ChannelState {
+	outputs, dacs := a.channelOutputs()
+	enabled := a.channelsEnabled()
+
+	var states [ChannelCount]ChannelState
+	for i := range states {
+		states[i] = ChannelState{
+			Channel: Channel(i),
+			Enabled: enabled[i],
+			DAC:     dacs[i],
+			Audible: a.Audible(Channel(i)),
+			Output:  outputs[i],
+		}
+	}
+
+	for i, square := range []*squareChannel{&a.square1, &a.square2} {
+		state := &states[i]
+		state.Frequency = square.frequency
+		state.Hz = float64(ClockFrequency) / float64(square.periodCycles()*8)
+		state.Duty = square.duty
+		state.setEnvelope(&square.envelope)
+		state.setLength(&square.length)
+	}
+
+	sweep := &a.square1.sweep
+	states[ChannelSquare1].SweepPeriod = sweep.period
+	states[ChannelSquare1].SweepNegate = sweep.negate
+	states[ChannelSquare1].SweepShift = sweep.shift
+
+	wave := &states[ChannelWave]
+	wave.Frequency = a.wave.frequency
+	wave.Hz = float64(ClockFrequency) / float64(a.wave.periodCycles()*waveSamples)
+	wave.Volume = a.wave.volumeCode
+	wave.setLength(&a.wave.length)
+
+	noise := &states[ChannelNoise]
+	noise.Hz = float64(ClockFrequency) / float64(a.noise.periodCycles())
+	noise.NoiseShift = a.noise.clockShift
+	noise.NoiseNarrow = a.noise.narrow
+	noise.NoiseDivisor = a.noise.divisor
+	noise.setEnvelope(&a.noise.envelope)
+	noise.setLength(&a.noise.length)
+
+	return states
+}
+
+func (s *ChannelState) setEnvelope(e *envelope) {
+	s.Volume = e.volume
+	s.EnvelopeIncrease = e.increase
+	s.EnvelopePeriod = e.period
+}
+
+func (s *ChannelState) setLength(l *lengthCounter) {
+	s.Length = l.value
+	s.LengthEnabled = l.enabled
+}
+
+// String returns a compact two-line description of the state
+func (s ChannelState) String() string {
+	var text strings.Builder
+
+	status := "off"
+	switch {
+	case s.Enabled && !s.Audible:
+		status = "mute"
+	case s.Enabled:
+		status = "on"
+	}
+	fmt.Fprintf(&text, "%-7s %-4s out %2d", s.Channel, status, s.Output)
+
+	switch s.Channel {
+	case ChannelSquare1, ChannelSquare2:
+		fmt.Fprintf(&text, " f %4d %6.0fHz d%d\n", s.Frequency, s.Hz, s.Duty)
+	case ChannelWave:
+		fmt.Fprintf(&text, " f %4d %6.0fHz\n", s.Frequency, s.Hz)
+	case ChannelNoise:
+		width := 15
+		if s.NoiseNarrow {
+			width = 7
+		}
+		fmt.Fprintf(&text, " s%d r%d %d-bit\n", s.NoiseShift, s.NoiseDivisor, width)
+	}
+
+	if s.Channel == ChannelWave {
+		fmt.Fprintf(&text, "  level %d", s.Volume)
+	} else {
+		direction := "-"
+		if s.EnvelopeIncrease {
+			direction = "+"
+		}
+		fmt.Fprintf(&text, "  vol %2d%s%d", s.Volume, direction, s.EnvelopePeriod)
+	}
+
+	length := "off"
+	if s.LengthEnabled {
+		length = fmt.Sprint(s.Length)
+	}
+	fmt.Fprintf(&text, " len %s", length)
+
+	if s.Channel == ChannelSquare1 {
+		direction := "+"
+		if s.SweepNegate {
+			direction = "-"
+		}
+		fmt.Fprintf(&text, " sw %d%s%d", s.SweepPeriod, direction, s.SweepShift)
+	}
+
+	return text.String()
+}
+
+const (
+	// ScopeRate is the number of oscilloscope samples per second
+	ScopeRate = ClockFrequency / scopePeriod
+
+	scopePeriod = 256  // T-cycles between two oscilloscope samples
+	scopeLength = 4096 // samples kept per channel, a quarter of a second
+)
+
+// scope keeps the recent digital output of every channel
+type scope struct {
+	samples  [ChannelCount][scopeLength]byte
+	position int // next sample to write
+	timer    int
+}
+
+// advance records outputs, held for the given T-cycles
+func (s *scope) advance(cycles int, outputs [ChannelCount]byte) {
+	s.timer -= cycles
+	for s.timer <= 0 {
+		s.timer += scopePeriod
+		for channel, output := range outputs {
+			s.samples[channel][s.position] = output
+		}
+		s.position = (s.position + 1) % scopeLength
+	}
+}
+
+// EnableScope starts or stops recording the channel outputs for ScopeSamples
+func (a *APU) EnableScope(enabled bool) {
+	switch {
+	case enabled && a.scope == nil:
+		a.scope = &scope{timer: scopePeriod}
+	case !enabled:
+		a.scope = nil
+	}
+}
+
+// ScopeSamples fills out with the latest outputs (0-15) of a channel, oldest
+// first, at ScopeRate. At most a quarter of a second is kept
+func (a *APU) ScopeSamples(channel Channel, out []byte) []byte {
+	if a.scope == nil {
+		return out[:0]
+	}
+
+	count := min(len(out), scopeLength)
+	samples := &a.scope.samples[channel]
+	start := a.scope.position - count + scopeLength
+
+	for i := range count {
+		out[i] = samples[(start+i)%scopeLength]
+	}
+
+	return out[:count]
+}
@@ -0,0 +1,106 @@
+package apu
+
+// Documentation
+// * https://gbdev.io/pandocs/Audio_details.html
+// * https://gbdev.gg8.se/wiki/articles/Gameboy_sound_hardware
+
+// lengthCounter disables its channel when it reaches zero, it's clocked at 256 Hz
+type lengthCounter struct {
+	enabled bool
+	value   int
+	max     int // 64, or 256 for the wave channel
+}
+
+// load sets the counter from the length field of NRx1
+func (l *lengthCounter) load(length int) {
+	l.value = l.max - length
+}
+
+// clock decrements the counter and reports whether the channel must be disabled
+func (l *lengthCounter) clock() bool {
+	if !l.enabled || l.value == 0 {
+		return false
+	}
+
+	l.value--
+	return l.value == 0
+}
+
+// writeControl applies the length enable and trigger bits of NRx4.
+// extraClock is true when the next frame sequencer step doesn't clock the
+// length counters, in which case enabling the counter clocks it once more.
+// It reports whether the channel must be disabled
+func (l *lengthCounter) writeControl(value byte, extraClock bool) bool {
+	wasEnabled := l.enabled
+	l.enabled = value&nrx4LengthEnable != 0
+	trigger := value&nrx4Trigger != 0
+	disable := false
+
+	if !wasEnabled && l.enabled && extraClock && l.value > 0 {
+		l.value--
+		disable = l.value == 0 && !trigger
+	}
+
+	if trigger && l.value == 0 {
+		l.value = l.max
+		if l.enabled && extraClock {
+			l.value--
+		}
+	}
+
+	return disable
+}
+
+// envelope changes the volume of a channel at 64 Hz
+type envelope struct {
+	initialVolume byte
+	increase      bool
+	period        byte
+
+	volume byte
+	timer  byte
+}
+
+// write loads the NRx2 register
+func (e *envelope) write(value byte) {
+	e.initialVolume = value >> 4
+	e.increase = value&0x08 != 0
+	e.period = value & 0x07
+}
+
+// dacEnabled reports whether the upper 5 bits of NRx2 power the channel DAC
+func (e *envelope) dacEnabled() bool {
+	return e.initialVolume != 0 || e.increase
+}
+
+func (e *envelope) trigger() {
+	e.volume = e.initialVolume
+	e.timer = e.reloadValue()
+}
+
+func (e *envelope) clock() {
+	if e.period == 0 {
+		return
+	}
+
+	e.timer--
+	if e.timer > 0 {
+		return
+	}
+	e.timer = e.reloadValue()
+
+	switch {
+	case e.increase && e.volume < 15:
+		e.volume++
+	case !e.increase && e.volume > 0:
+		e.volume--
+	}
+}
+
+// reloadValue treats a period of 0 as 8, like the hardware does
+func (e *envelope) reloadValue() byte {
+	if e.period == 0 {
+		return 8
+	}
+	return e.period
+}
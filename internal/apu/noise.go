@@ -0,0 +1,74 @@
+package apu
+
+// noiseDivisors maps NR43 bits 0-2 to the base period in T-cycles
+var noiseDivisors = [8]int{8, 16, 32, 48, 64, 80, 96, 112}
+
+// noiseChannel outputs the lowest bit of a linear feedback shift register
+type noiseChannel struct {
+	enabled    bool
+	clockShift byte
+	narrow     bool // 7-bit LFSR instead of 15-bit
+	divisor    byte
+	timer      int
+	lfsr       uint16
+
+	length   lengthCounter
+	envelope envelope
+}
+
+func newNoiseChannel() noiseChannel {
+	return noiseChannel{length: lengthCounter{max: 64}, lfsr: 0x7FFF}
+}
+
+// periodCycles is the number of T-cycles between two LFSR shifts
+func (c *noiseChannel) periodCycles() int {
+	return noiseDivisors[c.divisor] << c.clockShift
+}
+
+func (c *noiseChannel) step(cycles int) {
+	// shifts 14 and 15 stop the LFSR
+	if !c.enabled || c.clockShift >= 14 {
+		return
+	}
+
+	c.timer -= cycles
+	for c.timer <= 0 {
+		c.timer += c.periodCycles()
+		c.shift()
+	}
+}
+
+func (c *noiseChannel) shift() {
+	feedback := (c.lfsr ^ c.lfsr>>1) & 0x01
+	c.lfsr = c.lfsr>>1 | feedback<<14
+
+	if c.narrow {
+		c.lfsr = c.lfsr&^(1<<6) | feedback<<6
+	}
+}
+
+// output returns the digital value (0-15) sent to the DAC
+func (c *noiseChannel) output() byte {
+	if !c.enabled || c.lfsr&0x01 != 0 {
+		return 0
+	}
+	return c.envelope.volume
+}
+
+func (c *noiseChannel) dacEnabled() bool {
+	return c.envelope.dacEnabled()
+}
+
+func (c *noiseChannel) trigger() {
+	c.enabled = c.dacEnabled()
+	c.lfsr = 0x7FFF
+	c.timer = c.periodCycles()
+	c.envelope.trigger()
+}
+
+// writePolynomial loads NR43
+func (c *noiseChannel) writePolynomial(value byte) {
+	c.clockShift = value >> 4
+	c.narrow = value&0x08 != 0
+	c.divisor = value & 0x07
+}
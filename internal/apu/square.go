@@ -0,0 +1,148 @@
+package apu
+
+// dutyPatterns are the 8-step waveforms selected by NRx1 bits 6-7
+var dutyPatterns = [4][8]byte{
+	{0, 0, 0, 0, 0, 0, 0, 1}, // 12.5%
+	{1, 0, 0, 0, 0, 0, 0, 1}, // 25%
+	{1, 0, 0, 0, 0, 1, 1, 1}, // 50%
+	{0, 1, 1, 1, 1, 1, 1, 0}, // 75%
+}
+
+// squareChannel is a pulse channel, channel 1 adds the frequency sweep
+type squareChannel struct {
+	enabled   bool
+	hasSweep  bool
+	duty      byte
+	dutyStep  byte
+	frequency uint16 // 11-bit period value from NRx3/NRx4
+	timer     int
+
+	length   lengthCounter
+	envelope envelope
+	sweep    sweep
+}
+
+// sweep periodically changes the frequency of channel 1
+type sweep struct {
+	period     byte
+	negate     bool
+	shift      byte
+	timer      byte
+	enabled    bool
+	shadow     uint16
+	negateUsed bool // a calculation in negate mode happened since the last trigger
+}
+
+func newSquareChannel(hasSweep bool) squareChannel {
+	return squareChannel{hasSweep: hasSweep, length: lengthCounter{max: 64}}
+}
+
+// periodCycles is the number of T-cycles between two duty steps
+func (c *squareChannel) periodCycles() int {
+	return (2048 - int(c.frequency)) * 4
+}
+
+func (c *squareChannel) step(cycles int) {
+	c.timer -= cycles
+	for c.timer <= 0 {
+		c.timer += c.periodCycles()
+		c.dutyStep = (c.dutyStep + 1) & 0x07
+	}
+}
+
+// output returns the digital value (0-15) sent to the DAC
+func (c *squareChannel) output() byte {
+	if !c.enabled {
+		return 0
+	}
+	return dutyPatterns[c.duty][c.dutyStep] * c.envelope.volume
+}
+
+func (c *squareChannel) dacEnabled() bool {
+	return c.envelope.dacEnabled()
+}
+
+func (c *squareChannel) trigger() {
+	c.enabled = c.dacEnabled()
+	c.timer = c.periodCycles()
+	c.envelope.trigger()
+
+	if c.hasSweep {
+		c.triggerSweep()
+	}
+}
+
+func (c *squareChannel) triggerSweep() {
+	s := &c.sweep
+	s.shadow = c.frequency
+	s.timer = s.reloadValue()
+	s.enabled = s.period != 0 || s.shift != 0
+	s.negateUsed = false
+
+	if s.shift != 0 && c.calculateSweep() > 2047 {
+		c.enabled = false
+	}
+}
+
+// clockSweep runs at 128 Hz
+func (c *squareChannel) clockSweep() {
+	s := &c.sweep
+	s.timer--
+	if s.timer > 0 {
+		return
+	}
+	s.timer = s.reloadValue()
+
+	if !s.enabled || s.period == 0 {
+		return
+	}
+
+	frequency := c.calculateSweep()
+	if frequency > 2047 {
+		c.enabled = false
+		return
+	}
+
+	if s.shift != 0 {
+		s.shadow = frequency
+		c.frequency = frequency
+
+		// the new value is checked again for overflow, but not written back
+		if c.calculateSweep() > 2047 {
+			c.enabled = false
+		}
+	}
+}
+
+func (c *squareChannel) calculateSweep() uint16 {
+	s := &c.sweep
+	delta := s.shadow >> s.shift
+
+	if s.negate {
+		s.negateUsed = true
+		return s.shadow - delta
+	}
+	return s.shadow + delta
+}
+
+// writeSweep loads NR10. Leaving negate mode after a negate calculation
+// disables the channel
+func (c *squareChannel) writeSweep(value byte) {
+	s := &c.sweep
+	wasNegate := s.negate
+
+	s.period = value >> 4 & 0x07
+	s.negate = value&0x08 != 0
+	s.shift = value & 0x07
+
+	if wasNegate && !s.negate && s.negateUsed {
+		c.enabled = false
+	}
+}
+
+func (s *sweep) reloadValue() byte {
+	if s.period == 0 {
+		return 8
+	}
+	return s.period
+}
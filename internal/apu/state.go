@@ -0,0 +1,205 @@
+package apu
+
+import "gb-emulator/internal/savestate"
+
+// RegisterWrite is a write to a sound register or to wave RAM
+type RegisterWrite struct {
+	Address uint16
+	Value   byte
+}
+
+// controlRegisters are the NRx4 registers, writing them with bit 7 set
+// triggers their channel
+var controlRegisters = [ChannelCount]uint16{NR14Address, NR24Address, NR34Address, NR44Address}
+
+// StateWrites returns the register writes that bring a freshly powered APU to
+// the current state, so a register log can start in the middle of a song.
+// Registers are restored first and the playing channels triggered last
+func (a *APU) StateWrites() []RegisterWrite {
+	if !a.powered {
+		return []RegisterWrite{{NR52Address, 0x00}}
+	}
+
+	writes := []RegisterWrite{{NR52Address, nr52PowerOn}}
+
+	for address := uint16(NR10Address); address < NR52Address; address++ {
+		value := a.registers[address-NR10Address]
+		if isControlRegister(address) {
+			value &^= nrx4Trigger
+		}
+		writes = append(writes, RegisterWrite{address, value})
+	}
+
+	for i, value := range a.wave.ram {
+		writes = append(writes, RegisterWrite{WaveRamStartAddress + uint16(i), value})
+	}
+
+	for channel, enabled := range a.channelsEnabled() {
+		if enabled {
+			address := controlRegisters[channel]
+			writes = append(writes, RegisterWrite{address, a.registers[address-NR10Address] | nrx4Trigger})
+		}
+	}
+
+	return writes
+}
+
+func isControlRegister(address uint16) bool {
+	for _, control := range controlRegisters {
+		if address == control {
+			return true
+		}
+	}
+	return false
+}
+
+// channelsEnabled returns the NR52 status bit of each channel
+func (a *APU) channelsEnabled() [ChannelCount]bool {
+	return [ChannelCount]bool{a.square1.enabled, a.square2.enabled, a.wave.enabled, a.noise.enabled}
+}
+
+// SaveState writes the registers, the frame sequencer and the internal state
+// of every channel. The synthesis and the debug settings aren't state
+func (a *APU) SaveState(e *savestate.Encoder) {
+	e.Bool(a.powered)
+	e.Bytes(a.registers[:])
+	e.Int(a.frameStep)
+	e.Uint64(a.cycles)
+
+	a.square1.saveState(e)
+	a.square2.saveState(e)
+	a.wave.saveState(e)
+	a.noise.saveState(e)
+
+	e.Bool(a.CGB)
+}
+
+// LoadState restores the state written by SaveState. The samples already
+// synthesized are kept, the sound continues from the new levels
+func (a *APU) LoadState(d *savestate.Decoder) {
+	a.flush()
+
+	a.powered = d.Bool()
+	d.Bytes(a.registers[:])
+	a.frameStep = d.IntRange(0, frameSequencerLen-1)
+	a.cycles = d.Uint64()
+
+	a.square1.loadState(d)
+	a.square2.loadState(d)
+	a.wave.loadState(d)
+	a.noise.loadState(d)
+
+	a.setCGB(d.Bool())
+
+	a.updateOutput()
+}
+
+func (c *squareChannel) saveState(e *savestate.Encoder) {
+	e.Bool(c.enabled)
+	e.Uint8(c.duty)
+	e.Uint8(c.dutyStep)
+	e.Uint16(c.frequency)
+	e.Int(c.timer)
+	c.length.saveState(e)
+	c.envelope.saveState(e)
+
+	e.Uint8(c.sweep.period)
+	e.Bool(c.sweep.negate)
+	e.Uint8(c.sweep.shift)
+	e.Uint8(c.sweep.timer)
+	e.Bool(c.sweep.enabled)
+	e.Uint16(c.sweep.shadow)
+	e.Bool(c.sweep.negateUsed)
+}
+
+func (c *squareChannel) loadState(d *savestate.Decoder) {
+	c.enabled = d.Bool()
+	c.duty = d.Index(len(dutyPatterns))
+	c.dutyStep = d.Index(len(dutyPatterns[0]))
+	c.frequency = d.Uint16()
+	c.timer = d.Int()
+	c.length.loadState(d)
+	c.envelope.loadState(d)
+
+	c.sweep.period = d.Uint8()
+	c.sweep.negate = d.Bool()
+	c.sweep.shift = d.Uint8()
+	c.sweep.timer = d.Uint8()
+	c.sweep.enabled = d.Bool()
+	c.sweep.shadow = d.Uint16()
+	c.sweep.negateUsed = d.Bool()
+}
+
+func (c *waveChannel) saveState(e *savestate.Encoder) {
+	e.Bool(c.enabled)
+	e.Bool(c.dacOn)
+	e.Uint8(c.volumeCode)
+	e.Uint16(c.frequency)
+	e.Int(c.timer)
+	e.Uint8(c.position)
+	e.Uint8(c.sampleBuffer)
+	e.Int(c.sinceFetch)
+	c.length.saveState(e)
+	e.Bytes(c.ram[:])
+}
+
+func (c *waveChannel) loadState(d *savestate.Decoder) {
+	c.enabled = d.Bool()
+	c.dacOn = d.Bool()
+	c.volumeCode = d.Index(len(waveVolumeShifts))
+	c.frequency = d.Uint16()
+	c.timer = d.Int()
+	c.position = d.Index(waveSamples)
+	c.sampleBuffer = d.Uint8()
+	c.sinceFetch = d.Int()
+	c.length.loadState(d)
+	d.Bytes(c.ram[:])
+}
+
+func (c *noiseChannel) saveState(e *savestate.Encoder) {
+	e.Bool(c.enabled)
+	e.Uint8(c.clockShift)
+	e.Bool(c.narrow)
+	e.Uint8(c.divisor)
+	e.Int(c.timer)
+	e.Uint16(c.lfsr)
+	c.length.saveState(e)
+	c.envelope.saveState(e)
+}
+
+func (c *noiseChannel) loadState(d *savestate.Decoder) {
+	c.enabled = d.Bool()
+	c.clockShift = d.Uint8()
+	c.narrow = d.Bool()
+	c.divisor = d.Index(len(noiseDivisors))
+	c.timer = d.Int()
+	c.lfsr = d.Uint16()
+	c.length.loadState(d)
+	c.envelope.loadState(d)
+}
+
+func (l *lengthCounter) saveState(e *savestate.Encoder) {
+	e.Bool(l.enabled)
+	e.Int(l.value)
+}
+
+func (l *lengthCounter) loadState(d *savestate.Decoder) {
+	l.enabled = d.Bool()
+	l.value = d.Int()
+}
+
+func (v *envelope) saveState(e *savestate.Encoder) {
+	e.Uint8(v.initialVolume)
+	e.Bool(v.increase)
+	e.Uint8(v.period)
+	e.Uint8(v.volume)
+	e.Uint8(v.timer)
+}
+
+func (v *envelope) loadState(d *savestate.Decoder) {
+	v.initialVolume = d.Uint8()
+	v.increase = d.Bool()
+	v.period = d.Uint8()
+	v.volume = d.Uint8()
+	v.timer = d.Uint8()
+}
@@ -0,0 +1,54 @@
+package apu
+
+import "slices"
+
+// Tap synthesizes part of the APU output at its own fixed sample rate. Unlike
+// the main output it's not affected by AdjustRate, so recordings stay
+// sample-accurate whatever the pacing of the frontend
+type Tap struct {
+	channels   byte // mask of the mixed channels, bit 0 for channel 1
+	sampleRate int
+	synth      *synth
+	samples    []Sample
+}
+
+// AddTap starts a new tap mixing the given channels, or every channel when
+// none is given. The tap keeps all its samples until they are taken
+func (a *APU) AddTap(sampleRate int, channels ...Channel) *Tap {
+	a.flush()
+
+	tap := &Tap{channels: allChannels, sampleRate: sampleRate, synth: newSynth(a.quality)}
+	if len(channels) > 0 {
+		tap.channels = 0
+		for _, channel := range channels {
+			tap.channels |= 1 << channel
+		}
+	}
+	tap.synth.highPass = a.synth.highPass
+	tap.updateRate(a.CGB)
+
+	a.taps = append(a.taps, tap)
+	a.updateOutput()
+
+	return tap
+}
+
+// updateRate configures the synth for the fixed sample rate of the tap and
+// the high-pass filter of the model
+func (t *Tap) updateRate(cgb bool) {
+	t.synth.setRate(float64(t.sampleRate)/ClockFrequency, cgb)
+}
+
+// RemoveTap stops a tap, the samples produced until now can still be taken
+func (a *APU) RemoveTap(tap *Tap) {
+	a.flush()
+	a.taps = slices.DeleteFunc(a.taps, func(t *Tap) bool { return t == tap })
+}
+
+// TakeSamples returns the samples produced since the last call. Samples are
+// delivered by the APU about every millisecond of emulated time
+func (t *Tap) TakeSamples() []Sample {
+	samples := t.samples
+	t.samples = nil
+	return samples
+}
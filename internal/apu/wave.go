@@ -0,0 +1,96 @@
+package apu
+
+const (
+	waveRamSize    = 16
+	waveSamples    = 32
+	waveTriggerLag = 6 // T-cycles before the first sample is read after a trigger
+)
+
+// waveVolumeShifts maps NR32 bits 5-6 to the shift applied to the samples
+var waveVolumeShifts = [4]byte{4, 0, 1, 2} // mute, 100%, 50%, 25%
+
+// waveChannel plays the 32 4-bit samples stored in wave RAM
+type waveChannel struct {
+	enabled    bool
+	dacOn      bool // NR30 bit 7
+	volumeCode byte
+	frequency  uint16
+	timer      int
+
+	position     byte // sample being played, 0-31
+	sampleBuffer byte
+	sinceFetch   int // T-cycles since wave RAM was last read by the channel
+
+	length lengthCounter
+	ram    [waveRamSize]byte
+}
+
+func newWaveChannel() waveChannel {
+	return waveChannel{length: lengthCounter{max: 256}}
+}
+
+// periodCycles is the number of T-cycles between two samples
+func (c *waveChannel) periodCycles() int {
+	return (2048 - int(c.frequency)) * 2
+}
+
+func (c *waveChannel) step(cycles int) {
+	if !c.enabled {
+		return
+	}
+
+	c.sinceFetch += cycles
+	c.timer -= cycles
+	for c.timer <= 0 {
+		c.sinceFetch = -c.timer
+		c.timer += c.periodCycles()
+		c.position = (c.position + 1) % waveSamples
+		c.sampleBuffer = c.ram[c.position/2]
+	}
+}
+
+// output returns the digital value (0-15) sent to the DAC
+func (c *waveChannel) output() byte {
+	if !c.enabled {
+		return 0
+	}
+
+	sample := c.sampleBuffer >> 4 // even positions use the upper nibble
+	if c.position%2 == 1 {
+		sample = c.sampleBuffer & 0x0F
+	}
+	return sample >> waveVolumeShifts[c.volumeCode]
+}
+
+func (c *waveChannel) dacEnabled() bool {
+	return c.dacOn
+}
+
+func (c *waveChannel) trigger() {
+	c.enabled = c.dacOn
+	c.position = 0
+	c.timer = c.periodCycles() + waveTriggerLag
+}
+
+// readRam reads wave RAM from the CPU side. While the channel plays, the CPU
+// sees the byte being played; on DMG only right when the channel reads it
+func (c *waveChannel) readRam(offset uint16, cgb bool) byte {
+	if !c.enabled {
+		return c.ram[offset]
+	}
+
+	if cgb || c.sinceFetch < 2 {
+		return c.ram[c.position/2]
+	}
+	return 0xFF
+}
+
+// writeRam writes wave RAM from the CPU side, with the same restrictions as readRam
+func (c *waveChannel) writeRam(offset uint16, value byte, cgb bool) {
+	switch {
+	case !c.enabled:
+		c.ram[offset] = value
+	case cgb || c.sinceFetch < 2:
+		c.ram[c.position/2] = value
+	}
+}
@@ -0,0 +1,348 @@
+// Package config reads the user settings: a global JSON file in the user
+// config directory and optional files for single games
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gb-emulator/internal/apu"
+	"gb-emulator/internal/gb"
+	"gb-emulator/internal/joypad"
+	"gb-emulator/internal/ppu"
+)
+
+const (
+	appDir   = "gb-emulator"
+	fileName = "config.json"
+	gamesDir = "games" // per-game files, named after the header checksum
+
+	// Players is the number of players with their own key bindings: the
+	// first one and the others of a local link, a DMG-07 or the SGB
+	Players = 4
+)
+
+// Audio holds the sound settings, nil values are not set
+type Audio struct {
+	Mute     *bool    `json:"mute,omitempty"`
+	Volume   *float64 `json:"volume,omitempty"` // 0 to 1
+	Quality  string   `json:"quality,omitempty"`
+	HighPass *bool    `json:"high_pass,omitempty"`
+}
+
+// Paths holds the directories used by the emulator
+type Paths struct {
+	SaveDir   string `json:"save_dir,omitempty"`   // battery saves and save state slots
+	RecordDir string `json:"record_dir,omitempty"` // recordings made from the window
+}
+
+// Config is one layer of settings. Empty fields are not set and keep the
+// value of the layers below
+type Config struct {
+	Model   string            `json:"model,omitempty"`
+	Scale   int               `json:"scale,omitempty"`
+	Palette string            `json:"palette,omitempty"`
+	Keys    map[string]string `json:"keys,omitempty"`  // button name to keys, e.g. "start": "Space+Enter"
+	Keys2   map[string]string `json:"keys2,omitempty"` // players 2 to 4, like Keys
+	Keys3   map[string]string `json:"keys3,omitempty"`
+	Keys4   map[string]string `json:"keys4,omitempty"`
+	Audio   Audio             `json:"audio"`
+	Paths   Paths             `json:"paths"`
+
+	// ColourCorrection mimics the colours of the CGB LCD, in CGB games and
+	// in the compatibility mode
+	ColourCorrection *bool `json:"colour_correction,omitempty"`
+
+	// BootROMs maps a model name (dmg, cgb or sgb) to its boot ROM file
+	BootROMs map[string]string `json:"boot_roms,omitempty"`
+
+	// Palettes maps a game, "TITLE", "#XX" with the header checksum or
+	// "TITLE:XXXX" with the global checksum, to the palette it is shown with
+	// instead of Palette
+	Palettes map[string]string `json:"palettes,omitempty"`
+}
+
+// Merge returns the settings of c with the ones set in over replacing them.
+// Keys, boot ROMs and per-game palettes are replaced one by one
+func (c Config) Merge(over Config) Config {
+	if over.Model != "" {
+		c.Model = over.Model
+	}
+	if over.Scale != 0 {
+		c.Scale = over.Scale
+	}
+	if over.Palette != "" {
+		c.Palette = over.Palette
+	}
+	if over.ColourCorrection != nil {
+		c.ColourCorrection = over.ColourCorrection
+	}
+	if over.Audio.Mute != nil {
+		c.Audio.Mute = over.Audio.Mute
+	}
+	if over.Audio.Volume != nil {
+		c.Audio.Volume = over.Audio.Volume
+	}
+	if over.Audio.Quality != "" {
+		c.Audio.Quality = over.Audio.Quality
+	}
+	if over.Audio.HighPass != nil {
+		c.Audio.HighPass = over.Audio.HighPass
+	}
+	if over.Paths.SaveDir != "" {
+		c.Paths.SaveDir = over.Paths.SaveDir
+	}
+	if over.Paths.RecordDir != "" {
+		c.Paths.RecordDir = over.Paths.RecordDir
+	}
+
+	for player := 1; player <= Players; player++ {
+		c.SetKeys(player, mergeMaps(c.PlayerKeys(player), over.PlayerKeys(player)))
+	}
+	c.BootROMs = mergeMaps(c.BootROMs, over.BootROMs)
+	c.Palettes = mergeMaps(c.Palettes, over.Palettes)
+	return c
+}
+
+// mergeMaps returns a copy of base with the entries of over, base is not
+// changed because it may belong to another layer
+func mergeMaps(base, over map[string]string) map[string]string {
+	if len(over) == 0 {
+		return base
+	}
+
+	merged := maps.Clone(base)
+	if merged == nil {
+		merged = make(map[string]string, len(over))
+	}
+	maps.Copy(merged, over)
+	return merged
+}
+
+// Validate checks the names and ranges of the settings. Key names are
+// checked by the frontend, which knows them
+func (c Config) Validate() error {
+	if c.Model != "" {
+		if _, err := gb.ParseModel(c.Model); err != nil {
+			return err
+		}
+	}
+	if c.Scale < 0 {
+		return fmt.Errorf("invalid scale: %d", c.Scale)
+	}
+	if c.Palette != "" {
+		if _, err := ppu.ParseDMGPalette(c.Palette); err != nil {
+			return err
+		}
+	}
+	if _, err := ppu.ParsePaletteOverrides(c.Palettes); err != nil {
+		return err
+	}
+	for player := 1; player <= Players; player++ {
+		for name := range c.PlayerKeys(player) {
+			if _, err := joypad.ParseButton(name); err != nil {
+				return err
+			}
+		}
+	}
+	if volume := c.Audio.Volume; volume != nil && (*volume < 0 || *volume > 1) {
+		return fmt.Errorf("invalid volume: %g (from 0 to 1)", *volume)
+	}
+	if c.Audio.Quality != "" {
+		if _, err := apu.ParseQuality(c.Audio.Quality); err != nil {
+			return err
+		}
+	}
+	for name := range c.BootROMs {
+		if _, err := gb.ParseModel(name); err != nil || strings.EqualFold(name, gb.ModelAuto.String()) {
+			return fmt.Errorf("invalid boot ROM model: %q", name)
+		}
+	}
+
+	return nil
+}
+
+// PlayerKeys returns the key bindings of a player, from 1 to Players
+func (c Config) PlayerKeys(player int) map[string]string {
+	return *c.playerKeys(player)
+}
+
+// SetKeys replaces the key bindings of a player, from 1 to Players
+func (c *Config) SetKeys(player int, keys map[string]string) {
+	*c.playerKeys(player) = keys
+}
+
+func (c *Config) playerKeys(player int) *map[string]string {
+	return [Players]*map[string]string{&c.Keys, &c.Keys2, &c.Keys3, &c.Keys4}[player-1]
+}
+
+// KeySpec returns the key bindings of a player in the format of the -keys
+// options
+func (c Config) KeySpec(player int) string {
+	bindings := c.PlayerKeys(player)
+	keys := make(map[joypad.Button]string, len(bindings))
+	for name, value := range bindings {
+		if button, err := joypad.ParseButton(name); err == nil {
+			keys[button] = value
+		}
+	}
+
+	var entries []string
+	for _, button := range joypad.Buttons() {
+		if value, exists := keys[button]; exists {
+			entries = append(entries, button.String()+"="+value)
+		}
+	}
+	return strings.Join(entries, ",")
+}
+
+// KeysFromSpec reads bindings in the format of the -keys option, such as
+// "a=K,start=Space+Enter", as the Keys of a config
+func KeysFromSpec(spec string) (map[string]string, error) {
+	keys := make(map[string]string)
+	if strings.TrimSpace(spec) == "" {
+		return keys, nil
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		name, value, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid key binding: %q (expected button=key)", entry)
+		}
+		keys[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return keys, nil
+}
+
+// BootROM returns the boot ROM file of the model, or "" without one
+func (c Config) BootROM(model gb.Model) string {
+	for name, path := range c.BootROMs {
+		if strings.EqualFold(name, model.String()) {
+			return path
+		}
+	}
+	return ""
+}
+
+// DefaultDir returns the directory of the config files, under the user
+// config directory ($XDG_CONFIG_HOME or ~/.config on Linux)
+func DefaultDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("configuration directory not found: %w", err)
+	}
+	return filepath.Join(base, appDir), nil
+}
+
+// Store keeps the layers of the configuration: the global file, the file of
+// the running game and the command line options, which win over both files
+type Store struct {
+	dir   string
+	game  string // per-game file name, empty without a game
+	flags Config
+}
+
+// NewStore creates a store for the files of the directory, flags holds the
+// settings given on the command line
+func NewStore(dir string, flags Config) *Store {
+	return &Store{dir: dir, flags: flags}
+}
+
+// SetGame selects the per-game file of the cartridge, named by GameFile in
+// the games directory
+func (s *Store) SetGame(headerChecksum byte) {
+	s.game = GameFile(headerChecksum)
+}
+
+// GameFile returns the name of the per-game file of a cartridge, its header
+// checksum in hexadecimal, such as 20.json
+func GameFile(headerChecksum byte) string {
+	return fmt.Sprintf("%02X.json", headerChecksum)
+}
+
+// GlobalPath returns the path of the global config file
+func (s *Store) GlobalPath() string {
+	return filepath.Join(s.dir, fileName)
+}
+
+// GamePath returns the path of the per-game file, or "" without a game
+func (s *Store) GamePath() string {
+	if s.game == "" {
+		return ""
+	}
+	return filepath.Join(s.dir, gamesDir, s.game)
+}
+
+// Load reads the files and returns the merged settings. Missing files are
+// not an error and without a directory only the command line is used. It
+// reads the files again on every call, so a running game picks up the
+// changes by calling it
+func (s *Store) Load() (Config, error) {
+	if s.dir == "" {
+		return s.flags, nil
+	}
+
+	global, err := readFile(s.GlobalPath())
+	if err != nil {
+		return Config{}, err
+	}
+
+	var game Config
+	if path := s.GamePath(); path != "" {
+		if game, err = readFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	return global.Merge(game).Merge(s.flags), nil
+}
+
+// readFile reads a layer of settings, expanding "~" in its paths
+func readFile(path string) (Config, error) {
+	var c Config
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("cannot read the configuration: %w", err)
+	}
+	defer file.Close()
+
+	// unknown fields are usually typos, better to report them
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&c); err != nil {
+		return c, fmt.Errorf("error in %s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return c, fmt.Errorf("error in %s: %w", path, err)
+	}
+
+	c.Paths.SaveDir = expandHome(c.Paths.SaveDir)
+	c.Paths.RecordDir = expandHome(c.Paths.RecordDir)
+	for name, bootRom := range c.BootROMs {
+		c.BootROMs[name] = expandHome(bootRom)
+	}
+
+	return c, nil
+}
+
+// expandHome replaces a leading "~" with the home directory of the user
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
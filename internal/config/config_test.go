@@ -0,0 +1,207 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates the config files of a test directory, names are
+// relative to it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadLayers(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		fileName: `{
+			"scale": 2,
+			"palette": "green",
+			"keys": {"a": "K", "b": "J"},
+			"audio": {"mute": true, "volume": 0.5},
+			"palettes": {"TETRIS": "pocket"}
+		}`,
+		filepath.Join(gamesDir, "20.json"): `{
+			"scale": 3,
+			"keys": {"a": "L"},
+			"audio": {"mute": false},
+			"palettes": {"#20": "light"}
+		}`,
+	})
+	s := NewStore(dir, Config{Scale: 4, Keys: map[string]string{"start": "Space"}})
+	s.SetGame(0x20)
+
+	c, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"scale from the command line", c.Scale, 4},
+		{"palette from the global file", c.Palette, "green"},
+		{"mute from the game file", *c.Audio.Mute, false},
+		{"volume from the global file", *c.Audio.Volume, 0.5},
+		{"key a from the game file", c.Keys["a"], "L"},
+		{"key b from the global file", c.Keys["b"], "J"},
+		{"key start from the command line", c.Keys["start"], "Space"},
+		{"palette of the title", c.Palettes["TETRIS"], "pocket"},
+		{"palette of the checksum", c.Palettes["#20"], "light"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s: %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestGameFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		fileName:                           `{"scale": 2}`,
+		filepath.Join(gamesDir, "0A.json"): `{"scale": 3}`,
+	})
+
+	tests := []struct {
+		name     string
+		checksum byte
+		setGame  bool
+		scale    int
+	}{
+		{"no game", 0, false, 2},
+		{"game with a file", 0x0A, true, 3},
+		{"game without a file", 0x0B, true, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewStore(dir, Config{})
+			if test.setGame {
+				s.SetGame(test.checksum)
+			}
+
+			c, err := s.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Scale != test.scale {
+				t.Errorf("scale %d, want %d", c.Scale, test.scale)
+			}
+		})
+	}
+
+	if got := GameFile(0x0A); got != "0A.json" {
+		t.Errorf("GameFile(0A) = %q, want \"0A.json\"", got)
+	}
+}
+
+func TestLoadWithoutFiles(t *testing.T) {
+	flags := Config{Scale: 5}
+
+	for name, dir := range map[string]string{"no directory": "", "empty directory": t.TempDir()} {
+		c, err := NewStore(dir, flags).Load()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if c.Scale != 5 {
+			t.Errorf("%s: scale %d, want 5", name, c.Scale)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"syntax", `{"scale": `},
+		{"unknown field", `{"scal": 2}`},
+		{"scale", `{"scale": -1}`},
+		{"model", `{"model": "gba"}`},
+		{"palette", `{"palette": "purple"}`},
+		{"per-game palette", `{"palettes": {"TETRIS:12": "green"}}`},
+		{"button", `{"keys2": {"turbo": "K"}}`},
+		{"volume", `{"audio": {"volume": 1.5}}`},
+		{"quality", `{"audio": {"quality": "perfect"}}`},
+		{"boot ROM model", `{"boot_roms": {"auto": "boot.bin"}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{fileName: test.content})
+
+			_, err := NewStore(dir, Config{}).Load()
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if !strings.Contains(err.Error(), fileName) {
+				t.Errorf("error %q doesn't name the file", err)
+			}
+		})
+	}
+}
+
+func TestMergeKeepsTheLayers(t *testing.T) {
+	base := Config{Keys: map[string]string{"a": "K"}, BootROMs: map[string]string{"dmg": "dmg.bin"}}
+	over := Config{Keys: map[string]string{"a": "L"}, BootROMs: map[string]string{"cgb": "cgb.bin"}}
+
+	merged := base.Merge(over)
+	if merged.Keys["a"] != "L" || len(merged.BootROMs) != 2 {
+		t.Errorf("merged keys %v boot ROMs %v", merged.Keys, merged.BootROMs)
+	}
+	if base.Keys["a"] != "K" || len(base.BootROMs) != 1 {
+		t.Errorf("base changed to keys %v boot ROMs %v", base.Keys, base.BootROMs)
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := writeFiles(t, map[string]string{fileName: `{"paths": {"save_dir": "~/saves", "record_dir": "/tmp/rec"}}`})
+
+	c, err := NewStore(dir, Config{}).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, "saves"); c.Paths.SaveDir != want {
+		t.Errorf("save dir %q, want %q", c.Paths.SaveDir, want)
+	}
+	if c.Paths.RecordDir != "/tmp/rec" {
+		t.Errorf("record dir %q, want /tmp/rec", c.Paths.RecordDir)
+	}
+}
+
+func TestKeySpec(t *testing.T) {
+	keys, err := KeysFromSpec("start=Space+Enter, a = K")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Config{}
+	c.SetKeys(2, keys)
+	if got, want := c.KeySpec(2), "a=K,start=Space+Enter"; got != want {
+		t.Errorf("KeySpec %q, want %q", got, want)
+	}
+	if got := c.KeySpec(1); got != "" {
+		t.Errorf("KeySpec of player 1 %q, want empty", got)
+	}
+
+	if _, err := KeysFromSpec("start"); err == nil {
+		t.Error("no error for a binding without a key")
+	}
+}
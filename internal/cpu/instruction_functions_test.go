@@ -0,0 +1,74 @@
+package cpu
+
+import (
+	"testing"
+
+	"gb-emulator/internal/timer"
+)
+
+func TestSTOPSpeedSwitch(t *testing.T) {
+	tests := []struct {
+		name            string
+		cgb             bool
+		armed           bool
+		wantDoubleSpeed bool
+		wantStopped     bool
+	}{
+		{"CGB with the switch armed", true, true, true, false},
+		{"CGB without the switch armed", true, false, false, true},
+		{"DMG ignores KEY1", false, true, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu := NewCPU()
+			cpu.CGB = test.cgb
+			cpu.SpeedSwitchArmed = test.armed
+
+			STOP(cpu)
+
+			if cpu.DoubleSpeed != test.wantDoubleSpeed {
+				t.Errorf("double speed %v, want %v", cpu.DoubleSpeed, test.wantDoubleSpeed)
+			}
+			if cpu.Stopped != test.wantStopped {
+				t.Errorf("stopped %v, want %v", cpu.Stopped, test.wantStopped)
+			}
+			if test.wantDoubleSpeed && cpu.SpeedSwitchArmed {
+				t.Error("the switch is still armed after switching")
+			}
+			if cpu.PC != 2 {
+				t.Errorf("PC %04X, want 0002", cpu.PC)
+			}
+		})
+	}
+}
+
+func TestSTOPResetsDIV(t *testing.T) {
+	tests := []struct {
+		name  string
+		cgb   bool
+		armed bool
+	}{
+		{"stop", false, false},
+		{"speed switch", true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu := NewCPU()
+			cpu.CGB = test.cgb
+			cpu.SpeedSwitchArmed = test.armed
+			div := timer.New(&cpu.Memory)
+			div.Step(1000)
+			if value := cpu.Read(timer.DIVAddress); value == 0 {
+				t.Fatal("DIV didn't count")
+			}
+
+			STOP(cpu)
+
+			if value := cpu.Read(timer.DIVAddress); value != 0 {
+				t.Errorf("DIV %02X after STOP, want 00", value)
+			}
+		})
+	}
+}
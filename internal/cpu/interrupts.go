@@ -0,0 +1,24 @@
+package cpu
+
+import "math/bits"
+
+// Documentation
+// * https://gbdev.io/pandocs/Interrupts.html
+
+const (
+	interruptVectorBase = 0x0040 // VBlank, then one vector every 8 bytes
+	interruptCycles     = 5
+)
+
+// serviceInterrupt jumps to the vector of the highest priority pending
+// interrupt (the lowest bit), like a CALL that also clears IME
+func (cpu *Cpu) serviceInterrupt(pending byte) uint8 {
+	index := bits.TrailingZeros8(pending)
+
+	cpu.ClearInterrupt(1 << index)
+	cpu.IME = false
+	cpu.pushWordStack(cpu.PC)
+	cpu.PC = interruptVectorBase + uint16(index)*8
+
+	return interruptCycles
+}
@@ -1,29 +1,35 @@
-package cpu
-
-func (cpu *Cpu) pushStack(value uint8) {
-	cpu.SP--
-
-	cpu.Write(cpu.SP, value)
-}
-
-func (cpu *Cpu) pushWordStack(value uint16) {
-
-	cpu.pushStack(uint8(value >> 8))   // High byte
-	cpu.pushStack(uint8(value & 0xFF)) // Low byte
-}
-
-func (cpu *Cpu) popStack() uint8 {
-
-	value := cpu.Read(cpu.SP)
-
-	cpu.SP++
-
-	return value
-}
-
-func (cpu *Cpu) popWordStack() uint16 {
-	low := cpu.popStack()
-	high := cpu.popStack()
-
-	return uint16(high<<8) | uint16(low)
-}
+package cpu
+
+func (cpu *Cpu) pushStack(value uint8) {
+	cpu.SP--
+
+	cpu.Write(cpu.SP, value)
+}
+
+func (cpu *Cpu) pushWordStack(value uint16) {
+
+	cpu.pushStack(uint8(value >> 8))   // High byte
+	cpu.pushStack(uint8(value & 0xFF)) // Low byte
+}
+
+func (cpu *Cpu) popStack() uint8 {
+
+	value := cpu.Read(cpu.SP)
+
+	cpu.SP++
+
+	return value
+}
+
+func (cpu *Cpu) popWordStack() uint16 {
+	low := cpu.popStack()
+	high := cpu.popStack()
+
+	return uint16(high)<<8 | uint16(low)
+}
+
+// PushWord pushes a 16-bit value on the stack, it lets a driver outside the
+// CPU (like the GBS player) set up the return address of a routine
+func (cpu *Cpu) PushWord(value uint16) {
+	cpu.pushWordStack(value)
+}
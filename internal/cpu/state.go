@@ -0,0 +1,28 @@
+package cpu
+
+import "gb-emulator/internal/savestate"
+
+// SaveState writes the registers, the flags and the interrupt state. Memory
+// has its own section
+func (c *Cpu) SaveState(e *savestate.Encoder) {
+	e.Uint16(c.PC)
+	e.Uint16(c.SP)
+	e.Bytes([]byte{c.A, c.B, c.C, c.D, c.E, c.H, c.L})
+
+	for _, flag := range []bool{c.ZFlag, c.NFlag, c.HFlag, c.CFlag, c.Stopped, c.Halted, c.IME, c.enableIME} {
+		e.Bool(flag)
+	}
+}
+
+// LoadState restores the state written by SaveState
+func (c *Cpu) LoadState(d *savestate.Decoder) {
+	c.PC = d.Uint16()
+	c.SP = d.Uint16()
+	for _, register := range []*byte{&c.A, &c.B, &c.C, &c.D, &c.E, &c.H, &c.L} {
+		*register = d.Uint8()
+	}
+
+	for _, flag := range []*bool{&c.ZFlag, &c.NFlag, &c.HFlag, &c.CFlag, &c.Stopped, &c.Halted, &c.IME, &c.enableIME} {
+		*flag = d.Bool()
+	}
+}
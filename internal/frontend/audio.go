@@ -0,0 +1,210 @@
+package frontend
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gb-emulator/internal/apu"
+
+	"github.com/hajimehoshi/ebiten/v2/audio"
+)
+
+const (
+	bytesPerSample = 4 // 16-bit stereo
+
+	// the buffer is kept around 50 ms, enough to absorb frame jitter
+	audioTargetFill     = apu.DefaultSampleRate / 20
+	audioBufferCapacity = apu.DefaultSampleRate / 4
+	audioPlayerBuffer   = 30 * time.Millisecond
+
+	// samples of the fade to silence after a clear, 5 ms
+	audioFadeSamples = apu.DefaultSampleRate / 200
+
+	// maximum change of the APU rate, small enough not to be heard as pitch
+	maxRateAdjustment = 0.005
+
+	// VolumeStep is the volume change of a volume hotkey
+	VolumeStep = 0.1
+)
+
+// FastForwardAudio selects what happens to the sound while fast-forwarding
+type FastForwardAudio int
+
+const (
+	FastForwardMute  FastForwardAudio = iota // silence until the normal speed is back
+	FastForwardPitch                         // play every frame, pitched up by the speed
+)
+
+// audioBuffer is a ring buffer between the emulation, which writes from
+// Update, and the Ebiten player, which reads from its own goroutine
+type audioBuffer struct {
+	mutex   sync.Mutex
+	samples []apu.Sample
+	start   int
+	size    int
+	last    apu.Sample // repeated on underrun to avoid clicks
+	fade    int        // samples left of the fade of last to silence
+}
+
+func newAudioBuffer(capacity int) *audioBuffer {
+	return &audioBuffer{samples: make([]apu.Sample, capacity)}
+}
+
+// push appends samples, dropping the oldest ones when the buffer is full
+func (b *audioBuffer) push(samples []apu.Sample) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, sample := range samples {
+		if b.size == len(b.samples) {
+			b.start = (b.start + 1) % len(b.samples)
+			b.size--
+		}
+		b.samples[(b.start+b.size)%len(b.samples)] = sample
+		b.size++
+	}
+}
+
+// fill returns the number of samples waiting to be played
+func (b *audioBuffer) fill() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.size
+}
+
+// clear drops the samples waiting to be played and fades to silence
+func (b *audioBuffer) clear() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.start, b.size = 0, 0
+	b.fade = audioFadeSamples
+}
+
+// Read implements io.Reader with 16-bit little endian stereo samples. It
+// never blocks: missing samples are replaced by the last one played, which
+// ramps down to silence after a clear
+func (b *audioBuffer) Read(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	n := len(p) / bytesPerSample * bytesPerSample
+	for i := 0; i < n; i += bytesPerSample {
+		if b.size > 0 {
+			b.last = b.samples[b.start]
+			b.start = (b.start + 1) % len(b.samples)
+			b.size--
+			b.fade = 0
+		} else if b.fade > 0 {
+			b.last.Left -= b.last.Left / int16(b.fade)
+			b.last.Right -= b.last.Right / int16(b.fade)
+			b.fade--
+		}
+
+		p[i] = byte(b.last.Left)
+		p[i+1] = byte(uint16(b.last.Left) >> 8)
+		p[i+2] = byte(b.last.Right)
+		p[i+3] = byte(uint16(b.last.Right) >> 8)
+	}
+
+	return n, nil
+}
+
+// AudioOutput plays the APU samples through Ebiten
+type AudioOutput struct {
+	apu         *apu.APU
+	player      *audio.Player
+	buffer      *audioBuffer
+	volume      float64
+	muted       bool
+	fastForward FastForwardAudio
+}
+
+// NewAudioOutput starts playing the samples of the APU with the audio
+// settings of options. Ebiten has a single audio context, one created before
+// must run at the sample rate of the APU
+func NewAudioOutput(a *apu.APU, options Options) (*AudioOutput, error) {
+	context := audio.CurrentContext()
+	if context == nil {
+		context = audio.NewContext(a.SampleRate())
+	} else if context.SampleRate() != a.SampleRate() {
+		return nil, fmt.Errorf("the audio output runs at %d Hz, the APU at %d Hz", context.SampleRate(), a.SampleRate())
+	}
+
+	output := &AudioOutput{
+		apu:    a,
+		buffer: newAudioBuffer(audioBufferCapacity),
+	}
+
+	player, err := context.NewPlayer(output.buffer)
+	if err != nil {
+		return nil, err
+	}
+	player.SetBufferSize(audioPlayerBuffer)
+	output.player = player
+	output.Configure(options)
+	player.Play()
+
+	return output, nil
+}
+
+// Configure applies the audio settings of options, also while playing
+func (o *AudioOutput) Configure(options Options) {
+	o.apu.SetQuality(options.AudioQuality)
+	o.apu.SetHighPass(!options.NoHighPass)
+	o.volume = options.Volume
+	o.muted = options.Mute
+	o.fastForward = options.FastForwardAudio
+	o.applyVolume()
+}
+
+// Update moves the samples of the frames just emulated to the player and
+// adjusts the APU rate so the buffer stays near its target fill, which keeps
+// the audio from crackling or drifting when the host refresh rate isn't 59.73 Hz
+func (o *AudioOutput) Update(speed int) {
+	samples := o.apu.TakeSamples()
+
+	fastForward := speed > 1
+	if fastForward && o.fastForward == FastForwardMute {
+		o.buffer.clear()
+	} else {
+		o.buffer.push(samples)
+	}
+
+	deviation := float64(o.buffer.fill()-audioTargetFill) / audioTargetFill
+	ratio := 1 - maxRateAdjustment*max(-1, min(1, deviation))
+
+	// the frames of a fast-forward update share the time of a single one
+	if fastForward && o.fastForward == FastForwardPitch {
+		ratio /= float64(speed)
+	}
+
+	o.apu.AdjustRate(ratio)
+}
+
+// ToggleMute switches the sound on and off
+func (o *AudioOutput) ToggleMute() {
+	o.muted = !o.muted
+	o.applyVolume()
+}
+
+// ChangeVolume adds delta to the volume, which goes from 0 to 1
+func (o *AudioOutput) ChangeVolume(delta float64) {
+	o.volume = max(0, min(1, o.volume+delta))
+	o.applyVolume()
+}
+
+func (o *AudioOutput) applyVolume() {
+	if o.muted {
+		o.player.SetVolume(0)
+		return
+	}
+	o.player.SetVolume(o.volume)
+}
+
+// Close stops the playback
+func (o *AudioOutput) Close() error {
+	return o.player.Close()
+}
@@ -0,0 +1,112 @@
+package frontend
+
+import (
+	"log/slog"
+
+	"gb-emulator/internal/apu"
+	"gb-emulator/internal/config"
+	"gb-emulator/internal/ppu"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// ApplyConfig changes the options with the settings of a configuration, the
+// settings that are not set keep the value of the options
+func (o *Options) ApplyConfig(c config.Config) error {
+	players := [config.Players]*KeyBindings{&o.KeyBindings, &o.Player2KeyBindings, &o.Player3KeyBindings, &o.Player4KeyBindings}
+	for i, bindings := range players {
+		parsed, err := ParseKeyBindings(c.KeySpec(i+1), *bindings)
+		if err != nil {
+			return err
+		}
+		*bindings = parsed
+	}
+
+	if c.Scale > 0 {
+		o.Scale = c.Scale
+	}
+	if c.Audio.Mute != nil {
+		o.Mute = *c.Audio.Mute
+	}
+	if c.Audio.Volume != nil {
+		o.Volume = *c.Audio.Volume
+	}
+	if c.Audio.Quality != "" {
+		quality, err := apu.ParseQuality(c.Audio.Quality)
+		if err != nil {
+			return err
+		}
+		o.AudioQuality = quality
+	}
+	if c.Audio.HighPass != nil {
+		o.NoHighPass = !*c.Audio.HighPass
+	}
+	if c.Paths.SaveDir != "" {
+		o.SaveDir = c.Paths.SaveDir
+	}
+	if c.Paths.RecordDir != "" {
+		o.RecordDir = c.Paths.RecordDir
+	}
+
+	return nil
+}
+
+// loadConfig reads the configuration of options.Config and applies it over
+// the options given to NewGame, so the settings removed from the files go
+// back to those options
+func (g *Game) loadConfig() error {
+	if g.base.Config == nil {
+		return nil
+	}
+
+	c, err := g.base.Config.Load()
+	if err != nil {
+		return err
+	}
+
+	options := g.base
+	if err := options.ApplyConfig(c); err != nil {
+		return err
+	}
+
+	overrides, err := ppu.ParsePaletteOverrides(c.Palettes)
+	if err != nil {
+		return err
+	}
+	g.gb.SetPaletteOverrides(overrides)
+
+	// without a palette in the files the one given to NewGame comes back
+	palette := g.palette
+	if c.Palette != "" {
+		if palette, err = ppu.ParseDMGPalette(c.Palette); err != nil {
+			return err
+		}
+	}
+	g.gb.SetPalette(palette)
+
+	g.gb.PPU.ColourCorrection = c.ColourCorrection != nil && *c.ColourCorrection
+
+	g.options = options
+	if g.audio != nil {
+		g.audio.Configure(options)
+	}
+	return nil
+}
+
+// reloadConfig reads the config files again while the game runs. The model
+// and the boot ROM only change when the game is started again
+func (g *Game) reloadConfig() {
+	if g.base.Config == nil {
+		return
+	}
+
+	if err := g.loadConfig(); err != nil {
+		slog.Error("cannot reload the configuration", "error", err)
+		return
+	}
+
+	if g.panel == nil && g.slots == nil {
+		ebiten.SetWindowSize(g.windowSize())
+	}
+	slog.Info("configuration reloaded")
+}
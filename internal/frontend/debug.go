@@ -0,0 +1,116 @@
+package frontend
+
+import (
+	"image/color"
+
+	"gb-emulator/internal/apu"
+	"gb-emulator/internal/ppu"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// The audio debug panel is shown at the right of the game, scaled by 2. Every
+// channel gets an oscilloscope and two lines with its register state
+const (
+	panelScale  = 2
+	panelWidth  = 240
+	panelHeight = ppu.ScreenHeight * panelScale
+	panelX      = ppu.ScreenWidth * panelScale
+
+	channelBlockHeight = panelHeight / apu.ChannelCount
+	scopeHeight        = 36
+	scopeSamples       = panelWidth * 2 // two samples per pixel, about 29 ms
+	scopeTriggerRange  = 512            // samples searched for a rising edge
+)
+
+var (
+	panelBackground = color.RGBA{0x10, 0x10, 0x18, 0xFF}
+	scopeBackground = color.RGBA{0x20, 0x20, 0x30, 0xFF}
+	scopeColours    = [apu.ChannelCount]color.RGBA{
+		{0x60, 0xD0, 0x60, 0xFF},
+		{0x60, 0xA0, 0xF0, 0xFF},
+		{0xF0, 0xC0, 0x40, 0xFF},
+		{0xE0, 0x60, 0x60, 0xFF},
+	}
+	scopeMutedColour = color.RGBA{0x60, 0x60, 0x60, 0xFF}
+)
+
+// audioPanel draws the oscilloscopes and the channel register dump
+type audioPanel struct {
+	apu     *apu.APU
+	frame   *ebiten.Image // the game screen, drawn scaled next to the panel
+	samples []byte
+}
+
+func newAudioPanel(a *apu.APU) *audioPanel {
+	a.EnableScope(true)
+
+	return &audioPanel{
+		apu:     a,
+		frame:   ebiten.NewImage(ppu.ScreenWidth, ppu.ScreenHeight),
+		samples: make([]byte, scopeSamples+scopeTriggerRange),
+	}
+}
+
+func (p *audioPanel) close() {
+	p.apu.EnableScope(false)
+	p.frame.Deallocate()
+}
+
+// layout returns the logical screen size with the panel
+func (p *audioPanel) layout() (int, int) {
+	return panelX + panelWidth, panelHeight
+}
+
+func (p *audioPanel) draw(screen *ebiten.Image, frame []byte) {
+	p.frame.WritePixels(frame)
+
+	options := &ebiten.DrawImageOptions{}
+	options.GeoM.Scale(panelScale, panelScale)
+	screen.DrawImage(p.frame, options)
+
+	vector.FillRect(screen, panelX, 0, panelWidth, panelHeight, panelBackground, false)
+
+	states := p.apu.ChannelStates()
+	for channel := range apu.Channel(apu.ChannelCount) {
+		top := int(channel) * channelBlockHeight
+		p.drawScope(screen, channel, float32(top))
+		ebitenutil.DebugPrintAt(screen, states[channel].String(), panelX+2, top+scopeHeight+2)
+	}
+}
+
+// drawScope draws the output of a channel, starting at its last rising edge
+// in the trigger range so periodic waves stand still
+func (p *audioPanel) drawScope(screen *ebiten.Image, channel apu.Channel, top float32) {
+	vector.FillRect(screen, panelX, top, panelWidth, scopeHeight, scopeBackground, false)
+
+	samples := p.apu.ScopeSamples(channel, p.samples)
+	if len(samples) < len(p.samples) {
+		return
+	}
+
+	start := 0
+	for i := scopeTriggerRange - 1; i > 0; i-- {
+		if samples[i] > samples[i-1] {
+			start = i
+			break
+		}
+	}
+	samples = samples[start : start+scopeSamples]
+
+	colour := scopeColours[channel]
+	if !p.apu.Audible(channel) {
+		colour = scopeMutedColour
+	}
+
+	y := func(sample byte) float32 {
+		return top + scopeHeight - 2 - float32(sample)*(scopeHeight-4)/15
+	}
+
+	for i := 2; i < len(samples); i += 2 {
+		x := float32(panelX + i/2)
+		vector.StrokeLine(screen, x-1, y(samples[i-2]), x, y(samples[i]), 1, colour, false)
+	}
+}
@@ -0,0 +1,306 @@
+// Package frontend shows a Game Boy in an Ebiten window, with its audio output
+// and the emulator hotkeys
+package frontend
+
+import (
+	"log/slog"
+
+	"gb-emulator/internal/apu"
+	"gb-emulator/internal/config"
+	"gb-emulator/internal/gb"
+	"gb-emulator/internal/ppu"
+	"gb-emulator/internal/record"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	fastForwardSpeed = 4
+
+	windowWidth  = 512
+	windowHeight = 480
+)
+
+// Options configures the Ebiten frontend
+type Options struct {
+	Mute             bool
+	Volume           float64 // 0 to 1
+	FastForwardAudio FastForwardAudio
+	AudioQuality     apu.Quality
+	NoHighPass       bool // disable the DMG output capacitor filter
+
+	Scale int // window size in screen pixels, 0 picks the default size
+
+	RecordDir   string // where the R hotkey saves its recordings
+	RecordStems bool   // also record every channel to its own file
+
+	SaveDir string // where the battery saves and the save state slots are kept
+
+	KeyBindings             KeyBindings
+	Player2KeyBindings      KeyBindings // other machines of a local link or SGB controllers
+	Player3KeyBindings      KeyBindings
+	Player4KeyBindings      KeyBindings
+	AllowOppositeDirections bool // let left+right and up+down reach the game
+
+	// Config holds the settings of the config files, applied over these
+	// options and read again with F12. Nil uses only these options
+	Config *config.Store
+}
+
+// DefaultOptions returns the options used by StartGame
+func DefaultOptions() Options {
+	return Options{
+		Volume:             0.8,
+		FastForwardAudio:   FastForwardMute,
+		AudioQuality:       apu.DefaultQuality,
+		RecordDir:          ".",
+		SaveDir:            ".",
+		KeyBindings:        DefaultKeyBindings(),
+		Player2KeyBindings: DefaultPlayer2KeyBindings(),
+		Player3KeyBindings: DefaultPlayer3KeyBindings(),
+		Player4KeyBindings: DefaultPlayer4KeyBindings(),
+	}
+}
+
+// Game implements ebiten.Game for the NES emulator
+type Game struct {
+	gb *gb.GB
+	//renderer *ppu.Renderer
+	paused   bool
+	base     Options        // options given to NewGame, before the config files
+	palette  ppu.DMGPalette // palette of the machine given to NewGame
+	options  Options
+	recorder *record.Recorder
+	vgm      *record.VGMRecorder
+	panel    *audioPanel // audio debug panel, nil when hidden
+	slots    *slotsPanel // save state slots, nil when hidden
+	battery  bool        // the cartridge RAM is saved when the window closes
+	audio    *AudioOutput
+}
+
+// NewGame creates a new Game instance
+func NewGame(machine *gb.GB, options Options) (*Game, error) {
+	game := &Game{
+		gb: machine,
+		//renderer: ppu.NewRenderer(gb.PPU),
+		paused:  false,
+		base:    options,
+		options: options,
+		palette: machine.Palette(),
+	}
+	if err := game.loadConfig(); err != nil {
+		return nil, err
+	}
+
+	audio, err := NewAudioOutput(machine.APU, game.options)
+	if err != nil {
+		return nil, err
+	}
+	game.audio = audio
+
+	machine.Joypad.AllowOppositeDirections = game.options.AllowOppositeDirections
+
+	return game, nil
+}
+
+// Update updates the game logic
+func (g *Game) Update() error {
+	g.handleHotkeys()
+
+	// the game waits while the slots are shown
+	if g.paused || g.slots != nil {
+		return nil
+	}
+
+	g.gb.Joypad.SetButtons(g.options.KeyBindings.Pressed())
+	if g.gb.SGB != nil {
+		// the SGB reads up to four controllers after MLT_REQ
+		others := []KeyBindings{g.options.Player2KeyBindings, g.options.Player3KeyBindings, g.options.Player4KeyBindings}
+		for i, bindings := range others {
+			g.gb.Joypad.SetPlayerButtons(i+1, bindings.Pressed())
+		}
+	}
+
+	// holding Tab runs several frames per update
+	speed := 1
+	if ebiten.IsKeyPressed(ebiten.KeyTab) {
+		speed = fastForwardSpeed
+	}
+
+	for range speed {
+		if err := g.gb.RunFrame(); err != nil {
+			return err
+		}
+	}
+
+	g.audio.Update(speed)
+
+	if g.recorder != nil {
+		if err := g.recorder.Flush(); err != nil {
+			slog.Error("recording failed", "error", err)
+			g.stopRecording()
+		}
+	}
+
+	return nil
+}
+
+// handleHotkeys processes the emulator shortcuts:
+//   - M: mute / unmute
+//   - minus / equal: volume down / up
+//   - Q: cycle the audio synthesis quality
+//   - R: start / stop recording the audio to WAV
+//   - V: start / stop logging the sound registers to VGM
+//   - L: set the VGM loop start
+//   - 1-4: mute / unmute a channel, with Shift: solo / unsolo it
+//   - 0: make every channel audible again
+//   - O: show / hide the oscilloscopes and channel registers
+//   - F1-F10: load a save state slot, with Shift: save it
+//   - F11: show / hide the save state slots
+//   - F12: read the config files again
+func (g *Game) handleHotkeys() {
+	for i, key := range slotKeys {
+		if !inpututil.IsKeyJustPressed(key) {
+			continue
+		}
+
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			g.saveSlot(i + 1)
+		} else {
+			g.loadSlot(i + 1)
+		}
+	}
+
+	for channel, key := range channelKeys {
+		if !inpututil.IsKeyJustPressed(key) {
+			continue
+		}
+
+		a := g.gb.APU
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			a.SetSolo(channel, !a.Solo(channel))
+		} else {
+			a.SetMuted(channel, !a.Muted(channel))
+		}
+	}
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyM):
+		g.audio.ToggleMute()
+	case inpututil.IsKeyJustPressed(ebiten.KeyMinus):
+		g.audio.ChangeVolume(-VolumeStep)
+	case inpututil.IsKeyJustPressed(ebiten.KeyEqual):
+		g.audio.ChangeVolume(VolumeStep)
+	case inpututil.IsKeyJustPressed(ebiten.KeyQ):
+		g.gb.APU.SetQuality((g.gb.APU.Quality() + 1) % (apu.QualityBest + 1))
+	case inpututil.IsKeyJustPressed(ebiten.KeyR):
+		g.toggleRecording()
+	case inpututil.IsKeyJustPressed(ebiten.KeyV):
+		g.toggleVGM()
+	case inpututil.IsKeyJustPressed(ebiten.KeyL):
+		g.markVGMLoop()
+	case inpututil.IsKeyJustPressed(ebiten.KeyDigit0):
+		g.gb.APU.ClearMuteSolo()
+	case inpututil.IsKeyJustPressed(ebiten.KeyO):
+		g.togglePanel()
+	case inpututil.IsKeyJustPressed(ebiten.KeyF11):
+		g.toggleSlots()
+	case inpututil.IsKeyJustPressed(ebiten.KeyF12):
+		g.reloadConfig()
+	}
+}
+
+// channelKeys are the mute and solo hotkeys of each channel
+var channelKeys = map[apu.Channel]ebiten.Key{
+	apu.ChannelSquare1: ebiten.KeyDigit1,
+	apu.ChannelSquare2: ebiten.KeyDigit2,
+	apu.ChannelWave:    ebiten.KeyDigit3,
+	apu.ChannelNoise:   ebiten.KeyDigit4,
+}
+
+func (g *Game) togglePanel() {
+	if g.panel != nil {
+		g.panel.close()
+		g.panel = nil
+		ebiten.SetWindowSize(g.windowSize())
+		return
+	}
+
+	if g.slots != nil {
+		g.toggleSlots()
+	}
+
+	g.panel = newAudioPanel(g.gb.APU)
+	width, height := g.panel.layout()
+	ebiten.SetWindowSize(width*2, height*2)
+}
+
+// windowSize returns the size of the window without the audio panel: the
+// picture at the selected scale, or by default twice the SGB picture
+func (g *Game) windowSize() (int, int) {
+	width, height := g.gb.ScreenSize(true)
+	switch {
+	case g.options.Scale > 0:
+		return width * g.options.Scale, height * g.options.Scale
+	case g.gb.SGB != nil:
+		return width * 2, height * 2
+	}
+	return windowWidth, windowHeight
+}
+
+// Draw draws the game screen
+func (g *Game) Draw(screen *ebiten.Image) {
+	if g.slots != nil {
+		g.slots.draw(screen)
+		return
+	}
+	if g.panel != nil {
+		g.panel.draw(screen, g.gb.PPU.Frame().Pix)
+		return
+	}
+
+	// a Super Game Boy always shows its border around the game
+	screen.WritePixels(g.gb.Screen(true).Pix)
+}
+
+// Layout returns the game's logical screen size
+func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
+	if g.slots != nil {
+		return g.slots.layout()
+	}
+	if g.panel != nil {
+		return g.panel.layout()
+	}
+
+	// Game Boy screen resolution is 160x144, 256x224 with the SGB border
+	return g.gb.ScreenSize(true)
+}
+
+// StartGame initializes and starts the NES game
+func StartGame(machine *gb.GB) error {
+	return StartGameWithOptions(machine, DefaultOptions())
+}
+
+// StartGameWithOptions starts the game with a custom frontend configuration
+func StartGameWithOptions(machine *gb.GB, options Options) error {
+	game, err := NewGame(machine, options)
+	if err != nil {
+		return err
+	}
+
+	// Configure window
+	ebiten.SetWindowSize(game.windowSize())
+	ebiten.SetWindowTitle("GB Emulator")
+
+	game.loadBattery()
+
+	// Run the game, the recordings in progress are completed and the
+	// cartridge RAM saved when the window closes
+	err = ebiten.RunGame(game)
+	game.stopRecording()
+	game.stopVGM()
+	game.saveBattery()
+
+	return err
+}
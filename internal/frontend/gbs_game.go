@@ -0,0 +1,133 @@
+package frontend
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gb-emulator/internal/apu"
+	"gb-emulator/internal/gbs"
+	"gb-emulator/internal/ppu"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	gbsWindowWidth  = 320
+	gbsWindowHeight = 144
+)
+
+// gbsGame implements ebiten.Game for the GBS player
+type gbsGame struct {
+	player  *gbs.Player
+	options Options
+	audio   *AudioOutput
+	paused  bool
+	err     error // error of the last track change, shown on screen
+}
+
+// StartGBS opens a window playing the given track. Left/Right change
+// the track, Space pauses, M mutes and minus/equal change the volume
+func StartGBS(player *gbs.Player, track int, options Options) error {
+	if err := player.Start(track); err != nil {
+		return err
+	}
+
+	game := &gbsGame{player: player, options: options}
+	if err := game.startAudio(); err != nil {
+		return err
+	}
+
+	ebiten.SetWindowSize(gbsWindowWidth*2, gbsWindowHeight*2)
+	ebiten.SetWindowTitle("GBS Player")
+
+	return ebiten.RunGame(game)
+}
+
+// startAudio connects the audio output to the APU of the current track,
+// every track runs on a new machine
+func (g *gbsGame) startAudio() error {
+	if g.audio != nil {
+		g.audio.Close()
+	}
+
+	audio, err := NewAudioOutput(g.player.GB.APU, g.options)
+	if err != nil {
+		return err
+	}
+	g.audio = audio
+	return nil
+}
+
+func (g *gbsGame) changeTrack(delta int) error {
+	songs := g.player.File.Songs
+	track := (g.player.Track()-1+delta+songs)%songs + 1
+
+	if err := g.player.Start(track); err != nil {
+		g.err = err
+		return nil
+	}
+	g.err = nil
+
+	return g.startAudio()
+}
+
+// Update runs one frame of the track
+func (g *gbsGame) Update() error {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyRight):
+		if err := g.changeTrack(1); err != nil {
+			return err
+		}
+	case inpututil.IsKeyJustPressed(ebiten.KeyLeft):
+		if err := g.changeTrack(-1); err != nil {
+			return err
+		}
+	case inpututil.IsKeyJustPressed(ebiten.KeySpace):
+		g.paused = !g.paused
+	case inpututil.IsKeyJustPressed(ebiten.KeyM):
+		g.audio.ToggleMute()
+	case inpututil.IsKeyJustPressed(ebiten.KeyMinus):
+		g.audio.ChangeVolume(-VolumeStep)
+	case inpututil.IsKeyJustPressed(ebiten.KeyEqual):
+		g.audio.ChangeVolume(VolumeStep)
+	}
+
+	if g.paused || g.err != nil {
+		return nil
+	}
+
+	if err := g.player.Run(ppu.DotsPerFrame); err != nil {
+		g.err = err
+		return nil
+	}
+	g.audio.Update(1)
+
+	return nil
+}
+
+// Draw shows the file information and the playing track
+func (g *gbsGame) Draw(screen *ebiten.Image) {
+	file := g.player.File
+	elapsed := time.Duration(g.player.Elapsed()) * time.Second / apu.ClockFrequency
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s\n%s\n%s\n\n", file.Title, file.Author, file.Copyright)
+	fmt.Fprintf(&text, "Track %d/%d  %s\n", g.player.Track(), file.Songs, elapsed.Truncate(time.Second))
+	if g.paused {
+		text.WriteString("Paused\n")
+	}
+	if g.err != nil {
+		fmt.Fprintf(&text, "Error: %v\n", g.err)
+	}
+	text.WriteString("\n<- -> track  Space pause  M mute")
+
+	ebitenutil.DebugPrint(screen, text.String())
+}
+
+// Layout returns the logical screen size
+func (g *gbsGame) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return gbsWindowWidth, gbsWindowHeight
+}
@@ -0,0 +1,95 @@
+package frontend
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"gb-emulator/internal/joypad"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// KeyBindings maps every joypad button to the keyboard keys that press it
+type KeyBindings map[joypad.Button][]ebiten.Key
+
+// DefaultKeyBindings returns the arrows for the d-pad, X/Z for A/B, Enter for
+// Start and Backspace for Select
+func DefaultKeyBindings() KeyBindings {
+	return KeyBindings{
+		joypad.ButtonRight:  {ebiten.KeyArrowRight},
+		joypad.ButtonLeft:   {ebiten.KeyArrowLeft},
+		joypad.ButtonUp:     {ebiten.KeyArrowUp},
+		joypad.ButtonDown:   {ebiten.KeyArrowDown},
+		joypad.ButtonA:      {ebiten.KeyX},
+		joypad.ButtonB:      {ebiten.KeyZ},
+		joypad.ButtonSelect: {ebiten.KeyBackspace},
+		joypad.ButtonStart:  {ebiten.KeyEnter},
+	}
+}
+
+// ParseKeyBindings changes a copy of defaults with a list such as
+// "a=K,b=J,start=Space+Enter". Every entry replaces the keys of a button,
+// several keys are separated by "+" and use the Ebiten key names
+func ParseKeyBindings(spec string, defaults KeyBindings) (KeyBindings, error) {
+	bindings := maps.Clone(defaults)
+	if strings.TrimSpace(spec) == "" {
+		return bindings, nil
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		name, keyNames, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid key binding: %q (expected button=key)", entry)
+		}
+
+		button, err := joypad.ParseButton(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+
+		var keys []ebiten.Key
+		for _, keyName := range strings.Split(keyNames, "+") {
+			var key ebiten.Key
+			if err := key.UnmarshalText([]byte(strings.TrimSpace(keyName))); err != nil {
+				return nil, fmt.Errorf("unknown key for %s: %q", button, keyName)
+			}
+			keys = append(keys, key)
+		}
+		bindings[button] = keys
+	}
+
+	return bindings, nil
+}
+
+// Pressed returns the buttons whose keys are held down
+func (b KeyBindings) Pressed() joypad.Button {
+	var buttons joypad.Button
+	for button, keys := range b {
+		for _, key := range keys {
+			if ebiten.IsKeyPressed(key) {
+				buttons |= button
+				break
+			}
+		}
+	}
+	return buttons
+}
+
+// String lists the bindings in the format read by ParseKeyBindings
+func (b KeyBindings) String() string {
+	var entries []string
+	for _, button := range joypad.Buttons() {
+		keys, exists := b[button]
+		if !exists {
+			continue
+		}
+
+		names := make([]string, len(keys))
+		for i, key := range keys {
+			names[i] = key.String()
+		}
+		entries = append(entries, button.String()+"="+strings.Join(names, "+"))
+	}
+	return strings.Join(entries, ",")
+}
@@ -0,0 +1,167 @@
+package frontend
+
+import (
+	"gb-emulator/internal/gb"
+	"gb-emulator/internal/joypad"
+	"gb-emulator/internal/ppu"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	linkedGap     = 8 // space between the screens
+	linkedColumns = 2
+)
+
+// DefaultPlayer2KeyBindings returns WASD for the d-pad, K/J for A/B, Space for
+// Start and E for Select, away from the keys of the first player and from the
+// Shift hotkeys
+func DefaultPlayer2KeyBindings() KeyBindings {
+	return KeyBindings{
+		joypad.ButtonRight:  {ebiten.KeyD},
+		joypad.ButtonLeft:   {ebiten.KeyA},
+		joypad.ButtonUp:     {ebiten.KeyW},
+		joypad.ButtonDown:   {ebiten.KeyS},
+		joypad.ButtonA:      {ebiten.KeyK},
+		joypad.ButtonB:      {ebiten.KeyJ},
+		joypad.ButtonSelect: {ebiten.KeyE},
+		joypad.ButtonStart:  {ebiten.KeySpace},
+	}
+}
+
+// DefaultPlayer3KeyBindings returns TFGH for the d-pad, U/Y for A/B, 7 for
+// Start and 6 for Select
+func DefaultPlayer3KeyBindings() KeyBindings {
+	return KeyBindings{
+		joypad.ButtonRight:  {ebiten.KeyH},
+		joypad.ButtonLeft:   {ebiten.KeyF},
+		joypad.ButtonUp:     {ebiten.KeyT},
+		joypad.ButtonDown:   {ebiten.KeyG},
+		joypad.ButtonA:      {ebiten.KeyU},
+		joypad.ButtonB:      {ebiten.KeyY},
+		joypad.ButtonSelect: {ebiten.KeyDigit6},
+		joypad.ButtonStart:  {ebiten.KeyDigit7},
+	}
+}
+
+// DefaultPlayer4KeyBindings returns the numeric keypad: 8/4/5/6 for the
+// d-pad, 3/1 for A/B, Enter for Start and plus for Select
+func DefaultPlayer4KeyBindings() KeyBindings {
+	return KeyBindings{
+		joypad.ButtonRight:  {ebiten.KeyNumpad6},
+		joypad.ButtonLeft:   {ebiten.KeyNumpad4},
+		joypad.ButtonUp:     {ebiten.KeyNumpad8},
+		joypad.ButtonDown:   {ebiten.KeyNumpad5},
+		joypad.ButtonA:      {ebiten.KeyNumpad3},
+		joypad.ButtonB:      {ebiten.KeyNumpad1},
+		joypad.ButtonSelect: {ebiten.KeyNumpadAdd},
+		joypad.ButtonStart:  {ebiten.KeyNumpadEnter},
+	}
+}
+
+// linkedGame implements ebiten.Game for linked Game Boys: two are shown
+// side by side and three or four tiled in a 2x2 grid. Only the first one is
+// heard
+type linkedGame struct {
+	linked   *gb.Linked
+	bindings []KeyBindings
+	screens  []*ebiten.Image
+	audio    *AudioOutput
+	paused   bool
+}
+
+// StartLinked opens a window with every machine of the link, driven by
+// options.KeyBindings and options.Player2KeyBindings to Player4KeyBindings
+func StartLinked(linked *gb.Linked, options Options) error {
+	audio, err := NewAudioOutput(linked.Players[0].APU, options)
+	if err != nil {
+		return err
+	}
+
+	game := &linkedGame{
+		linked: linked,
+		bindings: []KeyBindings{
+			options.KeyBindings,
+			options.Player2KeyBindings,
+			options.Player3KeyBindings,
+			options.Player4KeyBindings,
+		},
+		audio: audio,
+	}
+	for _, player := range linked.Players {
+		player.Joypad.AllowOppositeDirections = options.AllowOppositeDirections
+		game.screens = append(game.screens, ebiten.NewImage(ppu.ScreenWidth, ppu.ScreenHeight))
+	}
+
+	width, height := game.Layout(0, 0)
+	scale := 3
+	switch {
+	case options.Scale > 0:
+		scale = options.Scale
+	case len(linked.Players) > 2:
+		scale = 2
+	}
+	ebiten.SetWindowSize(width*scale, height*scale)
+	ebiten.SetWindowTitle("GB Emulator - Link")
+
+	return ebiten.RunGame(game)
+}
+
+// Update runs one frame of every machine in lockstep. P pauses, M mutes and
+// minus/equal change the volume
+func (g *linkedGame) Update() error {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyP):
+		g.paused = !g.paused
+	case inpututil.IsKeyJustPressed(ebiten.KeyM):
+		g.audio.ToggleMute()
+	case inpututil.IsKeyJustPressed(ebiten.KeyMinus):
+		g.audio.ChangeVolume(-VolumeStep)
+	case inpututil.IsKeyJustPressed(ebiten.KeyEqual):
+		g.audio.ChangeVolume(VolumeStep)
+	}
+
+	if g.paused {
+		return nil
+	}
+
+	for i, player := range g.linked.Players {
+		player.Joypad.SetButtons(g.bindings[i].Pressed())
+	}
+
+	if err := g.linked.RunFor(ppu.DotsPerFrame); err != nil {
+		return err
+	}
+
+	g.audio.Update(1)
+	// only the first machine is heard, the samples of the others are dropped
+	for _, player := range g.linked.Players[1:] {
+		player.APU.TakeSamples()
+	}
+
+	return nil
+}
+
+// rows returns the number of rows of screens
+func (g *linkedGame) rows() int {
+	return (len(g.linked.Players) + linkedColumns - 1) / linkedColumns
+}
+
+// Draw shows the machines from left to right and top to bottom
+func (g *linkedGame) Draw(screen *ebiten.Image) {
+	for i, player := range g.linked.Players {
+		g.screens[i].WritePixels(player.PPU.Frame().Pix)
+
+		column, row := i%linkedColumns, i/linkedColumns
+		options := &ebiten.DrawImageOptions{}
+		options.GeoM.Translate(float64(column*(ppu.ScreenWidth+linkedGap)), float64(row*(ppu.ScreenHeight+linkedGap)))
+		screen.DrawImage(g.screens[i], options)
+	}
+}
+
+// Layout returns the logical screen size with every screen
+func (g *linkedGame) Layout(outsideWidth, outsideHeight int) (int, int) {
+	rows := g.rows()
+	return ppu.ScreenWidth*linkedColumns + linkedGap*(linkedColumns-1), ppu.ScreenHeight*rows + linkedGap*(rows-1)
+}
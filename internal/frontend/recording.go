@@ -0,0 +1,95 @@
+package frontend
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"gb-emulator/internal/gb"
+	"gb-emulator/internal/record"
+)
+
+func (g *Game) toggleRecording() {
+	if g.recorder != nil {
+		g.stopRecording()
+		return
+	}
+
+	path := g.recordingPath(".wav")
+	recorder, err := record.Start(g.gb.APU, path, g.gb.APU.SampleRate(), g.options.RecordStems)
+	if err != nil {
+		slog.Error("cannot start the recording", "error", err)
+		return
+	}
+
+	g.recorder = recorder
+	slog.Info("recording audio", "file", path)
+}
+
+func (g *Game) stopRecording() {
+	if g.recorder == nil {
+		return
+	}
+
+	if err := g.recorder.Close(); err != nil {
+		slog.Error("cannot close the recording", "error", err)
+	}
+	g.recorder = nil
+	slog.Info("recording stopped")
+}
+
+// recordingName returns a file name prefix based on the cartridge title
+func recordingName(header *gb.CartridgeHeader) string {
+	if header == nil || header.Title == "" {
+		return "gb"
+	}
+
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, header.Title)
+}
+
+// recordingPath returns a new file in the recording directory, named after
+// the cartridge and the current time
+func (g *Game) recordingPath(extension string) string {
+	name := fmt.Sprintf("%s-%s%s", recordingName(g.gb.Header), time.Now().Format("20060102-150405"), extension)
+	return filepath.Join(g.options.RecordDir, name)
+}
+
+func (g *Game) toggleVGM() {
+	if g.vgm != nil {
+		g.stopVGM()
+		return
+	}
+
+	path := g.recordingPath(".vgm")
+	g.vgm = record.StartVGM(g.gb.APU, path, g.gb.VGMTag())
+	slog.Info("logging the APU", "file", path)
+}
+
+func (g *Game) markVGMLoop() {
+	if g.vgm == nil {
+		return
+	}
+
+	g.vgm.MarkLoop()
+	slog.Info("VGM loop start marked")
+}
+
+func (g *Game) stopVGM() {
+	if g.vgm == nil {
+		return
+	}
+
+	if err := g.vgm.Close(); err != nil {
+		slog.Error("cannot close the VGM file", "error", err)
+	}
+	g.vgm = nil
+	slog.Info("VGM log stopped")
+}
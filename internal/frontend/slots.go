@@ -0,0 +1,198 @@
+package frontend
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+
+	"gb-emulator/internal/ppu"
+	"gb-emulator/internal/savestate"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// The slots screen shows the thumbnail of the ten save state slots in two
+// rows, with the key and the time of every slot below its picture
+const (
+	slotCount   = 10
+	slotColumns = 5
+
+	slotThumbnailWidth  = ppu.ScreenWidth / 2
+	slotThumbnailHeight = ppu.ScreenHeight / 2
+	slotCellWidth       = slotThumbnailWidth + 16
+	slotCellHeight      = slotThumbnailHeight + 28
+	slotsScale          = 2
+)
+
+var (
+	slotsBackground = color.RGBA{0x10, 0x10, 0x18, 0xFF}
+	slotEmptyColour = color.RGBA{0x20, 0x20, 0x30, 0xFF}
+)
+
+// slotKeys load a slot, with Shift they save it
+var slotKeys = [slotCount]ebiten.Key{
+	ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3, ebiten.KeyF4, ebiten.KeyF5,
+	ebiten.KeyF6, ebiten.KeyF7, ebiten.KeyF8, ebiten.KeyF9, ebiten.KeyF10,
+}
+
+// slotPath returns the file of a slot, numbered from 1, named after the
+// cartridge like the recordings
+func (g *Game) slotPath(slot int) string {
+	name := fmt.Sprintf("%s-%d.state", recordingName(g.gb.Header), slot)
+	return filepath.Join(g.options.SaveDir, name)
+}
+
+func (g *Game) saveSlot(slot int) {
+	path := g.slotPath(slot)
+	if err := g.gb.SaveStateFile(path); err != nil {
+		slog.Error("cannot save the state", "slot", slot, "error", err)
+		return
+	}
+
+	if g.slots != nil {
+		g.slots.refresh(slot)
+	}
+	slog.Info("state saved", "slot", slot, "file", path)
+}
+
+func (g *Game) loadSlot(slot int) {
+	err := g.gb.LoadStateFile(g.slotPath(slot))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		slog.Warn("the slot is empty", "slot", slot)
+	case err != nil:
+		slog.Error("cannot load the state", "slot", slot, "error", err)
+	default:
+		slog.Info("state loaded", "slot", slot)
+	}
+}
+
+// batteryPath returns the file keeping the cartridge RAM, next to the slots
+func (g *Game) batteryPath() string {
+	return filepath.Join(g.options.SaveDir, recordingName(g.gb.Header)+".sav")
+}
+
+// loadBattery reads the cartridge RAM. When the file can't be read it is
+// left untouched, the RAM isn't saved over it
+func (g *Game) loadBattery() {
+	if !g.gb.HasBattery() {
+		return
+	}
+	if err := g.gb.LoadBatteryFile(g.batteryPath()); err != nil {
+		slog.Error("cannot load the save, it won't be written on exit", "error", err)
+		return
+	}
+	g.battery = true
+}
+
+func (g *Game) saveBattery() {
+	if !g.battery {
+		return
+	}
+	path := g.batteryPath()
+	if err := g.gb.SaveBatteryFile(path); err != nil {
+		slog.Error("cannot write the save", "error", err)
+		return
+	}
+	slog.Info("save written", "file", path)
+}
+
+func (g *Game) toggleSlots() {
+	if g.slots != nil {
+		g.slots.close()
+		g.slots = nil
+		ebiten.SetWindowSize(g.windowSize())
+		return
+	}
+
+	// only one panel fits in the window
+	if g.panel != nil {
+		g.togglePanel()
+	}
+
+	g.slots = newSlotsPanel(g.slotPath)
+	width, height := g.slots.layout()
+	ebiten.SetWindowSize(width*slotsScale, height*slotsScale)
+}
+
+// slotsPanel draws the thumbnails of the slots, read from their files when
+// it is opened and after every save
+type slotsPanel struct {
+	path       func(slot int) string
+	thumbnails [slotCount]*ebiten.Image
+	labels     [slotCount]string
+}
+
+func newSlotsPanel(path func(slot int) string) *slotsPanel {
+	p := &slotsPanel{path: path}
+	for slot := 1; slot <= slotCount; slot++ {
+		p.refresh(slot)
+	}
+	return p
+}
+
+// refresh reads the header and the thumbnail of a slot again
+func (p *slotsPanel) refresh(slot int) {
+	i := slot - 1
+	if p.thumbnails[i] != nil {
+		p.thumbnails[i].Deallocate()
+		p.thumbnails[i] = nil
+	}
+
+	label := fmt.Sprintf("F%d", slot)
+	r, err := savestate.ReadFile(p.path(slot))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		p.labels[i] = label + " empty"
+		return
+	case err != nil:
+		p.labels[i] = label + " damaged"
+		return
+	}
+
+	p.labels[i] = label + " " + r.Header.Time.Format("02/01 15:04")
+	if thumbnail := r.Thumbnail(); thumbnail != nil {
+		p.thumbnails[i] = ebiten.NewImageFromImage(thumbnail)
+	}
+}
+
+func (p *slotsPanel) close() {
+	for _, thumbnail := range p.thumbnails {
+		if thumbnail != nil {
+			thumbnail.Deallocate()
+		}
+	}
+}
+
+// layout returns the logical screen size of the slots screen
+func (p *slotsPanel) layout() (int, int) {
+	return slotColumns * slotCellWidth, slotCount / slotColumns * slotCellHeight
+}
+
+func (p *slotsPanel) draw(screen *ebiten.Image) {
+	screen.Fill(slotsBackground)
+
+	for i := range slotCount {
+		x := i%slotColumns*slotCellWidth + (slotCellWidth-slotThumbnailWidth)/2
+		y := i/slotColumns*slotCellHeight + 4
+
+		thumbnail := p.thumbnails[i]
+		if thumbnail == nil {
+			vector.FillRect(screen, float32(x), float32(y), slotThumbnailWidth, slotThumbnailHeight, slotEmptyColour, false)
+		} else {
+			// SGB thumbnails include the border, they are shrunk to the same width
+			options := &ebiten.DrawImageOptions{}
+			scale := float64(slotThumbnailWidth) / float64(thumbnail.Bounds().Dx())
+			options.GeoM.Scale(scale, scale)
+			options.GeoM.Translate(float64(x), float64(y))
+			screen.DrawImage(thumbnail, options)
+		}
+
+		ebitenutil.DebugPrintAt(screen, p.labels[i], x, y+slotThumbnailHeight+4)
+	}
+}
@@ -0,0 +1,67 @@
+package gb
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// batteryCartridge is a controller whose external RAM can be kept by a battery
+type batteryCartridge interface {
+	RAM() []byte
+}
+
+// batteryRAM returns the external RAM kept by the battery of the cartridge,
+// nil when the cartridge has no battery
+func (n *GB) batteryRAM() []byte {
+	if n.Header == nil || !n.Header.HasBattery() {
+		return nil
+	}
+	if cartridge, ok := n.Cpu.Memory.Cartridge().(batteryCartridge); ok {
+		return cartridge.RAM()
+	}
+	return nil
+}
+
+// HasBattery reports whether the loaded cartridge keeps its RAM with a battery
+func (n *GB) HasBattery() bool {
+	return len(n.batteryRAM()) > 0
+}
+
+// LoadBatteryFile restores the external RAM from a file written by
+// SaveBatteryFile. A missing file is a new game and leaves the RAM as is
+func (n *GB) LoadBatteryFile(path string) error {
+	ram := n.batteryRAM()
+	if ram == nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read the save: %w", err)
+	}
+	if len(data) != len(ram) {
+		return fmt.Errorf("the save %s has %d bytes, the cartridge RAM %d", path, len(data), len(ram))
+	}
+
+	copy(ram, data)
+	return nil
+}
+
+// SaveBatteryFile writes the external RAM kept by the battery to the file,
+// nothing is written when the cartridge has no battery
+func (n *GB) SaveBatteryFile(path string) error {
+	ram := n.batteryRAM()
+	if ram == nil {
+		return nil
+	}
+
+	if err := os.WriteFile(path, ram, 0o644); err != nil {
+		return fmt.Errorf("cannot write the save: %w", err)
+	}
+	return nil
+}
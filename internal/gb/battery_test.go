@@ -0,0 +1,67 @@
+package gb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gb-emulator/internal/memory"
+)
+
+// newTestROM returns a 64KB ROM of the given cartridge type and RAM size code
+func newTestROM(cartridgeType byte, ramSizeCode byte) []byte {
+	rom := make([]byte, 4*memory.RomBank0Size)
+	copy(rom[titleStartAddress:], "TEST")
+	rom[cartridgeTypeAddress] = cartridgeType
+	rom[ramSizeAddress] = ramSizeCode
+	return rom
+}
+
+func TestBatteryFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sav")
+
+	machine := New()
+	if err := machine.LoadROM(newTestROM(CartridgeMBC1RAMBattery, 0x02)); err != nil {
+		t.Fatal(err)
+	}
+	machine.Cpu.Memory.Write(0x0000, 0x0A) // enable the RAM
+	machine.Cpu.Memory.Write(memory.ExternalRamStartAddress+0x10, 0x5A)
+	if err := machine.SaveBatteryFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := New()
+	if err := restored.LoadROM(newTestROM(CartridgeMBC1RAMBattery, 0x02)); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.LoadBatteryFile(path); err != nil {
+		t.Fatal(err)
+	}
+	restored.Cpu.Memory.Write(0x0000, 0x0A)
+	if value := restored.Cpu.Memory.Read(memory.ExternalRamStartAddress + 0x10); value != 0x5A {
+		t.Errorf("RAM read %02X after loading, want 5A", value)
+	}
+}
+
+func TestBatteryOnlyWithBatteryCartridges(t *testing.T) {
+	tests := []struct {
+		cartridgeType byte
+		ramSizeCode   byte
+		want          bool
+	}{
+		{CartridgeROMOnly, 0x00, false},
+		{CartridgeMBC1, 0x00, false},
+		{CartridgeMBC1RAM, 0x02, false},
+		{CartridgeMBC1RAMBattery, 0x00, false},
+		{CartridgeMBC1RAMBattery, 0x03, true},
+	}
+
+	for _, test := range tests {
+		machine := New()
+		if err := machine.LoadROM(newTestROM(test.cartridgeType, test.ramSizeCode)); err != nil {
+			t.Fatal(err)
+		}
+		if got := machine.HasBattery(); got != test.want {
+			t.Errorf("type %02X, RAM %02X: HasBattery() = %v, want %v", test.cartridgeType, test.ramSizeCode, got, test.want)
+		}
+	}
+}
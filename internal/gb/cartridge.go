@@ -0,0 +1,123 @@
+package gb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Documentation
+// * https://gbdev.io/pandocs/The_Cartridge_Header.html
+
+const (
+	titleStartAddress       = 0x0134
+	titleEndAddress         = 0x0143 // included, shared with the CGB flag on newer cartridges
+	cgbFlagAddress          = 0x0143
+	newLicenseeAddress      = 0x0144
+	sgbFlagAddress          = 0x0146
+	cartridgeTypeAddress    = 0x0147
+	ramSizeAddress          = 0x0149
+	oldLicenseeAddress      = 0x014B
+	headerChecksumAddress   = 0x014D
+	globalChecksumAddress   = 0x014E
+	cartridgeHeaderEndRange = 0x0150
+)
+
+// Cartridge types with a memory bank controller the emulator supports
+const (
+	CartridgeROMOnly        = 0x00
+	CartridgeMBC1           = 0x01
+	CartridgeMBC1RAM        = 0x02
+	CartridgeMBC1RAMBattery = 0x03
+)
+
+// ramSizes is the external RAM size of every RAM size code
+var ramSizes = map[byte]int{
+	0x00: 0,
+	0x01: 2 * 1024,
+	0x02: 8 * 1024,
+	0x03: 32 * 1024,
+	0x04: 128 * 1024,
+	0x05: 64 * 1024,
+}
+
+// CartridgeHeader holds the fields of the cartridge header (0x0100-0x014F)
+// the emulator cares about
+type CartridgeHeader struct {
+	Title          string // upper case ASCII title, without trailing zeros
+	RawTitle       [16]byte
+	CGBFlag        byte // 0x80: CGB enhanced, 0xC0: CGB only
+	NewLicensee    [2]byte
+	SGBFlag        byte // 0x03: SGB functions supported
+	CartridgeType  byte
+	RAMSizeCode    byte
+	OldLicensee    byte
+	HeaderChecksum byte
+	GlobalChecksum uint16
+}
+
+// ParseCartridgeHeader reads the cartridge header from the start of a ROM
+func ParseCartridgeHeader(romData []byte) (*CartridgeHeader, error) {
+	if len(romData) < cartridgeHeaderEndRange {
+		return nil, fmt.Errorf("ROM too small to contain a cartridge header: %d bytes", len(romData))
+	}
+
+	header := &CartridgeHeader{
+		CGBFlag:        romData[cgbFlagAddress],
+		NewLicensee:    [2]byte{romData[newLicenseeAddress], romData[newLicenseeAddress+1]},
+		SGBFlag:        romData[sgbFlagAddress],
+		CartridgeType:  romData[cartridgeTypeAddress],
+		RAMSizeCode:    romData[ramSizeAddress],
+		OldLicensee:    romData[oldLicenseeAddress],
+		HeaderChecksum: romData[headerChecksumAddress],
+		GlobalChecksum: uint16(romData[globalChecksumAddress])<<8 | uint16(romData[globalChecksumAddress+1]),
+	}
+	copy(header.RawTitle[:], romData[titleStartAddress:titleEndAddress+1])
+
+	titleLength := len(header.RawTitle)
+	if header.IsCGB() {
+		titleLength-- // the last title byte is the CGB flag
+	}
+	header.Title = strings.TrimRight(string(header.RawTitle[:titleLength]), "\x00 ")
+
+	return header, nil
+}
+
+// IsCGB reports whether the cartridge supports the Game Boy Color
+func (h *CartridgeHeader) IsCGB() bool {
+	return h.CGBFlag&0x80 != 0
+}
+
+// RAMSize returns the size in bytes of the external RAM of the cartridge
+func (h *CartridgeHeader) RAMSize() int {
+	return ramSizes[h.RAMSizeCode]
+}
+
+// HasBattery reports whether the external RAM of the cartridge is kept by a battery
+func (h *CartridgeHeader) HasBattery() bool {
+	return h.CartridgeType == CartridgeMBC1RAMBattery
+}
+
+// IsMBC1 reports whether the cartridge uses an MBC1 controller
+func (h *CartridgeHeader) IsMBC1() bool {
+	return h.CartridgeType >= CartridgeMBC1 && h.CartridgeType <= CartridgeMBC1RAMBattery
+}
+
+// IsSGB reports whether the cartridge supports the Super Game Boy functions
+func (h *CartridgeHeader) IsSGB() bool {
+	return h.SGBFlag == 0x03
+}
+
+// TitleChecksum is the sum of the 16 title bytes, used by the CGB boot ROM
+// to pick the compatibility palette of original Game Boy games
+func (h *CartridgeHeader) TitleChecksum() byte {
+	var checksum byte
+	for _, b := range h.RawTitle {
+		checksum += b
+	}
+	return checksum
+}
+
+// IsNintendoLicensee reports whether the game was published by Nintendo
+func (h *CartridgeHeader) IsNintendoLicensee() bool {
+	return h.OldLicensee == 0x01 || (h.OldLicensee == 0x33 && h.NewLicensee == [2]byte{'0', '1'})
+}
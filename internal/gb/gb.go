@@ -1,102 +1,335 @@
-// Package nes implements the NES system integration
-package gb
-
-import (
-	"gb-emulator/internal/cpu"
-)
-
-// NES represents the Nintendo Entertainment System
-type GB struct {
-	Cpu *cpu.Cpu
-	//PPU    *ppu.PPU
-	//Memory *memory.Memory
-
-	// System state
-	Running bool
-	//Cycles  uint64
-}
-
-// New creates a new NES instance
-func New() *GB {
-	cpuInstance := cpu.NewCPU()
-
-	gb := &GB{
-		Cpu: cpuInstance,
-		//PPU:     ppu.NewPPU(),
-		//Memory:  memory.New(),
-		Running: false,
-		//Cycles:  0,
-	}
-
-	// Connect components
-	//nes.CPU.SetMemory(nes.Memory)
-	//nes.PPU.SetCPU(nes.CPU)
-	//nes.Memory.SetPPU(nes.PPU)
-
-	return gb
-}
-
-// Reset resets the NES to its initial state
-func (n *GB) Reset() {
-	//n.Memory.Reset()
-	//n.PPU.Reset()
-	//n.CPU.Reset()
-	//n.Cycles = 0
-}
-
-func (n *GB) LoadBootROM(bootRomData []byte) error {
-
-	for i := 0; i < len(bootRomData); i++ {
-		if i == len(n.Cpu.BootRomBank0) {
-			panic("exceed memory")
-		}
-
-		n.Cpu.BootRomBank0[i] = bootRomData[i]
-	}
-
-	return nil
-}
-
-// LoadROM loads a ROM file into memory
-func (n *GB) LoadROM(romData []byte) error {
-
-	for i := 0; i < len(romData) && i < len(n.Cpu.RomBank0); i++ {
-		if i == len(n.Cpu.RomBank0) {
-			panic("exceed memory")
-		}
-
-		n.Cpu.RomBank0[i] = romData[i]
-	}
-
-	return nil
-}
-
-// Step advances the NES emulation by one CPU instruction
-func (n *GB) Step() error {
-	// Execute one CPU instruction
-	_, err := n.Cpu.Step()
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// Run runs the NES emulation until stopped
-func (gb *GB) Run() error {
-	gb.Running = true
-
-	for gb.Running {
-		err := gb.Step()
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
-// Stop stops the NES emulation
-func (gb *GB) Stop() {
-	gb.Running = false
-}
+// Package nes implements the NES system integration
+package gb
+
+import (
+	"gb-emulator/internal/apu"
+	"gb-emulator/internal/cpu"
+	"gb-emulator/internal/infrared"
+	"gb-emulator/internal/joypad"
+	"gb-emulator/internal/memory"
+	"gb-emulator/internal/ppu"
+	"gb-emulator/internal/record"
+	"gb-emulator/internal/serial"
+	"gb-emulator/internal/sgb"
+	"gb-emulator/internal/timer"
+)
+
+// NES represents the Nintendo Entertainment System
+type GB struct {
+	Cpu    *cpu.Cpu
+	PPU    *ppu.PPU
+	APU    *apu.APU
+	Timer  *timer.Timer
+	Joypad *joypad.Joypad
+	Serial *serial.Serial
+	IR     *infrared.Port
+	//Memory *memory.Memory
+
+	// Super Game Boy, nil unless the SGB model is selected
+	SGB *sgb.SGB
+
+	// Cartridge header of the loaded ROM, nil until LoadROM is called
+	Header *CartridgeHeader
+
+	// Model is the requested hardware, it must be set before LoadROM
+	Model Model
+
+	// Per-game palettes, they win over the palette given to SetPalette and
+	// over the compatibility colours of the CGB. See SetPaletteOverrides
+	PaletteOverrides *ppu.PaletteOverrides
+	palette          ppu.DMGPalette // selected with SetPalette
+
+	// System state
+	Running bool
+	Cycles  uint64 // T-cycles at normal speed since power on
+}
+
+// New creates a new NES instance
+func New() *GB {
+	cpuInstance := cpu.NewCPU()
+
+	gb := &GB{
+		Cpu:    cpuInstance,
+		PPU:    ppu.NewPPU(&cpuInstance.Memory),
+		APU:    apu.New(&cpuInstance.Memory, apu.DefaultSampleRate),
+		Timer:  timer.New(&cpuInstance.Memory),
+		Joypad: joypad.New(&cpuInstance.Memory),
+		Serial: serial.New(&cpuInstance.Memory),
+		IR:     infrared.New(&cpuInstance.Memory),
+		//Memory:  memory.New(),
+		PaletteOverrides: ppu.NewPaletteOverrides(),
+		palette:          ppu.Presets[ppu.DefaultPreset],
+		Running:          false,
+		//Cycles:  0,
+	}
+
+	// Connect components
+	gb.Timer.APUClock = gb.APU.ClockFrameSequencer
+	gb.Joypad.OnPress = gb.wake
+	gb.IR.Clock = func() uint64 { return gb.Cycles }
+	//nes.CPU.SetMemory(nes.Memory)
+	//nes.PPU.SetCPU(nes.CPU)
+	//nes.Memory.SetPPU(nes.PPU)
+
+	return gb
+}
+
+// Reset resets the NES to its initial state
+func (n *GB) Reset() {
+	//n.Memory.Reset()
+	//n.PPU.Reset()
+	//n.CPU.Reset()
+	//n.Cycles = 0
+}
+
+func (n *GB) LoadBootROM(bootRomData []byte) error {
+
+	for i := 0; i < len(bootRomData); i++ {
+		if i == len(n.Cpu.BootRomBank0) {
+			panic("exceed memory")
+		}
+
+		n.Cpu.BootRomBank0[i] = bootRomData[i]
+	}
+
+	return nil
+}
+
+// LoadROM loads a ROM file into memory. MBC1 cartridges are mapped with
+// their controller, the rest as a fixed 32KB ROM
+func (n *GB) LoadROM(romData []byte) error {
+	header, err := ParseCartridgeHeader(romData)
+	if err != nil {
+		return err
+	}
+	n.Header = header
+
+	if header.IsMBC1() {
+		n.Cpu.Memory.MapCartridge(memory.NewMBC1(romData, header.RAMSize()))
+	} else {
+		copy(n.Cpu.RomBank0[:], romData)
+		if len(romData) > len(n.Cpu.RomBank0) {
+			copy(n.Cpu.SwitchableRomBank[:], romData[len(n.Cpu.RomBank0):])
+		}
+	}
+
+	// CGB mode is only used for cartridges that support it, the rest run in
+	// compatibility mode with the palettes the CGB boot ROM would pick. The
+	// SGB colourizes any cartridge, the enhanced ones send their own colours
+	switch n.Model.Resolve(header) {
+	case ModelSGB:
+		n.SGB = sgb.New(n.PPU, n.Joypad)
+	case ModelCGB:
+		n.APU.EnableCGB()
+
+		if header.IsCGB() {
+			n.Cpu.Memory.EnableCGB()
+			n.PPU.EnableCGB()
+			n.IR.EnableCGB()
+		} else {
+			n.Cpu.Memory.EnableCGBHardware()
+			n.PPU.EnableCompatibility(compatibilityCombination(header))
+		}
+	}
+
+	n.applyPalette()
+
+	return nil
+}
+
+// LoadFiles creates a Game Boy of the given model with the ROM file and the
+// optional boot ROM file
+func LoadFiles(bootRom string, rom string, model Model) (*GB, error) {
+	machine := New()
+	machine.Model = model
+
+	if bootRom != "" {
+		data, err := ReadFileBytes(bootRom)
+		if err != nil {
+			return nil, err
+		}
+		if err := machine.LoadBootROM(data); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := ReadFileBytes(rom)
+	if err != nil {
+		return nil, err
+	}
+	if err := machine.LoadROM(data); err != nil {
+		return nil, err
+	}
+
+	return machine, nil
+}
+
+// SetPalette selects the colours used to display the four DMG shades of the
+// games without a per-game palette. In the CGB compatibility mode the
+// colours of the boot ROM are shown instead
+func (n *GB) SetPalette(palette ppu.DMGPalette) {
+	n.palette = palette
+	n.applyPalette()
+}
+
+// Palette returns the palette selected with SetPalette
+func (n *GB) Palette() ppu.DMGPalette {
+	return n.palette
+}
+
+// SetPaletteOverrides replaces the per-game palettes, the palette of the
+// loaded game is selected again
+func (n *GB) SetPaletteOverrides(overrides *ppu.PaletteOverrides) {
+	n.PaletteOverrides = overrides
+	n.applyPalette()
+}
+
+// applyPalette picks the colours of the DMG shades: the per-game palette,
+// then the compatibility colours and then the palette of SetPalette
+func (n *GB) applyPalette() {
+	n.PPU.Palette = n.palette
+	if n.Header == nil {
+		return
+	}
+
+	override, exists := n.PaletteOverrides.Lookup(n.Header.Title, n.Header.HeaderChecksum, n.Header.GlobalChecksum)
+	if exists {
+		n.PPU.Palette = override
+	}
+	n.PPU.Compatibility = n.compatibilityMode() && !exists
+}
+
+// compatibilityMode reports whether a CGB runs an original Game Boy game
+func (n *GB) compatibilityMode() bool {
+	return n.Model.Resolve(n.Header) == ModelCGB && !n.Header.IsCGB()
+}
+
+// Step advances the NES emulation by one CPU instruction
+func (n *GB) Step() error {
+	// Execute one CPU instruction
+	cycles, err := n.Cpu.Step()
+	if err != nil {
+		return err
+	}
+
+	n.advance(int(cycles))
+
+	// the CPU is halted during VRAM DMA transfers while the rest keeps going,
+	// which may start another HBlank transfer
+	for stall := n.PPU.HDMA.TakeStall(); stall > 0; stall = n.PPU.HDMA.TakeStall() {
+		n.advance(stall)
+	}
+
+	return nil
+}
+
+// advance moves the hardware around the CPU forward by the given M-cycles.
+// STOP halts the system clock: the PPU, the APU and the timer wait for the
+// button that wakes the CPU, time still passes for the frontend
+func (n *GB) advance(cycles int) {
+	dots := n.dots(cycles)
+	if !n.Cpu.Stopped {
+		n.PPU.Step(dots)
+		n.APU.Step(dots)
+		n.Timer.Step(cycles)
+	}
+	n.Serial.Step(cycles)
+	n.Cycles += uint64(dots)
+}
+
+// wake restarts the clock stopped by STOP when a button is pressed
+func (n *GB) wake() {
+	n.Cpu.Stopped = false
+}
+
+// Idle advances the hardware around the CPU by the given M-cycles without
+// running any instruction
+func (n *GB) Idle(cycles int) {
+	n.advance(cycles)
+}
+
+// dots converts CPU M-cycles to PPU dots. The PPU always runs at 4 MHz, so in
+// CGB double speed mode each M-cycle only lasts 2 dots
+func (n *GB) dots(cycles int) int {
+	if n.Cpu.DoubleSpeed {
+		return cycles * 2
+	}
+	return cycles * 4
+}
+
+// Run runs the NES emulation until stopped
+func (gb *GB) Run() error {
+	gb.Running = true
+
+	for gb.Running {
+		err := gb.Step()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunFor runs the emulation for at least the given number of T-cycles at
+// normal speed, without a frontend
+func (n *GB) RunFor(cycles uint64) error {
+	target := n.Cycles + cycles
+	for n.Cycles < target {
+		if err := n.Step(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunFrame runs the emulation until the PPU completes a frame, without a
+// frontend. With the LCD off no frame is completed and it returns after the
+// time of one frame
+func (n *GB) RunFrame() error {
+	_, err := n.RunFrameUntil(nil)
+	return err
+}
+
+// RunFrameUntil runs a frame like RunFrame, checking the condition after every
+// instruction. It stops as soon as the condition holds and reports it
+func (n *GB) RunFrameUntil(condition func() bool) (bool, error) {
+	// with the LCD on a frame always completes within this time
+	target := n.Cycles + ppu.DotsPerFrame
+	for n.Cycles < target {
+		if err := n.Step(); err != nil {
+			return false, err
+		}
+
+		frame := n.PPU.FrameReady()
+		if condition != nil && condition() {
+			return true, nil
+		}
+		if frame {
+			return false, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Stop stops the NES emulation
+func (gb *GB) Stop() {
+	gb.Running = false
+}
+
+// VGMTag returns the GD3 tag of a VGM log, filled from the cartridge header
+func (n *GB) VGMTag() record.GD3 {
+	tag := record.GD3{System: "Nintendo Game Boy", Creator: "gb-emulator"}
+	if n.APU.CGB {
+		tag.System = "Nintendo Game Boy Color"
+	}
+
+	if n.Header != nil {
+		tag.Track = n.Header.Title
+		tag.Game = n.Header.Title
+	}
+
+	return tag
+}
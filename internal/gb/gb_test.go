@@ -0,0 +1,56 @@
+package gb
+
+import (
+	"testing"
+
+	"gb-emulator/internal/ppu"
+	"gb-emulator/internal/timer"
+)
+
+func TestSTOPFreezesTheHardware(t *testing.T) {
+	machine := New()
+	if err := machine.LoadBootROM([]byte{0x10, 0x00}); err != nil { // STOP
+		t.Fatal(err)
+	}
+	if err := machine.LoadROM(newTestROM(CartridgeROMOnly, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := machine.RunFrame(); err != nil {
+		t.Fatal(err)
+	}
+	if !machine.Cpu.Stopped {
+		t.Fatal("the CPU didn't stop")
+	}
+
+	div := machine.Cpu.Read(timer.DIVAddress)
+	ly := machine.Cpu.Read(ppu.LYAddress)
+	apuCycles := machine.APU.Cycles()
+	cycles := machine.Cycles
+
+	if err := machine.RunFrame(); err != nil {
+		t.Fatal(err)
+	}
+
+	if machine.Cycles == cycles {
+		t.Error("time didn't pass while stopped")
+	}
+	if value := machine.Cpu.Read(timer.DIVAddress); value != div {
+		t.Errorf("DIV %02X while stopped, want %02X", value, div)
+	}
+	if value := machine.Cpu.Read(ppu.LYAddress); value != ly {
+		t.Errorf("LY %d while stopped, want %d", value, ly)
+	}
+	if value := machine.APU.Cycles(); value != apuCycles {
+		t.Errorf("APU at cycle %d while stopped, want %d", value, apuCycles)
+	}
+
+	// a button wakes the CPU and the clock runs again
+	machine.wake()
+	if err := machine.RunFrame(); err != nil {
+		t.Fatal(err)
+	}
+	if value := machine.APU.Cycles(); value == apuCycles {
+		t.Error("the APU didn't run after waking up")
+	}
+}
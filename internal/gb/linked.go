@@ -0,0 +1,89 @@
+package gb
+
+import (
+	"fmt"
+
+	"gb-emulator/internal/infrared"
+	"gb-emulator/internal/link"
+	"gb-emulator/internal/serial"
+)
+
+// Linked runs Game Boys connected through their serial ports in lockstep:
+// the machine that is behind always runs the next instruction, so they are
+// never more than one instruction apart and every run is deterministic
+type Linked struct {
+	Players []*GB
+
+	cable   *link.Cable   // two players
+	Adapter *link.Adapter // three or four players, nil with a cable
+}
+
+// NewLinked connects the serial ports of both machines with a cable
+func NewLinked(first, second *GB) *Linked {
+	return &Linked{
+		Players: []*GB{first, second},
+		cable:   link.Connect(first.Serial, second.Serial),
+	}
+}
+
+// NewFourPlayer connects two to four machines through a DMG-07 adapter, in
+// the order of its ports
+func NewFourPlayer(players ...*GB) (*Linked, error) {
+	if len(players) < 2 || len(players) > link.AdapterPorts {
+		return nil, fmt.Errorf("the DMG-07 adapter takes 2 to %d players, not %d", link.AdapterPorts, len(players))
+	}
+
+	ports := make([]*serial.Serial, len(players))
+	for i, player := range players {
+		ports[i] = player.Serial
+	}
+
+	return &Linked{Players: players, Adapter: link.NewAdapter(ports...)}, nil
+}
+
+// RunFor runs every machine for at least the given number of T-cycles at
+// normal speed. The adapter follows the machine that is behind
+func (l *Linked) RunFor(cycles uint64) error {
+	start := make([]uint64, len(l.Players))
+	for i, player := range l.Players {
+		start[i] = player.Cycles
+	}
+
+	var reached uint64 // T-cycles run by every machine
+	for {
+		behind := 0
+		for i, player := range l.Players {
+			if player.Cycles-start[i] < l.Players[behind].Cycles-start[behind] {
+				behind = i
+			}
+		}
+
+		elapsed := l.Players[behind].Cycles - start[behind]
+		if l.Adapter != nil && elapsed > reached {
+			l.Adapter.Step(int(elapsed - reached))
+		}
+		reached = elapsed
+
+		if elapsed >= cycles {
+			return nil
+		}
+
+		if err := l.Players[behind].Step(); err != nil {
+			return err
+		}
+	}
+}
+
+// ConnectInfrared points the infrared ports of the first two machines at each
+// other, the link keeps them in lockstep so the LED edges are seen in time
+func (l *Linked) ConnectInfrared() {
+	infrared.Connect(l.Players[0].IR, l.Players[1].IR)
+}
+
+// Disconnect unplugs the cable of two linked machines, they keep running on
+// their own
+func (l *Linked) Disconnect() {
+	if l.cable != nil {
+		l.cable.Disconnect()
+	}
+}
@@ -0,0 +1,62 @@
+package gb
+
+import (
+	"fmt"
+	"strings"
+
+	"gb-emulator/internal/ppu"
+)
+
+// Model is the Game Boy hardware being emulated
+type Model int
+
+const (
+	ModelAuto Model = iota // CGB for cartridges that support it, DMG otherwise
+	ModelDMG
+	ModelCGB
+	ModelSGB // DMG hardware with the colours of the Super Game Boy
+)
+
+var modelNames = map[Model]string{
+	ModelAuto: "auto",
+	ModelDMG:  "dmg",
+	ModelCGB:  "cgb",
+	ModelSGB:  "sgb",
+}
+
+func (m Model) String() string {
+	if name, exists := modelNames[m]; exists {
+		return name
+	}
+	return fmt.Sprintf("Model(%d)", int(m))
+}
+
+// ParseModel converts a model name ("auto", "dmg", "cgb", "sgb") to a Model
+func ParseModel(name string) (Model, error) {
+	for model, modelName := range modelNames {
+		if strings.EqualFold(name, modelName) {
+			return model, nil
+		}
+	}
+	return ModelAuto, fmt.Errorf("unknown hardware model %q", name)
+}
+
+// Resolve picks the hardware for the cartridge when the model is automatic
+func (m Model) Resolve(header *CartridgeHeader) Model {
+	if m != ModelAuto {
+		return m
+	}
+	if header.IsCGB() {
+		return ModelCGB
+	}
+	return ModelDMG
+}
+
+// compatibilityCombination picks the palette combination the CGB boot ROM
+// uses for an original Game Boy cartridge
+func compatibilityCombination(header *CartridgeHeader) int {
+	if !header.IsNintendoLicensee() {
+		return 0
+	}
+	return ppu.CompatibilityCombination(header.TitleChecksum(), header.RawTitle[3])
+}
@@ -0,0 +1,43 @@
+package gb
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+
+	"gb-emulator/internal/ppu"
+	"gb-emulator/internal/sgb"
+)
+
+// ScreenSize returns the size of the picture returned by Screen
+func (n *GB) ScreenSize(border bool) (int, int) {
+	if border && n.SGB != nil {
+		return sgb.BorderWidth, sgb.BorderHeight
+	}
+	return ppu.ScreenWidth, ppu.ScreenHeight
+}
+
+// Screen returns the last complete frame. With border set, a Super Game Boy
+// returns the whole 256x224 picture with the border around the game
+func (n *GB) Screen(border bool) *image.RGBA {
+	if border && n.SGB != nil {
+		return n.SGB.Border()
+	}
+	return n.PPU.Frame()
+}
+
+// SaveScreenshot writes the last complete frame as PNG, keeping the SGB
+// border when border is set. It works without a window
+func (n *GB) SaveScreenshot(path string, border bool) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot create the screenshot: %w", err)
+	}
+
+	if err := png.Encode(file, n.Screen(border)); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot write the screenshot: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,141 @@
+package gb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gb-emulator/internal/savestate"
+)
+
+// errNoGame is returned when a state is saved or loaded before LoadROM
+var errNoGame = errors.New("no game loaded")
+
+// SaveState returns a snapshot of the whole machine: CPU, memory, cartridge,
+// PPU, APU, timer, serial port, joypad, infrared port and SGB, with a
+// thumbnail of the screen. The ROM and the settings aren't included
+func (n *GB) SaveState() ([]byte, error) {
+	if n.Header == nil {
+		return nil, errNoGame
+	}
+
+	w := savestate.NewWriter(savestate.Header{
+		HeaderChecksum: n.Header.HeaderChecksum,
+		GlobalChecksum: n.Header.GlobalChecksum,
+		Model:          byte(n.Model.Resolve(n.Header)),
+		Time:           time.Now(),
+	})
+
+	w.Section("GB", func(e *savestate.Encoder) {
+		e.Uint64(n.Cycles)
+	})
+	for _, section := range n.sections() {
+		w.Save(section.tag, section.stater)
+	}
+	w.Thumbnail(n.Screen(true))
+
+	return w.Bytes(), nil
+}
+
+// LoadState restores a snapshot made by SaveState. The state must belong to
+// the loaded game and to the same model. If a section is damaged the machine
+// goes back to the state it had before the call
+func (n *GB) LoadState(data []byte) error {
+	if n.Header == nil {
+		return errNoGame
+	}
+
+	r, err := savestate.NewReader(data)
+	if err != nil {
+		return err
+	}
+
+	if r.Header.HeaderChecksum != n.Header.HeaderChecksum || r.Header.GlobalChecksum != n.Header.GlobalChecksum {
+		return fmt.Errorf("the state belongs to another game (checksum %02X/%04X)", r.Header.HeaderChecksum, r.Header.GlobalChecksum)
+	}
+	if model := n.Model.Resolve(n.Header); Model(r.Header.Model) != model {
+		return fmt.Errorf("the state is for the %s model and the game runs as %s", Model(r.Header.Model), model)
+	}
+
+	backup, err := n.SaveState()
+	if err != nil {
+		return err
+	}
+
+	if err := n.loadSections(r); err != nil {
+		if previous, restoreErr := savestate.NewReader(backup); restoreErr == nil {
+			n.loadSections(previous)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// section is the hardware kept in a section of the save states
+type section struct {
+	tag    string
+	stater savestate.Stater
+}
+
+// sections lists the hardware of the machine with its section tag. The
+// cartridge only has a section when its mapper keeps state
+func (n *GB) sections() []section {
+	sections := []section{
+		{"CPU", n.Cpu},
+		{"MEM", &n.Cpu.Memory},
+		{"PPU", n.PPU},
+		{"APU", n.APU},
+		{"TIMR", n.Timer},
+		{"SERL", n.Serial},
+		{"JOYP", n.Joypad},
+		{"IR", n.IR},
+	}
+	if cartridge, ok := n.Cpu.Memory.Cartridge().(savestate.Stater); ok {
+		sections = append(sections, section{"CART", cartridge})
+	}
+	if n.SGB != nil {
+		sections = append(sections, section{"SGB", n.SGB})
+	}
+	return sections
+}
+
+// loadSections restores every piece of hardware from its section
+func (n *GB) loadSections(r *savestate.Reader) error {
+	if _, err := r.Section("GB", func(d *savestate.Decoder) {
+		n.Cycles = d.Uint64()
+	}); err != nil {
+		return err
+	}
+
+	for _, section := range n.sections() {
+		if err := r.Load(section.tag, section.stater); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SaveStateFile writes a snapshot of the machine to the file
+func (n *GB) SaveStateFile(path string) error {
+	data, err := n.SaveState()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("cannot save the state: %w", err)
+	}
+	return nil
+}
+
+// LoadStateFile restores a snapshot written by SaveStateFile
+func (n *GB) LoadStateFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read the state: %w", err)
+	}
+	return n.LoadState(data)
+}
@@ -0,0 +1,170 @@
+package gb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"gb-emulator/internal/apu"
+	"gb-emulator/internal/memory"
+	"gb-emulator/internal/savestate"
+)
+
+// stateHeaderSize is the magic, version, checksums, model and time of a state
+const stateHeaderSize = 4 + 2 + 1 + 2 + 1 + 8
+
+// newStateMachine returns a CGB running an MBC1 cartridge with RAM. The CPU
+// waits in HALT, the rest of the hardware keeps running
+func newStateMachine(t *testing.T) *GB {
+	t.Helper()
+
+	machine := New()
+	machine.Model = ModelCGB
+	if err := machine.LoadBootROM([]byte{0x76}); err != nil {
+		t.Fatal(err)
+	}
+	if err := machine.LoadROM(newTestROM(CartridgeMBC1RAMBattery, 0x03)); err != nil {
+		t.Fatal(err)
+	}
+	return machine
+}
+
+// mutate changes some state of every kind of hardware
+func mutate(t *testing.T, machine *GB, seed byte) {
+	t.Helper()
+
+	mem := &machine.Cpu.Memory
+	mem.Write(0x0000, 0x0A)
+	mem.Write(0x4000, seed&0x03)
+	mem.Write(0x6000, 1)
+	mem.Write(memory.ExternalRamStartAddress, seed)
+	mem.Write(memory.InternalRamStartAddress, seed)
+	mem.Write(apu.NR52Address, 0x80)
+	mem.Write(apu.NR12Address, 0xF0)
+	mem.Write(apu.NR14Address, 0x80)
+	machine.Cpu.A = seed
+
+	for range 2 {
+		if err := machine.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	machine := newStateMachine(t)
+	mutate(t, machine, 0x11)
+
+	saved, err := machine.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mutate(t, machine, 0x22)
+	changed, err := machine.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(saved[stateHeaderSize:], changed[stateHeaderSize:]) {
+		t.Fatal("the state didn't change")
+	}
+
+	if err := machine.LoadState(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := machine.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the header holds the time the state was saved
+	if !bytes.Equal(saved[stateHeaderSize:], restored[stateHeaderSize:]) {
+		t.Error("the state saved after loading differs from the loaded one")
+	}
+}
+
+func TestStateOfAnotherGame(t *testing.T) {
+	machine := newStateMachine(t)
+	saved, err := machine.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rom := newTestROM(CartridgeMBC1RAMBattery, 0x03)
+	rom[globalChecksumAddress] = 0x12
+	other := New()
+	other.Model = ModelCGB
+	if err := other.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := other.LoadState(saved); err == nil {
+		t.Error("a state of another game was loaded")
+	}
+}
+
+// sectionOffset returns where the data of a section starts in a state and
+// its length
+func sectionOffset(t *testing.T, state []byte, tag string) (int, int) {
+	t.Helper()
+
+	for offset := stateHeaderSize; offset+8 <= len(state); {
+		length := int(binary.LittleEndian.Uint32(state[offset+4:]))
+		if string(state[offset:offset+4]) == fmt.Sprintf("%-4s", tag) {
+			return offset + 8, length
+		}
+		offset += 8 + length
+	}
+	t.Fatalf("no section %q", tag)
+	return 0, 0
+}
+
+func TestDamagedState(t *testing.T) {
+	tests := []struct {
+		name  string
+		tag   string
+		field func(length int) int // offset of the field in the section
+		value byte
+	}{
+		// WorkRamBank, before the undocumented registers
+		{"WRAM bank", "MEM", func(length int) int { return length - 5 }, 8},
+		{"VRAM bank", "MEM", func(length int) int { return length - 8 }, 2},
+		// duty of square 1, after powered, the registers, frameStep, cycles and enabled
+		{"duty", "APU", func(int) int { return 1 + 23 + 8 + 8 + 1 }, 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			machine := newStateMachine(t)
+			mutate(t, machine, 0x11)
+			saved, err := machine.SaveState()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			damaged := bytes.Clone(saved)
+			offset, length := sectionOffset(t, damaged, test.tag)
+			damaged[offset+test.field(length)] = test.value
+
+			mutate(t, machine, 0x22)
+			before, err := machine.SaveState()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := machine.LoadState(damaged); !errors.Is(err, savestate.ErrFormat) {
+				t.Fatalf("error %v, want %v", err, savestate.ErrFormat)
+			}
+
+			after, err := machine.SaveState()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(before[stateHeaderSize:], after[stateHeaderSize:]) {
+				t.Error("the machine wasn't restored after the damaged state")
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package gbs
+
+import (
+	"gb-emulator/internal/memory"
+	"gb-emulator/internal/savestate"
+)
+
+const (
+	bankSelectStart = 0x2000
+	bankSelectEnd   = 0x3FFF
+	externalRamSize = memory.ExternalRamEndAddress - memory.ExternalRamStartAddress + 1
+)
+
+// cartridge maps the GBS data as a ROM. Bank 0 is fixed and writes to
+// 0x2000-0x3FFF select the bank at 0x4000-0x7FFF, like an MBC1
+type cartridge struct {
+	rom  []byte
+	bank int
+	ram  [externalRamSize]byte
+}
+
+func newCartridge(rom []byte) *cartridge {
+	return &cartridge{rom: rom, bank: 1}
+}
+
+func (c *cartridge) banks() int {
+	return len(c.rom) / romBankSize
+}
+
+// ReadIO implements memory.IODevice
+func (c *cartridge) ReadIO(address uint16) byte {
+	switch {
+	case address < romBankSize:
+		return c.rom[address]
+	case address < romAreaLimit:
+		return c.rom[c.bank*romBankSize+int(address-romBankSize)]
+	default:
+		return c.ram[address-memory.ExternalRamStartAddress]
+	}
+}
+
+// WriteIO implements memory.IODevice
+func (c *cartridge) WriteIO(address uint16, value byte) {
+	switch {
+	case address >= bankSelectStart && address <= bankSelectEnd:
+		c.bank = max(int(value), 1) % c.banks()
+		if c.bank == 0 {
+			c.bank = 1
+		}
+	case address >= memory.ExternalRamStartAddress:
+		c.ram[address-memory.ExternalRamStartAddress] = value
+	}
+}
+
+// SaveState implements savestate.Stater, the ROM comes from the file
+func (c *cartridge) SaveState(e *savestate.Encoder) {
+	e.Int(c.bank)
+	e.Bytes(c.ram[:])
+}
+
+// LoadState implements savestate.Stater
+func (c *cartridge) LoadState(d *savestate.Decoder) {
+	if bank := d.Int(); bank > 0 && bank < c.banks() {
+		c.bank = bank
+	}
+	d.Bytes(c.ram[:])
+}
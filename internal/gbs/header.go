@@ -0,0 +1,128 @@
+// Package gbs plays Game Boy Sound System music rips
+package gbs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Documentation
+// * https://ocremix.org/info/GBS_Format_Specification
+
+const (
+	headerSize   = 0x70
+	minLoadAddr  = 0x0400
+	romBankSize  = 0x4000
+	romAreaLimit = 0x8000
+)
+
+// Header is the information block at the start of a GBS file
+type Header struct {
+	Version      byte
+	Songs        int    // number of tracks
+	FirstSong    int    // default track, starting at 1
+	LoadAddress  uint16 // where the data is mapped in the ROM area
+	InitAddress  uint16 // routine called with the track number (from 0) in A
+	PlayAddress  uint16 // routine called at the play rate
+	StackPointer uint16
+	TMA          byte // timer modulo, used when the timer drives play
+	TAC          byte // bit 2: play is driven by the timer instead of VBlank, bit 7: CGB double speed
+	Title        string
+	Author       string
+	Copyright    string
+}
+
+// File is a parsed GBS file
+type File struct {
+	Header
+	Data []byte // code and data, mapped from LoadAddress
+}
+
+// rawHeader mirrors the layout of the header in the file
+type rawHeader struct {
+	Magic        [3]byte
+	Version      byte
+	Songs        byte
+	FirstSong    byte
+	LoadAddress  uint16
+	InitAddress  uint16
+	PlayAddress  uint16
+	StackPointer uint16
+	TMA          byte
+	TAC          byte
+	Title        [32]byte
+	Author       [32]byte
+	Copyright    [32]byte
+}
+
+// Parse reads a GBS file
+func Parse(data []byte) (*File, error) {
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("GBS file too short: %d bytes", len(data))
+	}
+
+	var raw rawHeader
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &raw); err != nil {
+		return nil, err
+	}
+
+	if string(raw.Magic[:]) != "GBS" {
+		return nil, fmt.Errorf("not a GBS file")
+	}
+	if raw.Version != 1 {
+		return nil, fmt.Errorf("unsupported GBS version: %d", raw.Version)
+	}
+	if raw.Songs == 0 {
+		return nil, fmt.Errorf("the GBS file has no tracks")
+	}
+	if raw.LoadAddress < minLoadAddr || raw.LoadAddress >= romAreaLimit {
+		return nil, fmt.Errorf("invalid load address: %04X", raw.LoadAddress)
+	}
+
+	file := &File{
+		Header: Header{
+			Version:      raw.Version,
+			Songs:        int(raw.Songs),
+			FirstSong:    max(int(raw.FirstSong), 1),
+			LoadAddress:  raw.LoadAddress,
+			InitAddress:  raw.InitAddress,
+			PlayAddress:  raw.PlayAddress,
+			StackPointer: raw.StackPointer,
+			TMA:          raw.TMA,
+			TAC:          raw.TAC,
+			Title:        headerString(raw.Title[:]),
+			Author:       headerString(raw.Author[:]),
+			Copyright:    headerString(raw.Copyright[:]),
+		},
+		Data: data[headerSize:],
+	}
+
+	return file, nil
+}
+
+// headerString converts a zero padded text field
+func headerString(field []byte) string {
+	if end := bytes.IndexByte(field, 0); end >= 0 {
+		field = field[:end]
+	}
+	return strings.TrimSpace(string(field))
+}
+
+// UsesTimer reports whether play is driven by the timer interrupt instead of VBlank
+func (h *Header) UsesTimer() bool {
+	return h.TAC&0x04 != 0
+}
+
+// rom returns the ROM image, the data placed at the load address and padded
+// to whole banks
+func (f *File) rom() []byte {
+	size := int(f.LoadAddress) + len(f.Data)
+	size = (size + romBankSize - 1) / romBankSize * romBankSize
+
+	rom := make([]byte, max(size, 2*romBankSize))
+	copy(rom[f.LoadAddress:], f.Data)
+
+	return rom
+}
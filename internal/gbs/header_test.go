@@ -0,0 +1,154 @@
+package gbs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// newTestFile builds a GBS file with the given header and data
+func newTestFile(raw rawHeader, data []byte) []byte {
+	var file bytes.Buffer
+	binary.Write(&file, binary.LittleEndian, raw)
+	file.Write(data)
+	return file.Bytes()
+}
+
+func validHeader() rawHeader {
+	raw := rawHeader{
+		Magic:        [3]byte{'G', 'B', 'S'},
+		Version:      1,
+		Songs:        12,
+		FirstSong:    3,
+		LoadAddress:  0x0470,
+		InitAddress:  0x0480,
+		PlayAddress:  0x0490,
+		StackPointer: 0xDFFF,
+		TMA:          0xC0,
+		TAC:          0x04,
+	}
+	copy(raw.Title[:], "Test Song")
+	copy(raw.Author[:], "Someone  ")
+	copy(raw.Copyright[:], "2026 Nobody")
+	return raw
+}
+
+func TestParse(t *testing.T) {
+	data := []byte{0x3E, 0x01, 0xC9}
+	file, err := Parse(newTestFile(validHeader(), data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Header{
+		Version:      1,
+		Songs:        12,
+		FirstSong:    3,
+		LoadAddress:  0x0470,
+		InitAddress:  0x0480,
+		PlayAddress:  0x0490,
+		StackPointer: 0xDFFF,
+		TMA:          0xC0,
+		TAC:          0x04,
+		Title:        "Test Song",
+		Author:       "Someone",
+		Copyright:    "2026 Nobody",
+	}
+	if !reflect.DeepEqual(file.Header, want) {
+		t.Errorf("header %+v, want %+v", file.Header, want)
+	}
+	if !bytes.Equal(file.Data, data) {
+		t.Errorf("data % X, want % X", file.Data, data)
+	}
+}
+
+func TestParseFirstSongZero(t *testing.T) {
+	raw := validHeader()
+	raw.FirstSong = 0
+
+	file, err := Parse(newTestFile(raw, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.FirstSong != 1 {
+		t.Errorf("first song %d, want 1", file.FirstSong)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(raw *rawHeader)
+	}{
+		{"magic", func(raw *rawHeader) { raw.Magic[2] = 'X' }},
+		{"version", func(raw *rawHeader) { raw.Version = 2 }},
+		{"no songs", func(raw *rawHeader) { raw.Songs = 0 }},
+		{"load address too low", func(raw *rawHeader) { raw.LoadAddress = 0x03FF }},
+		{"load address out of the ROM", func(raw *rawHeader) { raw.LoadAddress = 0x8000 }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw := validHeader()
+			test.modify(&raw)
+			if _, err := Parse(newTestFile(raw, nil)); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+
+	t.Run("short", func(t *testing.T) {
+		if _, err := Parse(newTestFile(validHeader(), nil)[:headerSize-1]); err == nil {
+			t.Error("no error")
+		}
+	})
+}
+
+func TestUsesTimer(t *testing.T) {
+	tests := []struct {
+		tac  byte
+		want bool
+	}{
+		{0x00, false},
+		{0x04, true},
+		{0x07, true},
+		{0x80, false},
+		{0x84, true},
+	}
+
+	for _, test := range tests {
+		header := Header{TAC: test.tac}
+		if got := header.UsesTimer(); got != test.want {
+			t.Errorf("TAC %02X uses timer %v, want %v", test.tac, got, test.want)
+		}
+	}
+}
+
+func TestROM(t *testing.T) {
+	tests := []struct {
+		name        string
+		loadAddress uint16
+		dataSize    int
+		size        int
+	}{
+		{"small", 0x0470, 0x100, 2 * romBankSize},
+		{"three banks", 0x0470, 2*romBankSize - 0x0470 + 1, 3 * romBankSize},
+		{"whole banks", 0x0400, 4*romBankSize - 0x0400, 4 * romBankSize},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := bytes.Repeat([]byte{0xAA}, test.dataSize)
+			file := &File{Header: Header{LoadAddress: test.loadAddress}, Data: data}
+
+			rom := file.rom()
+			if len(rom) != test.size {
+				t.Fatalf("ROM size %X, want %X", len(rom), test.size)
+			}
+			if rom[test.loadAddress-1] != 0 || rom[test.loadAddress] != 0xAA {
+				t.Errorf("data not placed at %04X", test.loadAddress)
+			}
+		})
+	}
+}
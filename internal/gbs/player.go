@@ -0,0 +1,171 @@
+package gbs
+
+import (
+	"fmt"
+
+	"gb-emulator/internal/apu"
+	"gb-emulator/internal/gb"
+	gbmemory "gb-emulator/internal/memory"
+	"gb-emulator/internal/ppu"
+	"gb-emulator/internal/timer"
+)
+
+const (
+	// idleAddress is the return address pushed before calling init or play,
+	// the routine has finished when the CPU reaches it
+	idleAddress = 0xF00D
+
+	// maxInitCycles bounds the init routine, some rips never return from it
+	maxInitCycles = 10 * apu.ClockFrequency
+
+	tacDoubleSpeed = 0x80
+)
+
+// Player runs a GBS file on the emulated CPU and APU
+type Player struct {
+	File *File
+	GB   *gb.GB
+
+	track     int  // current track, starting at 1
+	untilPlay int  // T-cycles until the next VBlank play call
+	busy      bool // init or play is running
+	start     uint64
+}
+
+// NewPlayer creates a player for the file, Start selects the track to play
+func NewPlayer(file *File) *Player {
+	return &Player{File: file}
+}
+
+// Start resets the machine and runs the init routine of a track (from 1 to Songs)
+func (p *Player) Start(track int) error {
+	if track < 1 || track > p.File.Songs {
+		return fmt.Errorf("track %d out of range (1-%d)", track, p.File.Songs)
+	}
+
+	machine := gb.New()
+	cpu := machine.Cpu
+	cpu.Boot = false
+	cpu.MapCartridge(newCartridge(p.File.rom()))
+
+	// the APU is left powered with every channel on both sides, like after the boot ROM
+	machine.APU.WriteIO(apu.NR52Address, 0x80)
+	machine.APU.WriteIO(apu.NR51Address, 0xFF)
+	machine.APU.WriteIO(apu.NR50Address, 0x77)
+
+	if previous := p.GB; previous != nil {
+		machine.APU.SetQuality(previous.APU.Quality())
+	}
+
+	// the timer runs with the rip settings, its interrupt is handled by the
+	// player instead of the CPU
+	cpu.Write(timer.TMAAddress, p.File.TMA)
+	cpu.Write(timer.TACAddress, p.File.TAC)
+	cpu.DoubleSpeed = p.File.TAC&tacDoubleSpeed != 0
+
+	p.GB = machine
+	p.track = track
+	p.untilPlay = ppu.DotsPerFrame
+	p.start = machine.Cycles
+
+	cpu.SP = p.File.StackPointer
+	cpu.A = byte(track - 1)
+	p.call(p.File.InitAddress)
+
+	for p.busy {
+		if machine.Cycles-p.start > maxInitCycles {
+			return fmt.Errorf("the init routine of track %d doesn't return", track)
+		}
+		if err := p.step(); err != nil {
+			return err
+		}
+	}
+
+	p.start = machine.Cycles
+	return nil
+}
+
+// call starts a routine, it returns to idleAddress
+func (p *Player) call(address uint16) {
+	cpu := p.GB.Cpu
+	cpu.IME = false
+	cpu.PushWord(idleAddress)
+	cpu.PC = address
+	p.busy = true
+}
+
+// step runs one instruction, or lets the rest of the hardware run for one
+// M-cycle while no routine is running
+func (p *Player) step() error {
+	if !p.busy {
+		p.GB.Idle(1)
+		return nil
+	}
+
+	if err := p.GB.Step(); err != nil {
+		return err
+	}
+
+	if p.GB.Cpu.PC == idleAddress {
+		p.busy = false
+	}
+
+	return nil
+}
+
+// Run plays the track for the given number of T-cycles, calling play at the
+// VBlank rate or on the timer interrupt. A play call still running when the
+// next one is due delays it
+func (p *Player) Run(cycles uint64) error {
+	if p.GB == nil {
+		return fmt.Errorf("no track started")
+	}
+
+	target := p.GB.Cycles + cycles
+	for p.GB.Cycles < target {
+		before := p.GB.Cycles
+		if err := p.step(); err != nil {
+			return err
+		}
+
+		if p.playDue(int(p.GB.Cycles-before)) && !p.busy {
+			p.call(p.File.PlayAddress)
+		}
+	}
+
+	return nil
+}
+
+// playDue reports whether play must be called. The LCD is off while a rip
+// plays, so VBlank is counted in T-cycles; the timer interrupt stays pending
+// until play is called
+func (p *Player) playDue(elapsed int) bool {
+	if p.File.UsesTimer() {
+		memory := &p.GB.Cpu.Memory
+		if p.busy || memory.Read(gbmemory.IFAddress)&gbmemory.InterruptTimer == 0 {
+			return false
+		}
+		memory.ClearInterrupt(gbmemory.InterruptTimer)
+		return true
+	}
+
+	p.untilPlay -= elapsed
+	if p.untilPlay > 0 || p.busy {
+		return false
+	}
+	p.untilPlay += ppu.DotsPerFrame
+	return true
+}
+
+// Track returns the track being played, starting at 1
+func (p *Player) Track() int {
+	return p.track
+}
+
+// Elapsed returns the T-cycles played since the track started
+func (p *Player) Elapsed() uint64 {
+	if p.GB == nil {
+		return 0
+	}
+	return p.GB.Cycles - p.start
+}
@@ -0,0 +1,144 @@
+// Package headless runs a Game Boy without a window or audio output, as fast
+// as the host allows, for continuous integration and scripts
+package headless
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gb-emulator/internal/apu"
+	"gb-emulator/internal/gb"
+)
+
+// Condition ends a run when it holds, it is checked after every instruction
+type Condition func(r *Runner) bool
+
+// PCEquals holds when the next instruction to run is at the address
+func PCEquals(address uint16) Condition {
+	return func(r *Runner) bool {
+		return r.GB.Cpu.PC == address
+	}
+}
+
+// MemoryEquals holds when the byte at the address has the value
+func MemoryEquals(address uint16, value byte) Condition {
+	return func(r *Runner) bool {
+		return r.GB.Cpu.Memory.Read(address) == value
+	}
+}
+
+// SerialContains holds once the bytes sent through the serial port contain
+// the text, test ROMs print their results there
+func SerialContains(text string) Condition {
+	checked := 0
+	return func(r *Runner) bool {
+		// the output only grows, it is searched again when a byte arrives
+		if r.serial.Len() == checked {
+			return false
+		}
+		checked = r.serial.Len()
+		return strings.Contains(r.serial.String(), text)
+	}
+}
+
+// Result describes how a run ended
+type Result struct {
+	Frames  int    // frames completed
+	Met     bool   // a condition held before the frame limit
+	Cycles  uint64 // T-cycles at normal speed emulated
+	Elapsed time.Duration
+}
+
+// Emulated returns the time the run lasts on the real hardware
+func (r Result) Emulated() time.Duration {
+	return time.Duration(float64(r.Cycles) / apu.ClockFrequency * float64(time.Second))
+}
+
+// Speed returns how many times faster than real time the machine ran
+func (r Result) Speed() float64 {
+	return r.Emulated().Seconds() / r.Elapsed.Seconds()
+}
+
+// Runner runs a machine frame by frame until one of the conditions holds or
+// the frame limit is reached
+type Runner struct {
+	GB         *gb.GB
+	MaxFrames  int
+	Conditions []Condition
+
+	serial bytes.Buffer
+}
+
+// New creates a runner for the machine. The serial output is captured for
+// SerialContains and still reaches the writer set before
+func New(machine *gb.GB, maxFrames int) *Runner {
+	r := &Runner{GB: machine, MaxFrames: maxFrames}
+
+	if machine.Serial.Output != nil {
+		machine.Serial.Output = io.MultiWriter(&r.serial, machine.Serial.Output)
+	} else {
+		machine.Serial.Output = &r.serial
+	}
+
+	return r
+}
+
+// Serial returns the bytes sent through the serial port so far
+func (r *Runner) Serial() string {
+	return r.serial.String()
+}
+
+// Run emulates without waiting between frames. Without conditions it always
+// runs MaxFrames frames. A panic of the emulation is returned as an error, so
+// scripts get an exit code instead of a crash
+func (r *Runner) Run() (result Result, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("the emulation stopped: %v", p)
+		}
+	}()
+
+	var check func() bool
+	if len(r.Conditions) > 0 {
+		check = r.check
+	}
+
+	start, cycles := time.Now(), r.GB.Cycles
+
+	for result.Frames < r.MaxFrames {
+		result.Met, err = r.GB.RunFrameUntil(check)
+		if err != nil || result.Met {
+			break
+		}
+		result.Frames++
+
+		// nobody plays the audio, the samples are dropped every frame
+		r.GB.APU.TakeSamples()
+	}
+
+	result.Cycles = r.GB.Cycles - cycles
+	result.Elapsed = time.Since(start)
+	return result, err
+}
+
+// check reports whether any condition holds
+func (r *Runner) check() bool {
+	for _, condition := range r.Conditions {
+		if condition(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// FrameHash returns the SHA-256 of the pixels of the last complete frame, in
+// hexadecimal. With border set the SGB border is included
+func FrameHash(machine *gb.GB, border bool) string {
+	sum := sha256.Sum256(machine.Screen(border).Pix)
+	return hex.EncodeToString(sum[:])
+}
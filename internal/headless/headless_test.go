@@ -0,0 +1,146 @@
+package headless
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gb-emulator/internal/apu"
+	"gb-emulator/internal/gb"
+)
+
+// testProgram sends 'K' through the serial port, writes 5A to C000 and halts
+var testProgram = []byte{
+	0x3E, 'K', // LD A,'K'
+	0xE0, 0x01, // LDH (SB),A
+	0x3E, 0x81, // LD A,81
+	0xE0, 0x02, // LDH (SC),A
+	0x21, 0x00, 0xC0, // LD HL,C000
+	0x3E, 0x5A, // LD A,5A
+	0x77, // LD (HL),A
+	0x76, // HALT
+}
+
+const haltAddress = 0x000E
+
+func newTestMachine(t *testing.T, program []byte) *gb.GB {
+	t.Helper()
+
+	machine := gb.New()
+	if err := machine.LoadBootROM(program); err != nil {
+		t.Fatal(err)
+	}
+	if err := machine.LoadROM(make([]byte, 0x8000)); err != nil {
+		t.Fatal(err)
+	}
+	return machine
+}
+
+func TestConditions(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		met       bool
+		frames    int
+	}{
+		{"PC", PCEquals(haltAddress), true, 0},
+		{"memory", MemoryEquals(0xC000, 0x5A), true, 0},
+		{"serial", SerialContains("K"), true, 0},
+		{"never", MemoryEquals(0xC000, 0x11), false, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := New(newTestMachine(t, testProgram), 3)
+			r.Conditions = []Condition{test.condition}
+
+			result, err := r.Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Met != test.met || result.Frames != test.frames {
+				t.Errorf("met %v after %d frames, want %v after %d", result.Met, result.Frames, test.met, test.frames)
+			}
+			if result.Cycles == 0 {
+				t.Error("no cycles emulated")
+			}
+		})
+	}
+}
+
+func TestNoConditions(t *testing.T) {
+	r := New(newTestMachine(t, testProgram), 2)
+
+	result, err := r.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Met || result.Frames != 2 {
+		t.Errorf("met %v after %d frames, want the 2 frames", result.Met, result.Frames)
+	}
+}
+
+func TestSerialOutput(t *testing.T) {
+	machine := newTestMachine(t, testProgram)
+	var output bytes.Buffer
+	machine.Serial.Output = &output
+
+	r := New(machine, 1)
+	if _, err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the writer set before the runner still gets the bytes
+	if r.Serial() != "K" || output.String() != "K" {
+		t.Errorf("serial %q and output %q, want \"K\"", r.Serial(), output.String())
+	}
+}
+
+func TestEmulationError(t *testing.T) {
+	r := New(newTestMachine(t, []byte{0xD3}), 1) // not an instruction
+
+	if _, err := r.Run(); err == nil {
+		t.Error("no error")
+	}
+}
+
+func TestFrameHash(t *testing.T) {
+	hashes := make([]string, 2)
+	for i := range hashes {
+		machine := newTestMachine(t, testProgram)
+		if _, err := New(machine, 2).Run(); err != nil {
+			t.Fatal(err)
+		}
+		hashes[i] = FrameHash(machine, false)
+	}
+
+	if len(hashes[0]) != 64 {
+		t.Errorf("hash %q, want 64 hexadecimal digits", hashes[0])
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("hashes %s and %s of the same run differ", hashes[0], hashes[1])
+	}
+}
+
+func TestResultSpeed(t *testing.T) {
+	tests := []struct {
+		cycles   uint64
+		elapsed  time.Duration
+		emulated time.Duration
+		speed    float64
+	}{
+		{apu.ClockFrequency, time.Second, time.Second, 1},
+		{apu.ClockFrequency * 10, time.Second, 10 * time.Second, 10},
+		{apu.ClockFrequency / 2, 2 * time.Second, time.Second / 2, 0.25},
+	}
+
+	for _, test := range tests {
+		result := Result{Cycles: test.cycles, Elapsed: test.elapsed}
+		if got := result.Emulated(); got != test.emulated {
+			t.Errorf("%d cycles emulated %v, want %v", test.cycles, got, test.emulated)
+		}
+		if got := result.Speed(); got != test.speed {
+			t.Errorf("%d cycles in %v speed %v, want %v", test.cycles, test.elapsed, got, test.speed)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Package infrared implements the CGB infrared communication port
+package infrared
+
+import "gb-emulator/internal/memory"
+
+// Documentation
+// * https://gbdev.io/pandocs/CGB_Registers.html#ff56--rp-cgb-mode-only-infrared-communications-port
+
+const (
+	RPAddress = 0xFF56
+
+	rpLED        byte = 1 << 0 // write, the LED is on
+	rpNoLight    byte = 1 << 1 // read, 0 while receiving light
+	rpReadEnable byte = 0xC0   // both bits set to read the sensor
+	rpUnused     byte = 0x3C
+)
+
+// Peer is what the sensor of a port sees: another console, a mirror or
+// nothing. Times are T-cycles at normal speed, the same clock for both sides
+type Peer interface {
+	// SetLED is called when the LED of the port turns on or off
+	SetLED(on bool, time uint64)
+
+	// Light reports whether light reaches the sensor at the given time
+	Light(time uint64) bool
+}
+
+// Port holds the RP register
+type Port struct {
+	mem *memory.Memory
+
+	led        bool
+	readEnable bool
+	peer       Peer
+
+	// Clock returns the current time, it stamps the LED edges
+	Clock func() uint64
+}
+
+// New creates the port, EnableCGB maps its register
+func New(mem *memory.Memory) *Port {
+	return &Port{mem: mem}
+}
+
+// EnableCGB maps RP, it only exists in CGB mode
+func (p *Port) EnableCGB() {
+	p.mem.MapIO(RPAddress, RPAddress, p)
+}
+
+// Connect puts a peer in front of the port, nil leaves nothing in front of
+// it and the sensor never receives light
+func (p *Port) Connect(peer Peer) {
+	p.peer = peer
+}
+
+// LED reports whether the LED is on
+func (p *Port) LED() bool {
+	return p.led
+}
+
+func (p *Port) now() uint64 {
+	if p.Clock == nil {
+		return 0
+	}
+	return p.Clock()
+}
+
+// receiving reports whether the sensor sees light
+func (p *Port) receiving() bool {
+	return p.readEnable && p.peer != nil && p.peer.Light(p.now())
+}
+
+// ReadIO implements memory.IODevice
+func (p *Port) ReadIO(address uint16) byte {
+	value := rpUnused | rpNoLight
+	if p.led {
+		value |= rpLED
+	}
+	if p.readEnable {
+		value |= rpReadEnable
+	}
+	if p.receiving() {
+		value &^= rpNoLight
+	}
+	return value
+}
+
+// WriteIO implements memory.IODevice
+func (p *Port) WriteIO(address uint16, value byte) {
+	p.readEnable = value&rpReadEnable == rpReadEnable
+
+	led := value&rpLED != 0
+	if led != p.led {
+		p.led = led
+		if p.peer != nil {
+			p.peer.SetLED(led, p.now())
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package infrared
+
+// Edge is a change of a LED at a given time
+type Edge struct {
+	Time uint64
+	On   bool
+}
+
+// Loopback is a mirror in front of the port, the sensor sees its own LED
+type Loopback struct {
+	on bool
+}
+
+// SetLED implements Peer
+func (l *Loopback) SetLED(on bool, time uint64) {
+	l.on = on
+}
+
+// Light implements Peer
+func (l *Loopback) Light(time uint64) bool {
+	return l.on
+}
+
+// Connect points two ports at each other, every one sees the LED of the
+// other. The edges are applied when the receiver reaches their time, so
+// both machines must share their clock and run in lockstep
+func Connect(a, b *Port) {
+	toA, toB := &beam{receiver: a.now}, &beam{receiver: b.now}
+	a.Connect(&pairEnd{out: toB, in: toA})
+	b.Connect(&pairEnd{out: toA, in: toB})
+}
+
+// beam carries the LED edges of one side to the sensor of the other
+type beam struct {
+	edges    []Edge // not yet seen by the receiver
+	on       bool   // state seen by the receiver
+	receiver func() uint64
+}
+
+// light applies the edges up to the given time and returns the state
+func (b *beam) light(time uint64) bool {
+	for len(b.edges) > 0 && b.edges[0].Time <= time {
+		b.on = b.edges[0].On
+		b.edges = b.edges[1:]
+	}
+	return b.on
+}
+
+// pairEnd is the Peer seen by one of two connected ports
+type pairEnd struct {
+	out *beam // LED of this side
+	in  *beam // LED of the other side
+}
+
+// SetLED implements Peer. The edges the receiver already reached are applied
+// first, so they don't pile up while its game doesn't read the sensor
+func (e *pairEnd) SetLED(on bool, time uint64) {
+	e.out.light(e.out.receiver())
+	e.out.edges = append(e.out.edges, Edge{Time: time, On: on})
+}
+
+// Light implements Peer
+func (e *pairEnd) Light(time uint64) bool {
+	return e.in.light(time)
+}
@@ -0,0 +1,55 @@
+package infrared
+
+import (
+	"testing"
+
+	"gb-emulator/internal/memory"
+)
+
+// newConnectedPorts returns two connected CGB ports sharing the clock
+func newConnectedPorts(clock *uint64) (*Port, *Port) {
+	a, b := New(&memory.Memory{}), New(&memory.Memory{})
+	a.Clock = func() uint64 { return *clock }
+	b.Clock = a.Clock
+	Connect(a, b)
+	return a, b
+}
+
+func TestConnectedPortsSeeEachOther(t *testing.T) {
+	var clock uint64
+	a, b := newConnectedPorts(&clock)
+	b.WriteIO(RPAddress, rpReadEnable)
+
+	a.WriteIO(RPAddress, rpLED)
+	if b.ReadIO(RPAddress)&rpNoLight != 0 {
+		t.Error("the LED of A doesn't reach B")
+	}
+
+	a.WriteIO(RPAddress, 0)
+	if b.ReadIO(RPAddress)&rpNoLight == 0 {
+		t.Error("B still sees light with the LED of A off")
+	}
+
+	// the sensor only reads with both read enable bits set
+	a.WriteIO(RPAddress, rpLED)
+	b.WriteIO(RPAddress, 0x80)
+	if b.ReadIO(RPAddress)&rpNoLight == 0 {
+		t.Error("B sees light with reading disabled")
+	}
+}
+
+func TestEdgesDontPileUp(t *testing.T) {
+	var clock uint64
+	a, _ := newConnectedPorts(&clock)
+
+	// the game of B never reads its sensor
+	for i := range 10000 {
+		clock += 100
+		a.WriteIO(RPAddress, byte(i)&rpLED)
+	}
+
+	toB := a.peer.(*pairEnd).out
+	if len(toB.edges) > 1 {
+		t.Errorf("%d edges kept, want at most 1", len(toB.edges))
+	}
+}
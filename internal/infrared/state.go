@@ -0,0 +1,16 @@
+package infrared
+
+import "gb-emulator/internal/savestate"
+
+// SaveState writes the LED and the sensor enable. The peer isn't part of the
+// state
+func (p *Port) SaveState(e *savestate.Encoder) {
+	e.Bool(p.led)
+	e.Bool(p.readEnable)
+}
+
+// LoadState restores the state written by SaveState
+func (p *Port) LoadState(d *savestate.Decoder) {
+	p.led = d.Bool()
+	p.readEnable = d.Bool()
+}
@@ -0,0 +1,231 @@
+// Package joypad implements the Game Boy P1/JOYP register
+package joypad
+
+import (
+	"fmt"
+	"strings"
+
+	"gb-emulator/internal/memory"
+)
+
+// Documentation
+// * https://gbdev.io/pandocs/Joypad_Input.html
+// * https://gbdev.io/pandocs/Interrupt_Sources.html#int-60--joypad-interrupt
+
+const (
+	P1Address = 0xFF00
+
+	selectDirections byte = 1 << 4 // P14, low selects the direction keys
+	selectActions    byte = 1 << 5 // P15, low selects the action keys
+	selectMask            = selectDirections | selectActions
+	unusedBits       byte = 0xC0
+	lineMask         byte = 0x0F
+
+	// MaxPlayers is the number of controllers of the SGB multiplayer mode
+	MaxPlayers = 4
+)
+
+// Button is a bit mask of Game Boy buttons. The low nibble holds the
+// direction keys and the high nibble the action keys, in P1 line order
+type Button byte
+
+const (
+	ButtonRight Button = 1 << iota
+	ButtonLeft
+	ButtonUp
+	ButtonDown
+	ButtonA
+	ButtonB
+	ButtonSelect
+	ButtonStart
+)
+
+var buttonNames = []struct {
+	button Button
+	name   string
+}{
+	{ButtonRight, "right"},
+	{ButtonLeft, "left"},
+	{ButtonUp, "up"},
+	{ButtonDown, "down"},
+	{ButtonA, "a"},
+	{ButtonB, "b"},
+	{ButtonSelect, "select"},
+	{ButtonStart, "start"},
+}
+
+// Buttons lists every button in P1 line order
+func Buttons() []Button {
+	buttons := make([]Button, len(buttonNames))
+	for i, entry := range buttonNames {
+		buttons[i] = entry.button
+	}
+	return buttons
+}
+
+// String returns the names of the buttons in the mask separated by "+"
+func (b Button) String() string {
+	var names []string
+	for _, entry := range buttonNames {
+		if b&entry.button != 0 {
+			names = append(names, entry.name)
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+// ParseButton returns the button with the given name (right, left, up, down,
+// a, b, select, start)
+func ParseButton(name string) (Button, error) {
+	for _, entry := range buttonNames {
+		if strings.EqualFold(name, entry.name) {
+			return entry.button, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown button: %q", name)
+}
+
+// Joypad holds the pressed buttons and the P1 line selection
+type Joypad struct {
+	mem *memory.Memory
+
+	buttons   [MaxPlayers]Button // pressed buttons of every controller, before filtering
+	selection byte               // P14/P15 as written, low selects a group
+	players   int                // controllers read by the game, more than one on SGB
+	current   int                // controller seen on P10-P13
+
+	// AllowOppositeDirections lets left+right and up+down reach the game. They
+	// can't be pressed together on a real d-pad and some games glitch with them
+	AllowOppositeDirections bool
+
+	// OnPress is called when a selected line goes low, the same signal that
+	// ends STOP mode
+	OnPress func()
+
+	// OnWrite is called after every write of P1 with the P14/P15 selection,
+	// the SGB receives its command packets this way
+	OnWrite func(selection byte)
+}
+
+// New creates the joypad and maps P1
+func New(mem *memory.Memory) *Joypad {
+	j := &Joypad{mem: mem, selection: selectMask, players: 1}
+	mem.MapIO(P1Address, P1Address, j)
+	return j
+}
+
+// Pressed returns the pressed buttons
+func (j *Joypad) Pressed() Button {
+	return j.buttons[0]
+}
+
+// SetButtons replaces the pressed buttons
+func (j *Joypad) SetButtons(buttons Button) {
+	j.SetPlayerButtons(0, buttons)
+}
+
+// Press presses the given buttons, the rest keep their state
+func (j *Joypad) Press(buttons Button) {
+	j.SetButtons(j.buttons[0] | buttons)
+}
+
+// Release releases the given buttons, the rest keep their state
+func (j *Joypad) Release(buttons Button) {
+	j.SetButtons(j.buttons[0] &^ buttons)
+}
+
+// SetPlayerButtons replaces the pressed buttons of a controller, 0 to
+// MaxPlayers-1, other players are ignored. Only the first one is read unless
+// SetPlayers enables more
+func (j *Joypad) SetPlayerButtons(player int, buttons Button) {
+	if player < 0 || player >= MaxPlayers {
+		return
+	}
+	j.update(func() { j.buttons[player] = buttons })
+}
+
+// Players returns the number of controllers read by the game
+func (j *Joypad) Players() int {
+	return j.players
+}
+
+// SetPlayers sets the number of controllers read by the game, as the SGB
+// MLT_REQ command does. The first controller is selected again
+func (j *Joypad) SetPlayers(players int) {
+	j.update(func() {
+		j.players = max(1, min(MaxPlayers, players))
+		j.current = 0
+	})
+}
+
+// effective returns the buttons of the current controller seen by the game
+func (j *Joypad) effective() Button {
+	buttons := j.buttons[j.current]
+	if j.AllowOppositeDirections {
+		return buttons
+	}
+
+	if buttons&(ButtonLeft|ButtonRight) == ButtonLeft|ButtonRight {
+		buttons &^= ButtonLeft | ButtonRight
+	}
+	if buttons&(ButtonUp|ButtonDown) == ButtonUp|ButtonDown {
+		buttons &^= ButtonUp | ButtonDown
+	}
+	return buttons
+}
+
+// lines returns P10-P13, low when a button of a selected group is pressed.
+// With both groups selected the lines are ANDed. With no group selected the
+// SGB returns the current controller, 0xF for the first one
+func (j *Joypad) lines() byte {
+	if j.selection == selectMask {
+		return lineMask - byte(j.current)
+	}
+
+	buttons := j.effective()
+	lines := lineMask
+
+	if j.selection&selectDirections == 0 {
+		lines &^= byte(buttons) & lineMask
+	}
+	if j.selection&selectActions == 0 {
+		lines &^= byte(buttons>>4) & lineMask
+	}
+	return lines
+}
+
+// update applies a change of the buttons or the selection and requests the
+// joypad interrupt when one of the lines goes from high to low
+func (j *Joypad) update(change func()) {
+	previous := j.lines()
+	change()
+
+	if previous&^j.lines() != 0 {
+		j.mem.RequestInterrupt(memory.InterruptJoypad)
+		if j.OnPress != nil {
+			j.OnPress()
+		}
+	}
+}
+
+// ReadIO implements memory.IODevice
+func (j *Joypad) ReadIO(address uint16) byte {
+	return unusedBits | j.selection | j.lines()
+}
+
+// WriteIO implements memory.IODevice, only the selection bits are writable.
+// In multiplayer mode the next controller is selected when P15 goes high
+func (j *Joypad) WriteIO(address uint16, value byte) {
+	j.update(func() {
+		previous := j.selection
+		j.selection = value & selectMask
+
+		if previous&selectActions == 0 && j.selection&selectActions != 0 {
+			j.current = (j.current + 1) % j.players
+		}
+	})
+
+	if j.OnWrite != nil {
+		j.OnWrite(j.selection)
+	}
+}
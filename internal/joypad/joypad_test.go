@@ -0,0 +1,37 @@
+package joypad
+
+import (
+	"testing"
+
+	"gb-emulator/internal/memory"
+)
+
+func TestSetPlayerButtonsIgnoresOtherPlayers(t *testing.T) {
+	j := New(memory.New())
+	j.SetPlayers(MaxPlayers)
+
+	for _, player := range []int{-1, MaxPlayers, MaxPlayers + 1} {
+		j.SetPlayerButtons(player, ButtonA)
+	}
+
+	if j.buttons != [MaxPlayers]Button{} {
+		t.Errorf("buttons %v, want none pressed", j.buttons)
+	}
+}
+
+func TestMultiplayerReadsEveryController(t *testing.T) {
+	j := New(memory.New())
+	j.SetPlayers(MaxPlayers)
+	for player := range MaxPlayers {
+		j.SetPlayerButtons(player, ButtonA<<player)
+	}
+
+	for player := range MaxPlayers {
+		j.WriteIO(P1Address, selectDirections)
+		if lines := j.ReadIO(P1Address) & 0x0F; lines != 0x0F&^byte(1<<player) {
+			t.Errorf("player %d: lines %04b, want button %d low", player+1, lines, player)
+		}
+		// P15 going high selects the next controller
+		j.WriteIO(P1Address, selectMask)
+	}
+}
@@ -0,0 +1,18 @@
+package joypad
+
+import "gb-emulator/internal/savestate"
+
+// SaveState writes the selection and the multiplayer state. The buttons come
+// from the player and aren't saved
+func (j *Joypad) SaveState(e *savestate.Encoder) {
+	e.Uint8(j.selection)
+	e.Int(j.players)
+	e.Int(j.current)
+}
+
+// LoadState restores the state written by SaveState
+func (j *Joypad) LoadState(d *savestate.Decoder) {
+	j.selection = d.Uint8()
+	j.players = d.IntRange(1, MaxPlayers)
+	j.current = d.IntRange(0, MaxPlayers-1)
+}
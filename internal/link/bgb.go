@@ -0,0 +1,433 @@
+// Package link connects the serial port of the emulator to other Game Boys
+package link
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gb-emulator/internal/serial"
+)
+
+// Documentation
+// * https://bgb.bircd.org/bgblink.html
+
+const (
+	// DefaultPort is the port BGB listens on
+	DefaultPort = 8765
+
+	packetSize = 8
+
+	// commands
+	bgbVersion    = 1
+	bgbJoypad     = 101
+	bgbSync1      = 104 // data sent by the side using its internal clock
+	bgbSync2      = 105 // answer of the side using the external clock
+	bgbSync3      = 106 // timestamp update, or answer when no transfer is waiting
+	bgbStatus     = 108
+	bgbDisconnect = 109
+
+	versionMajor = 1
+	versionMinor = 4
+
+	statusRunning = 1 << 0
+	statusPaused  = 1 << 1
+
+	sync3Timestamp = 0 // b2 of a timestamp update
+	sync3NoData    = 1 // b2 of the answer to sync1 without a transfer waiting
+
+	sync1Control = 0x81 // SC of a transfer using the internal clock
+	sync2Control = 0x80 // SC of a transfer using the external clock
+
+	// timestamps count at 2 MHz, half the T-cycle clock, in 31 bits
+	timestampMask = 0x7FFFFFFF
+
+	// syncInterval is the number of timestamp ticks between two sync3 updates,
+	// maxLead how far this side may run ahead of the partner
+	syncInterval = 1 << 21 / 256
+	maxLead      = 1 << 21 / 32
+
+	// waitTimeout bounds every wait for the partner, the emulation keeps
+	// running unsynchronized when it expires
+	waitTimeout = time.Second
+)
+
+// packet is the 8-byte unit of the BGB protocol
+type packet struct {
+	command   byte
+	b2        byte
+	b3        byte
+	b4        byte
+	timestamp uint32
+}
+
+func (p packet) encode() []byte {
+	data := []byte{p.command, p.b2, p.b3, p.b4, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(data[4:], p.timestamp)
+	return data
+}
+
+func decodePacket(data []byte) packet {
+	return packet{
+		command:   data[0],
+		b2:        data[1],
+		b3:        data[2],
+		b4:        data[3],
+		timestamp: binary.LittleEndian.Uint32(data[4:]),
+	}
+}
+
+// BGB is a link cable to another emulator over TCP using the BGB protocol
+// 1.4. It works as a serial.ClockedPeer: transfers using the internal clock
+// are sent to the partner, and the partner transfers are applied when the
+// local time reaches their timestamp
+type BGB struct {
+	conn   net.Conn
+	serial *serial.Serial
+
+	time     uint32 // local timestamp, in 2 MHz ticks
+	fraction int    // odd T-cycle left from the last step
+	lastSync uint32 // timestamp of the last sync3 sent
+
+	remoteTime  atomic.Uint32
+	remoteKnown atomic.Bool // a timestamp was received
+	paused      atomic.Bool
+
+	requests  chan packet // sync1 sent by the partner
+	responses chan packet // sync2 or sync3 answering our sync1
+	late      int         // sync1 whose answer didn't come before waitTimeout
+	pending   []packet    // requests waiting for the local time
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// Listen waits for a partner on the address, acting as the host
+func Listen(address string, s *serial.Serial) (*BGB, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on %s: %w", address, err)
+	}
+	defer listener.Close()
+
+	return accept(listener, s)
+}
+
+// accept waits for the first partner on the listener
+func accept(listener net.Listener, s *serial.Serial) (*BGB, error) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return nil, fmt.Errorf("cannot accept the connection: %w", err)
+	}
+
+	return newBGB(conn, s)
+}
+
+// Dial connects to a host on the address, acting as the client
+func Dial(address string, s *serial.Serial) (*BGB, error) {
+	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to %s: %w", address, err)
+	}
+
+	return newBGB(conn, s)
+}
+
+// newBGB exchanges the version and status packets and plugs the link into
+// the serial port
+func newBGB(conn net.Conn, s *serial.Serial) (*BGB, error) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetNoDelay(true)
+	}
+
+	b := &BGB{
+		conn:      conn,
+		serial:    s,
+		requests:  make(chan packet, 16),
+		responses: make(chan packet, 16),
+		closed:    make(chan struct{}),
+	}
+
+	if err := b.handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go b.read()
+	s.Connect(b)
+	return b, nil
+}
+
+func (b *BGB) handshake() error {
+	if err := b.send(packet{command: bgbVersion, b2: versionMajor, b3: versionMinor}); err != nil {
+		return err
+	}
+
+	b.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	defer b.conn.SetReadDeadline(time.Time{})
+
+	version, err := b.receive()
+	if err != nil {
+		return fmt.Errorf("protocol version not received: %w", err)
+	}
+	if version.command != bgbVersion || version.b2 != versionMajor || version.b3 != versionMinor || version.b4 != 0 {
+		return fmt.Errorf("unsupported BGB protocol version: %d.%d", version.b2, version.b3)
+	}
+
+	if err := b.send(packet{command: bgbStatus, b2: statusRunning}); err != nil {
+		return err
+	}
+
+	// the partner waits for this side from the start
+	return b.send(packet{command: bgbSync3, b2: sync3Timestamp})
+}
+
+// send writes a packet, it is only called from the emulation
+func (b *BGB) send(p packet) error {
+	if _, err := b.conn.Write(p.encode()); err != nil {
+		b.fail(fmt.Errorf("cannot send to the other end: %w", err))
+		return err
+	}
+	return nil
+}
+
+func (b *BGB) receive() (packet, error) {
+	data := make([]byte, packetSize)
+	if _, err := io.ReadFull(b.conn, data); err != nil {
+		return packet{}, err
+	}
+	return decodePacket(data), nil
+}
+
+// read dispatches the packets of the partner until the connection closes
+func (b *BGB) read() {
+	for {
+		p, err := b.receive()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			b.fail(err)
+			return
+		}
+
+		switch p.command {
+		case bgbSync1:
+			b.updateRemoteTime(p.timestamp)
+			b.deliver(b.requests, p)
+		case bgbSync2:
+			b.deliver(b.responses, p)
+		case bgbSync3:
+			if p.b2 == sync3NoData {
+				b.deliver(b.responses, p)
+			} else {
+				b.updateRemoteTime(p.timestamp)
+			}
+		case bgbStatus:
+			b.paused.Store(p.b2&statusPaused != 0 || p.b2&statusRunning == 0)
+		case bgbDisconnect:
+			b.fail(nil)
+			return
+		case bgbVersion, bgbJoypad:
+			// nothing to do, joypad packets only drive the BGB debugger
+		}
+	}
+}
+
+func (b *BGB) deliver(queue chan packet, p packet) {
+	select {
+	case queue <- p:
+	case <-b.closed:
+	}
+}
+
+func (b *BGB) updateRemoteTime(timestamp uint32) {
+	b.remoteTime.Store(timestamp & timestampMask)
+	b.remoteKnown.Store(true)
+}
+
+// fail closes the link, err is nil when the partner disconnected cleanly
+func (b *BGB) fail(err error) {
+	b.closeOnce.Do(func() {
+		b.err = err
+		close(b.closed)
+		b.conn.Close()
+	})
+}
+
+// Close tells the partner and closes the connection
+func (b *BGB) Close() error {
+	if !b.Closed() {
+		b.send(packet{command: bgbDisconnect})
+	}
+	b.fail(nil)
+	b.serial.Connect(nil)
+	return nil
+}
+
+// Closed reports whether the connection is closed
+func (b *BGB) Closed() bool {
+	select {
+	case <-b.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Err returns the error that closed the link, nil while it is open or when
+// the partner disconnected
+func (b *BGB) Err() error {
+	if !b.Closed() {
+		return nil
+	}
+	return b.err
+}
+
+// timestamp returns the local timestamp as sent to the partner
+func (b *BGB) timestamp() uint32 {
+	return b.time & timestampMask
+}
+
+// ahead returns how many ticks timestamp a is after b, with wrap-around
+func ahead(a, b uint32) int32 {
+	return int32((a-b)<<1) >> 1
+}
+
+// Exchange implements serial.SerialPeer. The byte is sent with sync1 and the
+// emulation blocks until the partner answers, for up to waitTimeout, since the
+// serial port needs the received byte to end the transfer. The partner answers
+// every sync1 once: until the answer of a transfer that timed out arrives the
+// partner is stalled, and the next bytes read the idle line at once instead of
+// waiting again. That answer is skipped and never taken for a later byte
+func (b *BGB) Exchange(out byte) byte {
+	if b.Closed() {
+		return serial.Disconnected
+	}
+
+	b.skipLateAnswers()
+	if b.late > 0 {
+		return serial.Disconnected
+	}
+
+	if err := b.send(packet{command: bgbSync1, b2: out, b3: sync1Control, timestamp: b.timestamp()}); err != nil {
+		return serial.Disconnected
+	}
+
+	timeout := time.NewTimer(waitTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case response := <-b.responses:
+			if response.command == bgbSync2 {
+				return response.b2
+			}
+			return serial.Disconnected
+		case request := <-b.requests:
+			// both sides started a transfer with their internal clock, the
+			// partner one finds nobody listening
+			b.send(packet{command: bgbSync3, b2: sync3NoData, timestamp: request.timestamp})
+		case <-b.closed:
+			return serial.Disconnected
+		case <-timeout.C:
+			b.late++
+			return serial.Disconnected
+		}
+	}
+}
+
+// skipLateAnswers drops the answers received for the transfers that timed out
+func (b *BGB) skipLateAnswers() {
+	for b.late > 0 {
+		select {
+		case <-b.responses:
+			b.late--
+		default:
+			return
+		}
+	}
+}
+
+// Step implements serial.ClockedPeer. It applies the partner transfers that
+// are due, sends the local timestamp and waits when this side runs too far
+// ahead of the partner
+func (b *BGB) Step(cycles int) {
+	if b.Closed() {
+		return
+	}
+
+	cycles += b.fraction
+	b.time += uint32(cycles / 2)
+	b.fraction = cycles % 2
+
+	b.collectRequests()
+	b.applyRequests()
+
+	if ahead(b.timestamp(), b.lastSync) >= syncInterval {
+		b.lastSync = b.timestamp()
+		b.send(packet{command: bgbSync3, b2: sync3Timestamp, timestamp: b.lastSync})
+		b.waitForPartner()
+	}
+}
+
+// collectRequests moves the received sync1 packets to the pending list
+func (b *BGB) collectRequests() {
+	for {
+		select {
+		case request := <-b.requests:
+			b.pending = append(b.pending, request)
+		default:
+			return
+		}
+	}
+}
+
+// applyRequests answers the first partner transfer when its timestamp was
+// reached. The byte is only accepted when this side waits with the external
+// clock, one transfer per step lets the game prepare the next one
+func (b *BGB) applyRequests() bool {
+	if len(b.pending) == 0 || ahead(b.timestamp(), b.pending[0].timestamp&timestampMask) < 0 {
+		return false
+	}
+
+	request := b.pending[0]
+	b.pending = b.pending[1:]
+
+	if out, ok := b.serial.Receive(request.b2); ok {
+		b.send(packet{command: bgbSync2, b2: out, b3: sync2Control})
+	} else {
+		b.send(packet{command: bgbSync3, b2: sync3NoData, timestamp: b.timestamp()})
+	}
+	return true
+}
+
+// waitForPartner blocks while this side is more than maxLead ticks ahead of
+// the last timestamp of the partner. The wait ends early on a partner
+// transfer, the partner may be blocked on it
+func (b *BGB) waitForPartner() {
+	deadline := time.Now().Add(waitTimeout)
+
+	for b.remoteKnown.Load() && !b.paused.Load() && ahead(b.timestamp(), b.remoteTime.Load()) > maxLead {
+		if time.Now().After(deadline) {
+			return
+		}
+
+		select {
+		case request := <-b.requests:
+			b.pending = append(b.pending, request)
+			if b.applyRequests() {
+				return
+			}
+		case <-b.closed:
+			return
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
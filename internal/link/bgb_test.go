@@ -0,0 +1,133 @@
+package link
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"gb-emulator/internal/memory"
+	"gb-emulator/internal/serial"
+)
+
+// newTestBGB returns a link whose partner discards everything it receives
+func newTestBGB(t *testing.T) *BGB {
+	local, remote := net.Pipe()
+	go io.Copy(io.Discard, remote)
+	t.Cleanup(func() {
+		local.Close()
+		remote.Close()
+	})
+
+	return &BGB{
+		conn:      local,
+		requests:  make(chan packet, 16),
+		responses: make(chan packet, 16),
+		closed:    make(chan struct{}),
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	hostSerial := serial.New(memory.New())
+	clientSerial := serial.New(memory.New())
+
+	hosts := make(chan *BGB, 1)
+	go func() {
+		host, err := accept(listener, hostSerial)
+		if err != nil {
+			t.Error(err)
+		}
+		hosts <- host
+	}()
+
+	client, err := Dial(listener.Addr().String(), clientSerial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	host := <-hosts
+	if host == nil {
+		t.FailNow()
+	}
+	defer host.Close()
+
+	// the client waits with the external clock for the byte of the host
+	clientSerial.WriteIO(serial.SBAddress, 0x42)
+	clientSerial.WriteIO(serial.SCAddress, 0x80)
+
+	answers := make(chan byte)
+	go func() { answers <- host.Exchange(0x99) }()
+
+	// the client applies the sync1 once it reaches its timestamp and answers
+	// with sync2
+	var in byte
+	for received := false; !received; {
+		select {
+		case in = <-answers:
+			received = true
+		default:
+			client.Step(4)
+			time.Sleep(100 * time.Microsecond)
+		}
+	}
+
+	if in != 0x42 {
+		t.Errorf("host received %02X, want 42", in)
+	}
+	if got := clientSerial.Data(); got != 0x99 {
+		t.Errorf("client received %02X, want 99", got)
+	}
+	if clientSerial.Waiting() {
+		t.Error("the client still waits for the transfer")
+	}
+}
+
+func TestExchangeAfterTimeout(t *testing.T) {
+	b := newTestBGB(t)
+
+	// the answer of a transfer that timed out hasn't come, the partner is
+	// stalled and the next byte doesn't wait for it
+	b.late = 1
+	start := time.Now()
+	if in := b.Exchange(0x55); in != serial.Disconnected {
+		t.Errorf("received %02X while the partner is stalled, want FF", in)
+	}
+	if elapsed := time.Since(start); elapsed >= waitTimeout/2 {
+		t.Errorf("blocked for %v while the partner is stalled", elapsed)
+	}
+
+	// the late answer arrives first and is skipped
+	b.responses <- packet{command: bgbSync2, b2: 0x11, b3: sync2Control}
+	b.responses <- packet{command: bgbSync2, b2: 0x22, b3: sync2Control}
+
+	if in := b.Exchange(0x55); in != 0x22 {
+		t.Errorf("received %02X, want 22", in)
+	}
+	if b.late != 0 {
+		t.Errorf("%d late answers left, want 0", b.late)
+	}
+}
+
+func TestAhead(t *testing.T) {
+	tests := []struct {
+		a, b uint32
+		want int32
+	}{
+		{10, 4, 6},
+		{4, 10, -6},
+		{2, timestampMask - 1, 4}, // wrapped around
+		{timestampMask - 1, 2, -4},
+	}
+
+	for _, test := range tests {
+		if got := ahead(test.a, test.b); got != test.want {
+			t.Errorf("ahead(%d, %d) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package link
+
+import "gb-emulator/internal/serial"
+
+// Cable connects the serial ports of two Game Boys emulated in the same
+// process. The side using its internal clock exchanges the byte with the
+// other side when its transfer completes, so both machines must be run in
+// lockstep for the timing to match a real cable
+type Cable struct {
+	a *serial.Serial
+	b *serial.Serial
+}
+
+// Connect plugs the cable between two serial ports
+func Connect(a, b *serial.Serial) *Cable {
+	a.Connect(cableEnd{other: b})
+	b.Connect(cableEnd{other: a})
+	return &Cable{a: a, b: b}
+}
+
+// Disconnect unplugs the cable from both ports
+func (c *Cable) Disconnect() {
+	c.a.Connect(nil)
+	c.b.Connect(nil)
+}
+
+// cableEnd is the serial.SerialPeer seen by one of the ports
+type cableEnd struct {
+	other *serial.Serial
+}
+
+// Exchange implements serial.SerialPeer. The other side only shifts when it
+// waits with the external clock, otherwise the line stays high
+func (e cableEnd) Exchange(out byte) byte {
+	in, ok := e.other.Receive(out)
+	if !ok {
+		return serial.Disconnected
+	}
+	return in
+}
@@ -0,0 +1,66 @@
+package link
+
+import (
+	"testing"
+
+	"gb-emulator/internal/memory"
+	"gb-emulator/internal/serial"
+)
+
+// transferCycles are the M-cycles of a transfer with the 8192 Hz clock
+const transferCycles = 8 * 128
+
+func newTestPort(sb, sc byte) *serial.Serial {
+	s := serial.New(memory.New())
+	s.WriteIO(serial.SBAddress, sb)
+	s.WriteIO(serial.SCAddress, sc)
+	return s
+}
+
+func TestCable(t *testing.T) {
+	tests := []struct {
+		name    string
+		otherSC byte // SC of the side not driving the clock
+		in      byte // byte received by the driving side
+		other   byte // SB of the other side after the transfer
+	}{
+		{"waiting with the external clock", 0x80, 0x22, 0x11},
+		{"no transfer requested", 0x00, serial.Disconnected, 0x22},
+		{"both with the internal clock", 0x81, serial.Disconnected, 0x22},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := newTestPort(0x11, 0x81)
+			b := newTestPort(0x22, test.otherSC)
+			Connect(a, b)
+
+			a.Step(transferCycles)
+
+			if got := a.ReadIO(serial.SBAddress); got != test.in {
+				t.Errorf("driving side received %02X, want %02X", got, test.in)
+			}
+			if got := b.ReadIO(serial.SBAddress); got != test.other {
+				t.Errorf("other side SB %02X, want %02X", got, test.other)
+			}
+		})
+	}
+}
+
+func TestCableDisconnect(t *testing.T) {
+	a := newTestPort(0x11, 0x81)
+	b := newTestPort(0x22, 0x80)
+	Connect(a, b).Disconnect()
+
+	if a.Peer() != nil || b.Peer() != nil {
+		t.Fatal("ports still connected")
+	}
+
+	a.Step(transferCycles)
+	if got := a.ReadIO(serial.SBAddress); got != serial.Disconnected {
+		t.Errorf("received %02X after the disconnection, want FF", got)
+	}
+	if !b.Waiting() {
+		t.Error("the other side stopped waiting")
+	}
+}
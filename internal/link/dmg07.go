@@ -0,0 +1,208 @@
+package link
+
+import "gb-emulator/internal/serial"
+
+// Documentation
+// * https://gbdev.io/pandocs/Four_Player_Adapter.html
+// * https://shonumi.github.io/dandocs.html#dmg07
+
+const (
+	// AdapterPorts is the number of Game Boys the DMG-07 connects
+	AdapterPorts = 4
+
+	pingHeader    = 0xFE
+	pingAck       = 0x88 // answer of a Game Boy to the first two ping bytes
+	startRequest  = 0xAA // sent by player 1 to start the transmission phase
+	startAck      = 0xCC // sent by the adapter before the transmission phase
+	restartSignal = 0xFF // player 1 data that returns to the ping phase
+	signalLength  = 4    // bytes of every ping packet and phase change signal
+
+	// the adapter clocks its bytes at about 8 kHz, RATE adds a delay between
+	// two bytes
+	baseBytePeriod = 4096 // T-cycles
+	rateStep       = 512
+
+	defaultSize = 4
+	maxSize     = 4
+)
+
+// adapterPhase is the state of the DMG-07 protocol
+type adapterPhase int
+
+const (
+	phasePing         adapterPhase = iota // the adapter looks for Game Boys and reads RATE and SIZE
+	phaseStart                            // the adapter confirms the transmission phase
+	phaseTransmission                     // the data of every player is sent to all of them
+)
+
+// Adapter emulates the DMG-07 four player adapter. It drives the clock of
+// every connected serial port, which must wait for transfers using the
+// external clock
+type Adapter struct {
+	ports [AdapterPorts]*serial.Serial
+
+	phase     adapterPhase
+	position  int // byte of the current packet
+	countdown int // T-cycles until the next byte
+	rate      byte
+	size      int
+
+	connected byte                             // bit n set when player n+1 answered the last ping
+	answers   [AdapterPorts][signalLength]byte // answers to the current ping packet
+	received  [AdapterPorts][maxSize]byte      // player data collected for the next round
+	packet    []byte                           // data of all players sent in the current round
+	restarts  int                              // consecutive restart bytes sent by player 1
+}
+
+// NewAdapter connects up to four serial ports, nil leaves a port empty
+func NewAdapter(ports ...*serial.Serial) *Adapter {
+	a := &Adapter{size: defaultSize, countdown: baseBytePeriod}
+	copy(a.ports[:], ports)
+	return a
+}
+
+// Connected returns a mask of the players that answered the last ping, bit 0
+// for player 1
+func (a *Adapter) Connected() byte {
+	return a.connected
+}
+
+// Transmitting reports whether the adapter is in the transmission phase
+func (a *Adapter) Transmitting() bool {
+	return a.phase == phaseTransmission
+}
+
+// Step advances the adapter by the given T-cycles at normal speed
+func (a *Adapter) Step(cycles int) {
+	a.countdown -= cycles
+	for a.countdown <= 0 {
+		a.countdown += a.bytePeriod()
+		a.clockByte()
+	}
+}
+
+func (a *Adapter) bytePeriod() int {
+	return baseBytePeriod + int(a.rate&0x0F)*rateStep
+}
+
+// exchange sends one byte to every port and returns their answers, an empty
+// port or one not waiting for a transfer answers 0xFF
+func (a *Adapter) exchange(out func(port int) byte) [AdapterPorts]byte {
+	var answers [AdapterPorts]byte
+	for port, s := range a.ports {
+		answers[port] = serial.Disconnected
+		if s == nil {
+			continue
+		}
+		if in, ok := s.Receive(out(port)); ok {
+			answers[port] = in
+		}
+	}
+	return answers
+}
+
+// clockByte runs one byte of the current phase
+func (a *Adapter) clockByte() {
+	switch a.phase {
+	case phasePing:
+		a.ping()
+	case phaseStart:
+		a.exchange(func(int) byte { return startAck })
+		a.position++
+		if a.position == signalLength {
+			a.startTransmission()
+		}
+	case phaseTransmission:
+		a.transmit()
+	}
+}
+
+// ping sends the ping packet: the header and three copies of the status,
+// which holds the connected players in the high nibble and the player
+// number of the receiving Game Boy in the low one
+func (a *Adapter) ping() {
+	position := a.position
+	answers := a.exchange(func(port int) byte {
+		if position == 0 {
+			return pingHeader
+		}
+		return a.connected<<4 | byte(port+1)
+	})
+
+	for port, answer := range answers {
+		a.answers[port][position] = answer
+	}
+
+	a.position++
+	if a.position < signalLength {
+		return
+	}
+	a.position = 0
+
+	// player 1 asks for the transmission phase instead of acknowledging, the
+	// players found by the previous pings take part
+	player1 := a.answers[0]
+	if player1 == [signalLength]byte{startRequest, startRequest, startRequest, startRequest} {
+		a.phase = phaseStart
+		return
+	}
+
+	// a Game Boy is connected when it acknowledges the header
+	a.connected = 0
+	for port, answer := range a.answers {
+		if answer[0] == pingAck && answer[1] == pingAck {
+			a.connected |= 1 << port
+		}
+	}
+
+	if a.connected&1 != 0 {
+		a.rate = player1[2]
+		a.size = max(1, min(maxSize, int(player1[3])))
+	}
+}
+
+func (a *Adapter) startTransmission() {
+	a.phase = phaseTransmission
+	a.position = 0
+	a.restarts = 0
+	a.received = [AdapterPorts][maxSize]byte{}
+	a.packet = make([]byte, AdapterPorts*a.size)
+}
+
+// transmit runs one byte of a round. Every Game Boy sends its SIZE bytes at
+// the start of the round while the adapter sends the data of all players
+// collected in the previous one
+func (a *Adapter) transmit() {
+	position := a.position
+	answers := a.exchange(func(int) byte { return a.packet[position] })
+
+	if position < a.size {
+		for port, answer := range answers {
+			if a.connected&(1<<port) == 0 {
+				answer = 0x00
+			}
+			a.received[port][position] = answer
+		}
+
+		if answers[0] == restartSignal {
+			a.restarts++
+		} else {
+			a.restarts = 0
+		}
+	}
+
+	a.position++
+	if a.position < len(a.packet) {
+		return
+	}
+	a.position = 0
+
+	for port := range AdapterPorts {
+		copy(a.packet[port*a.size:], a.received[port][:a.size])
+	}
+
+	if a.restarts >= signalLength {
+		a.phase = phasePing
+		a.rate = 0
+	}
+}
@@ -0,0 +1,150 @@
+package link
+
+import (
+	"bytes"
+	"testing"
+
+	"gb-emulator/internal/memory"
+	"gb-emulator/internal/serial"
+)
+
+func newTestAdapter(players int) (*Adapter, []*serial.Serial) {
+	ports := make([]*serial.Serial, players)
+	for i := range ports {
+		ports[i] = serial.New(memory.New())
+	}
+	return NewAdapter(ports...), ports
+}
+
+// clock runs one byte of the adapter with every port waiting to send its byte
+// of out, and returns the bytes the ports received
+func clock(a *Adapter, ports []*serial.Serial, out ...byte) []byte {
+	for i, port := range ports {
+		port.WriteIO(serial.SBAddress, out[i])
+		port.WriteIO(serial.SCAddress, 0x80)
+	}
+
+	a.Step(a.bytePeriod())
+
+	received := make([]byte, len(ports))
+	for i, port := range ports {
+		received[i] = port.ReadIO(serial.SBAddress)
+	}
+	return received
+}
+
+// ping runs a ping packet where each player sends the four bytes of answers
+func ping(t *testing.T, a *Adapter, ports []*serial.Serial, answers ...[signalLength]byte) {
+	t.Helper()
+
+	for position := range signalLength {
+		out := make([]byte, len(ports))
+		for i := range ports {
+			out[i] = answers[i][position]
+		}
+		clock(a, ports, out...)
+	}
+}
+
+func TestPing(t *testing.T) {
+	a, ports := newTestAdapter(2)
+	ack := [signalLength]byte{pingAck, pingAck, 0x03, 0x02}
+
+	var received [][]byte
+	for position := range signalLength {
+		received = append(received, clock(a, ports, ack[position], ack[position]))
+	}
+	want := [][]byte{{pingHeader, pingHeader}, {0x01, 0x02}, {0x01, 0x02}, {0x01, 0x02}}
+	for position := range signalLength {
+		if !bytes.Equal(received[position], want[position]) {
+			t.Errorf("ping byte %d received % X, want % X", position, received[position], want[position])
+		}
+	}
+
+	if got := a.Connected(); got != 0b11 {
+		t.Errorf("connected %04b, want 0011", got)
+	}
+	if got := a.bytePeriod(); got != baseBytePeriod+3*rateStep {
+		t.Errorf("byte period %d with RATE 03, want %d", got, baseBytePeriod+3*rateStep)
+	}
+	if a.size != 2 {
+		t.Errorf("size %d, want 2", a.size)
+	}
+
+	// the status of the next ping holds the connected players
+	if got := clock(a, ports, 0, 0); got[0] != pingHeader {
+		t.Fatalf("received %02X, want the ping header", got[0])
+	}
+	if got := clock(a, ports, 0, 0); !bytes.Equal(got, []byte{0x31, 0x32}) {
+		t.Errorf("status % X, want 31 32", got)
+	}
+}
+
+func TestPingWithoutAnswer(t *testing.T) {
+	a, ports := newTestAdapter(3)
+	ack := [signalLength]byte{pingAck, pingAck, 0, 4}
+	silent := [signalLength]byte{0xFF, 0xFF, 0xFF, 0xFF}
+
+	ping(t, a, ports, ack, silent, ack)
+	if got := a.Connected(); got != 0b101 {
+		t.Errorf("connected %04b, want 0101", got)
+	}
+
+	// an empty port isn't connected
+	a = NewAdapter(ports[0], nil)
+	ping(t, a, ports[:1], ack)
+	if got := a.Connected(); got != 0b01 {
+		t.Errorf("connected %04b with an empty port, want 0001", got)
+	}
+}
+
+func TestTransmission(t *testing.T) {
+	a, ports := newTestAdapter(2)
+	ping(t, a, ports, [signalLength]byte{pingAck, pingAck, 0, 2}, [signalLength]byte{pingAck, pingAck, 0, 0})
+
+	start := [signalLength]byte{startRequest, startRequest, startRequest, startRequest}
+	ping(t, a, ports, start, [signalLength]byte{pingAck, pingAck, 0, 0})
+	for range signalLength {
+		if got := clock(a, ports, 0, 0); !bytes.Equal(got, []byte{startAck, startAck}) {
+			t.Fatalf("received % X, want the start acknowledgement", got)
+		}
+	}
+	if !a.Transmitting() {
+		t.Fatal("not in the transmission phase")
+	}
+
+	// the players send their 2 bytes at the start of the round and get the
+	// data of every player in the next one, 0 for the missing ones
+	round := func(first, second [2]byte) [2][]byte {
+		var received [2][]byte
+		for position := range AdapterPorts * 2 {
+			var out [2]byte
+			if position < 2 {
+				out = [2]byte{first[position], second[position]}
+			}
+			in := clock(a, ports, out[0], out[1])
+			received[0] = append(received[0], in[0])
+			received[1] = append(received[1], in[1])
+		}
+		return received
+	}
+
+	round([2]byte{0x10, 0x11}, [2]byte{0x20, 0x21})
+	received := round([2]byte{}, [2]byte{})
+	want := []byte{0x10, 0x11, 0x20, 0x21, 0, 0, 0, 0}
+	for player := range 2 {
+		if !bytes.Equal(received[player], want) {
+			t.Errorf("player %d received % X, want % X", player+1, received[player], want)
+		}
+	}
+
+	// four restart bytes of player 1 go back to the ping phase
+	round([2]byte{restartSignal, restartSignal}, [2]byte{})
+	if !a.Transmitting() {
+		t.Fatal("back to the ping phase after two restart bytes")
+	}
+	round([2]byte{restartSignal, restartSignal}, [2]byte{})
+	if a.Transmitting() {
+		t.Error("still transmitting after four restart bytes")
+	}
+}
@@ -0,0 +1,45 @@
+package memory
+
+// Documentation
+// * https://gbdev.io/pandocs/MBCs.html
+
+const (
+	ExternalRamStartAddress = SwitchableRamBankStartAddress
+	ExternalRamEndAddress   = InternalRamStartAddress - 1
+
+	// BootRomDisableAddress unmaps the boot ROM when written with a non-zero value
+	BootRomDisableAddress = 0xFF50
+)
+
+// MapCartridge routes the ROM (0x0000-0x7FFF) and external RAM (0xA000-0xBFFF)
+// areas to a cartridge with its memory bank controller. While booting, the
+// boot ROM still overlays the reads of bank 0
+func (m *Memory) MapCartridge(cartridge IODevice) {
+	m.cartridge = cartridge
+}
+
+// Cartridge returns the device mapped with MapCartridge, nil without one
+func (m *Memory) Cartridge() IODevice {
+	return m.cartridge
+}
+
+// cartridgeDevice returns the cartridge mapped at the address, or nil when the
+// address is not handled by a cartridge. The boot ROM is read only, its
+// writes reach the controller
+func (m *Memory) cartridgeDevice(address uint16, write bool) IODevice {
+	switch {
+	case m.cartridge == nil:
+		return nil
+	case address < SwitchableRomBankStartAddress:
+		if m.Boot && !write {
+			return nil
+		}
+		return m.cartridge
+	case address < VideoRamStartAddress:
+		return m.cartridge
+	case address >= ExternalRamStartAddress && address <= ExternalRamEndAddress:
+		return m.cartridge
+	}
+
+	return nil
+}
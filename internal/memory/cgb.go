@@ -0,0 +1,91 @@
+package memory
+
+// Documentation
+// * https://gbdev.io/pandocs/CGB_Registers.html
+// * https://gbdev.io/pandocs/Hardware_Reg_List.html (undocumented registers)
+
+const (
+	KEY1Address = 0xFF4D // prepare speed switch
+	VBKAddress  = 0xFF4F // VRAM bank select
+	SVBKAddress = 0xFF70 // WRAM bank select
+
+	UndocumentedStartAddress = 0xFF72 // FF72-FF75, plain storage on CGB hardware
+	UndocumentedEndAddress   = 0xFF75
+	undocumentedCGBOnly      = 0xFF74 // only writable in CGB mode
+	undocumentedFF75Mask     = 0x70   // only bits 4-6 of FF75 exist
+)
+
+// cgbRegisters handles the CGB registers that belong to the memory
+type cgbRegisters struct {
+	m *Memory
+}
+
+// EnableCGB switches the memory to Game Boy Color mode and maps the CGB
+// banking and speed registers
+func (m *Memory) EnableCGB() {
+	m.CGB = true
+	m.WorkRamBank = 1
+
+	registers := cgbRegisters{m: m}
+	m.MapIO(KEY1Address, KEY1Address, registers)
+	m.MapIO(VBKAddress, VBKAddress, registers)
+	m.MapIO(SVBKAddress, SVBKAddress, registers)
+	m.EnableCGBHardware()
+}
+
+// EnableCGBHardware maps the registers a CGB has even when it runs an
+// original Game Boy cartridge in compatibility mode
+func (m *Memory) EnableCGBHardware() {
+	m.MapIO(UndocumentedStartAddress, UndocumentedEndAddress, cgbRegisters{m: m})
+}
+
+// VideoRamBanks returns both VRAM banks, bank 1 is only used in CGB mode
+func (m *Memory) VideoRamBanks() [2]*[VideoRamSize]byte {
+	return [2]*[VideoRamSize]byte{&m.VideoRam, &m.VideoRamBank1}
+}
+
+// ReadIO implements IODevice
+func (r cgbRegisters) ReadIO(address uint16) byte {
+	switch {
+	case address == KEY1Address:
+		key1 := byte(0x7E)
+		if r.m.DoubleSpeed {
+			key1 |= 0x80
+		}
+		if r.m.SpeedSwitchArmed {
+			key1 |= 0x01
+		}
+		return key1
+	case address == VBKAddress:
+		return 0xFE | r.m.VideoRamBank
+	case address == SVBKAddress:
+		return 0xF8 | r.m.WorkRamBank
+	case address == undocumentedCGBOnly && !r.m.CGB:
+		return 0xFF
+	case address == UndocumentedEndAddress:
+		return 0x8F | r.m.Undocumented[address-UndocumentedStartAddress]
+	case address >= UndocumentedStartAddress && address <= UndocumentedEndAddress:
+		return r.m.Undocumented[address-UndocumentedStartAddress]
+	}
+
+	return 0xFF
+}
+
+// WriteIO implements IODevice
+func (r cgbRegisters) WriteIO(address uint16, value byte) {
+	switch {
+	case address == KEY1Address:
+		r.m.SpeedSwitchArmed = value&0x01 != 0
+	case address == VBKAddress:
+		r.m.VideoRamBank = value & 0x01
+	case address == SVBKAddress:
+		// bank 0 can't be mapped at 0xD000, selecting it maps bank 1
+		r.m.WorkRamBank = max(value&0x07, 1)
+	case address == undocumentedCGBOnly && !r.m.CGB:
+		// locked in compatibility mode
+	case address == UndocumentedEndAddress:
+		r.m.Undocumented[address-UndocumentedStartAddress] = value & undocumentedFF75Mask
+	case address >= UndocumentedStartAddress && address <= UndocumentedEndAddress:
+		r.m.Undocumented[address-UndocumentedStartAddress] = value
+	}
+}
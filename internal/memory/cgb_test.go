@@ -0,0 +1,141 @@
+package memory
+
+import "testing"
+
+func newCGBMemory() *Memory {
+	m := New()
+	m.Boot = false
+	m.EnableCGB()
+	return m
+}
+
+func TestKEY1(t *testing.T) {
+	m := newCGBMemory()
+
+	if value := m.Read(KEY1Address); value != 0x7E {
+		t.Errorf("KEY1 %02X at power on, want 7E", value)
+	}
+
+	m.Write(KEY1Address, 0xFF) // only bit 0 is writable
+	if value := m.Read(KEY1Address); value != 0x7F {
+		t.Errorf("KEY1 %02X after arming the switch, want 7F", value)
+	}
+	if m.DoubleSpeed {
+		t.Error("the speed changed before STOP")
+	}
+
+	m.DoubleSpeed = true
+	m.SpeedSwitchArmed = false
+	if value := m.Read(KEY1Address); value != 0xFE {
+		t.Errorf("KEY1 %02X in double speed, want FE", value)
+	}
+}
+
+func TestSVBK(t *testing.T) {
+	m := newCGBMemory()
+
+	tests := []struct {
+		value    byte
+		wantBank byte
+	}{
+		{0, 1}, // bank 0 maps bank 1
+		{1, 1},
+		{2, 2},
+		{7, 7},
+		{0xFB, 3}, // only bits 0-2
+	}
+	for _, test := range tests {
+		m.Write(SVBKAddress, test.value)
+		if value := m.Read(SVBKAddress); value != 0xF8|test.wantBank {
+			t.Errorf("SVBK %02X after writing %02X, want %02X", value, test.value, 0xF8|test.wantBank)
+		}
+	}
+
+	for bank := range byte(8) {
+		m.Write(SVBKAddress, bank)
+		m.Write(SwitchableRamStartAddress, 0x10+bank)
+	}
+	// bank 0 at 0xC000 is never switched
+	m.Write(InternalRamStartAddress, 0xAA)
+
+	for bank := byte(1); bank < 8; bank++ {
+		m.Write(SVBKAddress, bank)
+		if value := m.Read(SwitchableRamStartAddress); value != 0x10+bank {
+			t.Errorf("bank %d reads %02X at 0xD000, want %02X", bank, value, 0x10+bank)
+		}
+		// the echo RAM mirrors the selected bank
+		if value := m.Read(EchoInternalRamStartAddress + InternalRamSize); value != 0x10+bank {
+			t.Errorf("bank %d reads %02X at 0xF000, want %02X", bank, value, 0x10+bank)
+		}
+		if value := m.Read(InternalRamStartAddress); value != 0xAA {
+			t.Errorf("bank %d changed 0xC000 to %02X", bank, value)
+		}
+	}
+}
+
+func TestVBK(t *testing.T) {
+	m := newCGBMemory()
+
+	m.Write(VBKAddress, 0xFF)
+	if value := m.Read(VBKAddress); value != 0xFF {
+		t.Errorf("VBK %02X, want FF", value)
+	}
+	m.Write(VideoRamStartAddress, 0x11)
+
+	m.Write(VBKAddress, 0x00)
+	if value := m.Read(VBKAddress); value != 0xFE {
+		t.Errorf("VBK %02X, want FE", value)
+	}
+	m.Write(VideoRamStartAddress, 0x22)
+
+	if m.VideoRamBank1[0] != 0x11 || m.VideoRam[0] != 0x22 {
+		t.Errorf("banks hold %02X and %02X, want 22 and 11", m.VideoRam[0], m.VideoRamBank1[0])
+	}
+}
+
+func TestBankingRegistersOnlyInCGBMode(t *testing.T) {
+	m := New()
+	m.Boot = false
+	m.EnableCGBHardware() // compatibility mode
+
+	m.Write(SVBKAddress, 3)
+	m.Write(SwitchableRamStartAddress, 0x33)
+	if m.SwitchableRam[0] != 0x33 {
+		t.Error("SVBK switched the WRAM bank in compatibility mode")
+	}
+
+	m.Write(VBKAddress, 1)
+	m.Write(VideoRamStartAddress, 0x44)
+	if m.VideoRam[0] != 0x44 {
+		t.Error("VBK switched the VRAM bank in compatibility mode")
+	}
+}
+
+func TestUndocumentedRegisters(t *testing.T) {
+	tests := []struct {
+		cgb     bool
+		address uint16
+		value   byte
+		want    byte
+	}{
+		{true, 0xFF72, 0xA5, 0xA5},
+		{true, 0xFF74, 0xA5, 0xA5},
+		{false, 0xFF74, 0xA5, 0xFF}, // locked in compatibility mode
+		{true, 0xFF75, 0xFF, 0xFF},
+		{true, 0xFF75, 0x00, 0x8F}, // only bits 4-6 exist
+	}
+
+	for _, test := range tests {
+		m := New()
+		if test.cgb {
+			m.EnableCGB()
+		} else {
+			m.EnableCGBHardware()
+		}
+
+		m.Write(test.address, test.value)
+		if value := m.Read(test.address); value != test.want {
+			t.Errorf("CGB %v: %04X reads %02X after writing %02X, want %02X", test.cgb, test.address, value, test.value, test.want)
+		}
+	}
+}
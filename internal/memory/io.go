@@ -0,0 +1,59 @@
+package memory
+
+// Documentation
+// * https://gbdev.io/pandocs/Hardware_Reg_List.html
+// * https://gbdev.io/pandocs/Interrupts.html
+
+const (
+	IFAddress = 0xFF0F // Interrupt Flag register
+	IEAddress = 0xFFFF // Interrupt Enable register
+)
+
+// Interrupt bits shared by the IF and IE registers
+const (
+	InterruptVBlank byte = 1 << 0
+	InterruptLCD    byte = 1 << 1
+	InterruptTimer  byte = 1 << 2
+	InterruptSerial byte = 1 << 3
+	InterruptJoypad byte = 1 << 4
+)
+
+// IODevice is implemented by the hardware blocks (PPU, timer, ...) that own
+// registers inside the I/O port region
+type IODevice interface {
+	ReadIO(address uint16) byte
+	WriteIO(address uint16, value byte)
+}
+
+// MapIO routes reads and writes of the I/O registers between start and end
+// (both included) to the given device
+func (m *Memory) MapIO(start uint16, end uint16, device IODevice) {
+	for address := start; address <= end; address++ {
+		m.ioDevices[address-IOPortStartAddress] = device
+	}
+}
+
+// ioDevice returns the device mapped at the address, or nil when the address
+// is not a mapped I/O register
+func (m *Memory) ioDevice(address uint16) IODevice {
+	if address < IOPortStartAddress || address >= HighRamStartAddress {
+		return nil
+	}
+
+	return m.ioDevices[address-IOPortStartAddress]
+}
+
+// RequestInterrupt sets the given interrupt bit in the IF register
+func (m *Memory) RequestInterrupt(interrupt byte) {
+	m.IOPort[IFAddress-IOPortStartAddress] |= interrupt
+}
+
+// PendingInterrupts returns the interrupts both requested in IF and enabled in IE
+func (m *Memory) PendingInterrupts() byte {
+	return m.IOPort[IFAddress-IOPortStartAddress] & m.IE[0] & 0x1F
+}
+
+// ClearInterrupt clears the given interrupt bit in the IF register
+func (m *Memory) ClearInterrupt(interrupt byte) {
+	m.IOPort[IFAddress-IOPortStartAddress] &^= interrupt
+}
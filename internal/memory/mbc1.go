@@ -0,0 +1,130 @@
+package memory
+
+import "gb-emulator/internal/savestate"
+
+// Documentation
+// * https://gbdev.io/pandocs/MBC1.html
+
+const (
+	mbc1RamEnableEnd  = 0x1FFF
+	mbc1RomBankEnd    = 0x3FFF
+	mbc1RamBankEnd    = 0x5FFF
+	mbc1ModeSelectEnd = 0x7FFF
+
+	externalRamBankSize = ExternalRamEndAddress - ExternalRamStartAddress + 1
+)
+
+// MBC1 is the memory bank controller of most early cartridges: up to 2MB of
+// ROM in 16KB banks and up to 32KB of external RAM in 8KB banks
+type MBC1 struct {
+	rom []byte
+	ram []byte
+
+	ramEnabled bool
+	bank1      byte // 0x2000-0x3FFF, low 5 bits of the ROM bank, never 0
+	bank2      byte // 0x4000-0x5FFF, RAM bank or bits 5-6 of the ROM bank
+	mode       byte // 0x6000-0x7FFF, 1 applies bank2 to bank 0 and the RAM too
+}
+
+// NewMBC1 creates the controller of a cartridge with the given ROM and
+// external RAM size in bytes
+func NewMBC1(rom []byte, ramSize int) *MBC1 {
+	// the ROM is padded to a power of two banks, at least two, so the bank
+	// number can be masked like the hardware does
+	banks := 2
+	for banks*RomBank0Size < len(rom) {
+		banks *= 2
+	}
+	padded := make([]byte, banks*RomBank0Size)
+	copy(padded, rom)
+
+	return &MBC1{rom: padded, ram: make([]byte, ramSize), bank1: 1}
+}
+
+func (c *MBC1) romBanks() int {
+	return len(c.rom) / RomBank0Size
+}
+
+// romBank returns the bank mapped at 0x0000 or at 0x4000
+func (c *MBC1) romBank(switchable bool) int {
+	bank := int(c.bank2) << 5
+	switch {
+	case switchable:
+		bank |= int(c.bank1)
+	case c.mode == 0:
+		bank = 0
+	}
+	return bank & (c.romBanks() - 1)
+}
+
+// ramOffset returns the offset in the external RAM of the address, or -1
+// when the RAM is disabled or the cartridge has none
+func (c *MBC1) ramOffset(address uint16) int {
+	if !c.ramEnabled || len(c.ram) == 0 {
+		return -1
+	}
+
+	bank := 0
+	if c.mode == 1 {
+		bank = int(c.bank2)
+	}
+	return (bank*externalRamBankSize + int(address-ExternalRamStartAddress)) % len(c.ram)
+}
+
+// ReadIO implements IODevice
+func (c *MBC1) ReadIO(address uint16) byte {
+	switch {
+	case address < SwitchableRomBankStartAddress:
+		return c.rom[c.romBank(false)*RomBank0Size+int(address)]
+	case address < VideoRamStartAddress:
+		return c.rom[c.romBank(true)*RomBank0Size+int(address-SwitchableRomBankStartAddress)]
+	}
+
+	if offset := c.ramOffset(address); offset >= 0 {
+		return c.ram[offset]
+	}
+	return 0xFF
+}
+
+// WriteIO implements IODevice
+func (c *MBC1) WriteIO(address uint16, value byte) {
+	switch {
+	case address <= mbc1RamEnableEnd:
+		c.ramEnabled = value&0x0F == 0x0A
+	case address <= mbc1RomBankEnd:
+		c.bank1 = max(value&0x1F, 1)
+	case address <= mbc1RamBankEnd:
+		c.bank2 = value & 0x03
+	case address <= mbc1ModeSelectEnd:
+		c.mode = value & 0x01
+	default:
+		if offset := c.ramOffset(address); offset >= 0 {
+			c.ram[offset] = value
+		}
+	}
+}
+
+// RAM returns the external RAM, kept by the battery on the cartridges that have one
+func (c *MBC1) RAM() []byte {
+	return c.ram
+}
+
+// SaveState implements savestate.Stater, the ROM comes from the cartridge
+func (c *MBC1) SaveState(e *savestate.Encoder) {
+	e.Bool(c.ramEnabled)
+	e.Uint8(c.bank1)
+	e.Uint8(c.bank2)
+	e.Uint8(c.mode)
+	e.Slice(c.ram)
+}
+
+// LoadState implements savestate.Stater
+func (c *MBC1) LoadState(d *savestate.Decoder) {
+	c.ramEnabled = d.Bool()
+	c.bank1 = max(d.Uint8()&0x1F, 1)
+	c.bank2 = d.Uint8() & 0x03
+	c.mode = d.Uint8() & 0x01
+	if ram := d.Slice(); len(ram) == len(c.ram) {
+		copy(c.ram, ram)
+	}
+}
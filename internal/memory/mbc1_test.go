@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"bytes"
+	"testing"
+)
+
+// newBankedROM returns a ROM whose banks start with their own number
+func newBankedROM(banks int) []byte {
+	rom := make([]byte, banks*RomBank0Size)
+	for bank := range banks {
+		rom[bank*RomBank0Size] = byte(bank)
+	}
+	return rom
+}
+
+func TestMBC1ROMBanks(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes map[uint16]byte
+		bank0  byte // bank mapped at 0x0000
+		bankN  byte // bank mapped at 0x4000
+	}{
+		{"power on", nil, 0, 1},
+		{"bank 5", map[uint16]byte{0x2000: 5}, 0, 5},
+		{"bank 0 selects 1", map[uint16]byte{0x2000: 0}, 0, 1},
+		{"only 5 bits", map[uint16]byte{0x2000: 0xE3}, 0, 3},
+		{"upper bits", map[uint16]byte{0x2000: 2, 0x4000: 1}, 0, 34},
+		{"upper bits in mode 1", map[uint16]byte{0x2000: 2, 0x4000: 1, 0x6000: 1}, 32, 34},
+		{"masked by the ROM size", map[uint16]byte{0x2000: 2, 0x4000: 3}, 0, 34},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := New()
+			m.Boot = false
+			m.MapCartridge(NewMBC1(newBankedROM(64), 0))
+			for _, address := range []uint16{0x2000, 0x4000, 0x6000} {
+				if value, exists := test.writes[address]; exists {
+					m.Write(address, value)
+				}
+			}
+
+			if bank := m.Read(0x0000); bank != test.bank0 {
+				t.Errorf("bank %d at 0x0000, want %d", bank, test.bank0)
+			}
+			if bank := m.Read(SwitchableRomBankStartAddress); bank != test.bankN {
+				t.Errorf("bank %d at 0x4000, want %d", bank, test.bankN)
+			}
+		})
+	}
+}
+
+func TestMBC1RAM(t *testing.T) {
+	m := New()
+	m.Boot = false
+	m.MapCartridge(NewMBC1(newBankedROM(4), 32*1024))
+
+	m.Write(ExternalRamStartAddress, 0x12)
+	if value := m.Read(ExternalRamStartAddress); value != 0xFF {
+		t.Fatalf("disabled RAM read %02X, want FF", value)
+	}
+
+	m.Write(0x0000, 0x0A)
+	m.Write(0x6000, 1)
+	for bank := range byte(4) {
+		m.Write(0x4000, bank)
+		m.Write(ExternalRamStartAddress, 0x10+bank)
+	}
+	for bank := range byte(4) {
+		m.Write(0x4000, bank)
+		if value := m.Read(ExternalRamStartAddress); value != 0x10+bank {
+			t.Errorf("RAM bank %d read %02X, want %02X", bank, value, 0x10+bank)
+		}
+	}
+
+	// in mode 0 only the first bank is mapped
+	m.Write(0x6000, 0)
+	if value := m.Read(ExternalRamStartAddress); value != 0x10 {
+		t.Errorf("mode 0 read %02X, want 10", value)
+	}
+}
+
+func TestBootRomDisable(t *testing.T) {
+	m := New()
+	m.BootRomBank0[0] = 0x31
+	m.MapCartridge(NewMBC1(newBankedROM(2), 0))
+
+	if value := m.Read(0x0000); value != 0x31 {
+		t.Fatalf("boot ROM read %02X, want 31", value)
+	}
+	// the bank registers are written while booting too
+	m.Write(0x2000, 0)
+	if value := m.BootRomBank0[0]; value != 0x31 {
+		t.Fatalf("boot ROM changed to %02X by a write", value)
+	}
+	m.Write(BootRomDisableAddress, 1)
+	if value := m.Read(0x0000); value != 0x00 {
+		t.Errorf("cartridge read %02X, want 00", value)
+	}
+}
+
+func TestROMOnlyIsReadOnly(t *testing.T) {
+	tests := []struct {
+		name    string
+		address uint16
+	}{
+		{"bank 0", 0x2000},
+		{"bank 1", 0x4000},
+		{"last byte", 0x7FFF},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := New()
+			m.Write(BootRomDisableAddress, 1)
+			copy(m.RomBank0[:], bytes.Repeat([]byte{0xC3}, len(m.RomBank0)))
+			copy(m.SwitchableRomBank[:], bytes.Repeat([]byte{0xC3}, len(m.SwitchableRomBank)))
+
+			m.Write(test.address, 0x01)
+			if value := m.Read(test.address); value != 0xC3 {
+				t.Errorf("ROM %04X changed to %02X by a write", test.address, value)
+			}
+		})
+	}
+
+	// the boot ROM is read only too
+	m := New()
+	m.Write(0x0000, 0x01)
+	if value := m.BootRomBank0[0]; value != 0x00 {
+		t.Errorf("boot ROM changed to %02X by a write", value)
+	}
+}
@@ -1,144 +1,183 @@
-package memory
-
-import "fmt"
-
-// TODO: implement MBC1
-
-//https://bgb.bircd.org/pandocs.htm
-
-const (
-	InitialAddress = 0x0000
-
-	RomBank0Size             = 0x4000
-	RomBank0SizeStartAddress = InitialAddress
-
-	SwitchableRomBankSize         = 0x4000
-	SwitchableRomBankStartAddress = RomBank0SizeStartAddress + RomBank0Size
-
-	VideoRamSize         = 0x2000
-	VideoRamStartAddress = SwitchableRomBankStartAddress + SwitchableRomBankSize
-
-	SwitchableRamBankSize         = 0x2000 // external ram
-	SwitchableRamBankStartAddress = VideoRamStartAddress + VideoRamSize
-
-	InternalRamSize         = 0x1000
-	InternalRamStartAddress = SwitchableRamBankStartAddress + SwitchableRamBankSize
-
-	SwitchableRamSize         = 0x1000 // WRAM Bank 1 (or n)
-	SwitchableRamStartAddress = InternalRamStartAddress + InternalRamSize
-
-	EchoInternalRamSize         = 0x1E00
-	EchoInternalRamStartAddress = SwitchableRamStartAddress + SwitchableRamSize
-
-	OAMSize         = 0xA0
-	OAMStartAddress = EchoInternalRamStartAddress + EchoInternalRamSize
-
-	EmptyIO1Size         = 0x60
-	EmptyIO1StartAddress = OAMStartAddress + OAMSize
-
-	IOPortSize         = 0x7F
-	IOPortStartAddress = EmptyIO1StartAddress + EmptyIO1Size
-
-	HighRamSize         = 0x7F // high speed ram
-	HighRamStartAddress = IOPortStartAddress + IOPortSize
-
-	IESize         = 0x0001
-	IEStartAddress = HighRamStartAddress + HighRamSize
-)
-
-// Memory represents the memory system of the GB
-type Memory struct {
-	BootRomBank0      [RomBank0Size]byte
-	RomBank0          [RomBank0Size]byte // reemplace BootRomBank0
-	SwitchableRomBank [SwitchableRomBankSize]byte
-	VideoRam          [VideoRamSize]byte
-	SwitchableRamBank [SwitchableRamBankSize]byte
-	InternalRam       [InternalRamSize]byte
-	SwitchableRam     [SwitchableRamSize]byte
-	EchoInternalRam   [EchoInternalRamSize]byte // not used
-	OAM               [OAMSize]byte
-	EmptyIO1          [EmptyIO1Size]byte // undetermined
-	IOPort            [IOPortSize]byte
-	HighRam           [HighRamSize]byte
-	IE                [IESize]byte // Interrupt Enable Register (IE)
-	Boot              bool         // if is true, the console is booting
-}
-
-// New creates a new Memory instance
-func New() *Memory {
-	// Create a new memory instance
-	m := &Memory{Boot: true}
-
-	return m
-}
-
-// Return the memory address of the requested byte
-func (m *Memory) getMemoryAddress(address uint16) *byte {
-
-	switch {
-	case address < SwitchableRomBankStartAddress:
-		if m.Boot {
-			return &m.BootRomBank0[address]
-		} else {
-			return &m.RomBank0[address]
-		}
-	case address < VideoRamStartAddress:
-		return &m.SwitchableRomBank[address-SwitchableRomBankStartAddress]
-	case address < SwitchableRamBankStartAddress:
-		return &m.VideoRam[address-VideoRamStartAddress]
-	case address < InternalRamStartAddress:
-		return &m.SwitchableRamBank[address-SwitchableRamBankStartAddress]
-	case address < SwitchableRamStartAddress:
-		return &m.InternalRam[address-InternalRamStartAddress]
-	case address < EchoInternalRamStartAddress:
-		return &m.SwitchableRam[address-SwitchableRamStartAddress]
-	case address < OAMStartAddress:
-		offset := address - EchoInternalRamStartAddress
-		if offset < InternalRamSize {
-			return &m.InternalRam[offset]
-		} else {
-			return &m.SwitchableRam[offset-InternalRamSize]
-		}
-	case address < EmptyIO1StartAddress:
-		return &m.OAM[address-OAMStartAddress]
-	case address < IOPortStartAddress:
-		return &m.EmptyIO1[address-EmptyIO1StartAddress]
-	case address < HighRamStartAddress:
-		return &m.IOPort[address-IOPortStartAddress]
-	case address < IEStartAddress:
-		return &m.HighRam[address-HighRamStartAddress]
-	case address < 0xFFFF:
-		return &m.IE[0]
-	default:
-		fmt.Printf("Invalid memory address: %x \n", address)
-		panic("Invalid memory address")
-	}
-
-}
-
-// Read returns a byte from the specified memory address
-func (m *Memory) Read(address uint16) byte {
-	return *m.getMemoryAddress(address)
-
-}
-
-// Write writes a byte to the specified memory address
-func (m *Memory) Write(address uint16, value byte) {
-	memoryValue := m.getMemoryAddress(address)
-
-	*memoryValue = value
-}
-
-// ReadWord reads a 16-bit word from the specified memory address
-// gb is little-endian, so the first byte is the low byte
-func (m *Memory) ReadWord(address uint16) uint16 {
-	low := uint16(m.Read(address))
-	high := uint16(m.Read(address + 1))
-	return (high << 8) | low
-}
-
-// WriteWord writes a 16-bit word to the specified memory address
-func (m *Memory) WriteWord(address uint16, value uint16) {
-	m.Write(address, byte(value&0xFF))
-	m.Write(address+1, byte(value>>8))
-}
+package memory
+
+//https://bgb.bircd.org/pandocs.htm
+
+const (
+	InitialAddress = 0x0000
+
+	RomBank0Size             = 0x4000
+	RomBank0SizeStartAddress = InitialAddress
+
+	SwitchableRomBankSize         = 0x4000
+	SwitchableRomBankStartAddress = RomBank0SizeStartAddress + RomBank0Size
+
+	VideoRamSize         = 0x2000
+	VideoRamStartAddress = SwitchableRomBankStartAddress + SwitchableRomBankSize
+
+	SwitchableRamBankSize         = 0x2000 // external ram
+	SwitchableRamBankStartAddress = VideoRamStartAddress + VideoRamSize
+
+	InternalRamSize         = 0x1000
+	InternalRamStartAddress = SwitchableRamBankStartAddress + SwitchableRamBankSize
+
+	SwitchableRamSize         = 0x1000 // WRAM Bank 1 (or n)
+	SwitchableRamStartAddress = InternalRamStartAddress + InternalRamSize
+
+	EchoInternalRamSize         = 0x1E00
+	EchoInternalRamStartAddress = SwitchableRamStartAddress + SwitchableRamSize
+
+	OAMSize         = 0xA0
+	OAMStartAddress = EchoInternalRamStartAddress + EchoInternalRamSize
+
+	EmptyIO1Size         = 0x60
+	EmptyIO1StartAddress = OAMStartAddress + OAMSize
+
+	IOPortSize         = 0x80
+	IOPortStartAddress = EmptyIO1StartAddress + EmptyIO1Size
+
+	HighRamSize         = 0x7F // high speed ram
+	HighRamStartAddress = IOPortStartAddress + IOPortSize
+
+	IESize         = 0x0001
+	IEStartAddress = HighRamStartAddress + HighRamSize
+)
+
+// Memory represents the memory system of the GB
+type Memory struct {
+	BootRomBank0      [RomBank0Size]byte
+	RomBank0          [RomBank0Size]byte // reemplace BootRomBank0
+	SwitchableRomBank [SwitchableRomBankSize]byte
+	VideoRam          [VideoRamSize]byte
+	VideoRamBank1     [VideoRamSize]byte // CGB only, selected with VBK
+	SwitchableRamBank [SwitchableRamBankSize]byte
+	InternalRam       [InternalRamSize]byte
+	SwitchableRam     [SwitchableRamSize]byte
+	SwitchableRamCGB  [6][SwitchableRamSize]byte // CGB WRAM banks 2-7, selected with SVBK
+	EchoInternalRam   [EchoInternalRamSize]byte  // not used
+	OAM               [OAMSize]byte
+	EmptyIO1          [EmptyIO1Size]byte // undetermined
+	IOPort            [IOPortSize]byte
+	HighRam           [HighRamSize]byte
+	IE                [IESize]byte // Interrupt Enable Register (IE)
+	Boot              bool         // if is true, the console is booting
+	CGB               bool         // Game Boy Color mode, enables the CGB banking registers
+	VideoRamBank      byte         // VRAM bank mapped at 0x8000 (CGB)
+	DoubleSpeed       bool         // CGB double speed mode, the CPU runs at 8 MHz
+	SpeedSwitchArmed  bool         // KEY1 bit 0, the next STOP switches the speed
+	WorkRamBank       byte         // WRAM bank mapped at 0xD000, 1-7 (CGB)
+	Undocumented      [4]byte      // CGB registers 0xFF72-0xFF75
+
+	ioDevices [IOPortSize]IODevice // hardware registers mapped with MapIO
+	cartridge IODevice             // memory bank controller mapped with MapCartridge
+}
+
+// New creates a new Memory instance
+func New() *Memory {
+	// Create a new memory instance
+	m := &Memory{Boot: true}
+
+	return m
+}
+
+// Return the memory address of the requested byte
+func (m *Memory) getMemoryAddress(address uint16) *byte {
+
+	switch {
+	case address < SwitchableRomBankStartAddress:
+		if m.Boot {
+			return &m.BootRomBank0[address]
+		} else {
+			return &m.RomBank0[address]
+		}
+	case address < VideoRamStartAddress:
+		return &m.SwitchableRomBank[address-SwitchableRomBankStartAddress]
+	case address < SwitchableRamBankStartAddress:
+		if m.VideoRamBank == 1 {
+			return &m.VideoRamBank1[address-VideoRamStartAddress]
+		}
+		return &m.VideoRam[address-VideoRamStartAddress]
+	case address < InternalRamStartAddress:
+		return &m.SwitchableRamBank[address-SwitchableRamBankStartAddress]
+	case address < SwitchableRamStartAddress:
+		return &m.InternalRam[address-InternalRamStartAddress]
+	case address < EchoInternalRamStartAddress:
+		return m.switchableRam(address - SwitchableRamStartAddress)
+	case address < OAMStartAddress:
+		offset := address - EchoInternalRamStartAddress
+		if offset < InternalRamSize {
+			return &m.InternalRam[offset]
+		} else {
+			return m.switchableRam(offset - InternalRamSize)
+		}
+	case address < EmptyIO1StartAddress:
+		return &m.OAM[address-OAMStartAddress]
+	case address < IOPortStartAddress:
+		return &m.EmptyIO1[address-EmptyIO1StartAddress]
+	case address < HighRamStartAddress:
+		return &m.IOPort[address-IOPortStartAddress]
+	case address < IEStartAddress:
+		return &m.HighRam[address-HighRamStartAddress]
+	default:
+		return &m.IE[0]
+	}
+
+}
+
+// switchableRam returns a byte of the WRAM bank mapped at 0xD000
+func (m *Memory) switchableRam(offset uint16) *byte {
+	if m.WorkRamBank > 1 {
+		return &m.SwitchableRamCGB[m.WorkRamBank-2][offset]
+	}
+	return &m.SwitchableRam[offset]
+}
+
+// Read returns a byte from the specified memory address
+func (m *Memory) Read(address uint16) byte {
+	if device := m.ioDevice(address); device != nil {
+		return device.ReadIO(address)
+	}
+	if cartridge := m.cartridgeDevice(address, false); cartridge != nil {
+		return cartridge.ReadIO(address)
+	}
+
+	return *m.getMemoryAddress(address)
+
+}
+
+// Write writes a byte to the specified memory address
+func (m *Memory) Write(address uint16, value byte) {
+	if device := m.ioDevice(address); device != nil {
+		device.WriteIO(address, value)
+		return
+	}
+	if cartridge := m.cartridgeDevice(address, true); cartridge != nil {
+		cartridge.WriteIO(address, value)
+		return
+	}
+	if address == BootRomDisableAddress && value != 0 {
+		m.Boot = false
+	}
+	// without a controller the ROM is read only, ROM-only games still write
+	// bank numbers to it
+	if address < VideoRamStartAddress {
+		return
+	}
+
+	memoryValue := m.getMemoryAddress(address)
+
+	*memoryValue = value
+}
+
+// ReadWord reads a 16-bit word from the specified memory address
+// gb is little-endian, so the first byte is the low byte
+func (m *Memory) ReadWord(address uint16) uint16 {
+	low := uint16(m.Read(address))
+	high := uint16(m.Read(address + 1))
+	return (high << 8) | low
+}
+
+// WriteWord writes a 16-bit word to the specified memory address
+func (m *Memory) WriteWord(address uint16, value uint16) {
+	m.Write(address, byte(value&0xFF))
+	m.Write(address+1, byte(value>>8))
+}
@@ -0,0 +1,55 @@
+package memory
+
+import "gb-emulator/internal/savestate"
+
+// SaveState writes every RAM region, the I/O area and the banking registers.
+// The ROM comes from the cartridge and isn't saved
+func (m *Memory) SaveState(e *savestate.Encoder) {
+	e.Bytes(m.VideoRam[:])
+	e.Bytes(m.VideoRamBank1[:])
+	e.Bytes(m.SwitchableRamBank[:])
+	e.Bytes(m.InternalRam[:])
+	e.Bytes(m.SwitchableRam[:])
+	for i := range m.SwitchableRamCGB {
+		e.Bytes(m.SwitchableRamCGB[i][:])
+	}
+	e.Bytes(m.OAM[:])
+	e.Bytes(m.EmptyIO1[:])
+	e.Bytes(m.IOPort[:])
+	e.Bytes(m.HighRam[:])
+	e.Bytes(m.IE[:])
+
+	e.Bool(m.Boot)
+	e.Bool(m.CGB)
+	e.Uint8(m.VideoRamBank)
+	e.Bool(m.DoubleSpeed)
+	e.Bool(m.SpeedSwitchArmed)
+	e.Uint8(m.WorkRamBank)
+	e.Bytes(m.Undocumented[:])
+}
+
+// LoadState restores the state written by SaveState, a bank number out of
+// range is a damaged file
+func (m *Memory) LoadState(d *savestate.Decoder) {
+	d.Bytes(m.VideoRam[:])
+	d.Bytes(m.VideoRamBank1[:])
+	d.Bytes(m.SwitchableRamBank[:])
+	d.Bytes(m.InternalRam[:])
+	d.Bytes(m.SwitchableRam[:])
+	for i := range m.SwitchableRamCGB {
+		d.Bytes(m.SwitchableRamCGB[i][:])
+	}
+	d.Bytes(m.OAM[:])
+	d.Bytes(m.EmptyIO1[:])
+	d.Bytes(m.IOPort[:])
+	d.Bytes(m.HighRam[:])
+	d.Bytes(m.IE[:])
+
+	m.Boot = d.Bool()
+	m.CGB = d.Bool()
+	m.VideoRamBank = d.Index(2)
+	m.DoubleSpeed = d.Bool()
+	m.SpeedSwitchArmed = d.Bool()
+	m.WorkRamBank = d.Index(len(m.SwitchableRamCGB) + 2)
+	d.Bytes(m.Undocumented[:])
+}
@@ -0,0 +1,147 @@
+package ppu
+
+import (
+	"image/color"
+
+	"gb-emulator/internal/memory"
+)
+
+// Documentation
+// * https://gbdev.io/pandocs/Palettes.html#lcd-color-palettes-cgb-only
+// * https://gbdev.io/pandocs/Tile_Maps.html#bg-map-attributes-cgb-mode-only
+
+// CGB only I/O registers owned by the PPU
+const (
+	BCPSAddress = 0xFF68 // BG palette specification
+	BCPDAddress = 0xFF69 // BG palette data
+	OCPSAddress = 0xFF6A // OBJ palette specification
+	OCPDAddress = 0xFF6B // OBJ palette data
+	OPRIAddress = 0xFF6C // OBJ priority mode
+)
+
+const (
+	cgbPaletteRAMSize   = 64 // 8 palettes * 4 colours * 2 bytes
+	paletteAutoIncrease = 1 << 7
+
+	bgAttrPalette    = 0x07
+	bgAttrBank       = 1 << 3
+	bgAttrXFlip      = 1 << 5
+	bgAttrYFlip      = 1 << 6
+	bgAttrPriority   = 1 << 7
+	spriteAttrBank   = 1 << 3
+	spriteAttrCGBPal = 0x07
+)
+
+// cgbPaletteRAM is one of the two CGB colour palette memories, accessed
+// through a specification register (index + auto increment) and a data register
+type cgbPaletteRAM struct {
+	data          [cgbPaletteRAMSize]byte
+	index         byte
+	autoIncrement bool
+}
+
+func (r *cgbPaletteRAM) readSpec() byte {
+	spec := 0x40 | r.index
+	if r.autoIncrement {
+		spec |= paletteAutoIncrease
+	}
+	return spec
+}
+
+func (r *cgbPaletteRAM) writeSpec(value byte) {
+	r.index = value & 0x3F
+	r.autoIncrement = value&paletteAutoIncrease != 0
+}
+
+func (r *cgbPaletteRAM) readData() byte {
+	return r.data[r.index]
+}
+
+func (r *cgbPaletteRAM) writeData(value byte) {
+	r.data[r.index] = value
+	if r.autoIncrement {
+		r.index = (r.index + 1) & 0x3F
+	}
+}
+
+// colour returns the BGR555 value of a colour in one of the eight palettes
+func (r *cgbPaletteRAM) colour(palette byte, colourIndex byte) uint16 {
+	offset := int(palette)*8 + int(colourIndex)*2
+	return uint16(r.data[offset]) | uint16(r.data[offset+1])<<8
+}
+
+// EnableCGB switches the PPU to Game Boy Color rendering and maps the CGB
+// palette registers
+func (p *PPU) EnableCGB() {
+	p.CGB = true
+	p.mem.MapIO(BCPSAddress, OPRIAddress, p)
+	p.mem.MapIO(HDMA1Address, HDMA5Address, &p.HDMA)
+}
+
+// readCGBIO handles the reads of the CGB registers
+func (p *PPU) readCGBIO(address uint16) byte {
+	switch address {
+	case BCPSAddress:
+		return p.bgPalettes.readSpec()
+	case BCPDAddress:
+		return p.bgPalettes.readData()
+	case OCPSAddress:
+		return p.objPalettes.readSpec()
+	case OCPDAddress:
+		return p.objPalettes.readData()
+	case OPRIAddress:
+		return 0xFE | p.OPRI
+	}
+
+	return 0xFF
+}
+
+// writeCGBIO handles the writes of the CGB registers
+func (p *PPU) writeCGBIO(address uint16, value byte) {
+	switch address {
+	case BCPSAddress:
+		p.bgPalettes.writeSpec(value)
+	case BCPDAddress:
+		p.bgPalettes.writeData(value)
+	case OCPSAddress:
+		p.objPalettes.writeSpec(value)
+	case OCPDAddress:
+		p.objPalettes.writeData(value)
+	case OPRIAddress:
+		p.OPRI = value & 0x01
+	}
+}
+
+// BGR555 converts a 15-bit CGB/SGB colour to RGB as is, the 5-bit channels
+// are expanded to 8 bits
+func BGR555(value uint16) color.RGBA {
+	r := byte(value & 0x1F)
+	g := byte(value >> 5 & 0x1F)
+	b := byte(value >> 10 & 0x1F)
+
+	return color.RGBA{R: r<<3 | r>>2, G: g<<3 | g>>2, B: b<<3 | b>>2, A: 0xFF}
+}
+
+// cgbColour converts a BGR555 colour to RGB, optionally mimicking the
+// washed out colours of the CGB LCD
+func (p *PPU) cgbColour(value uint16) color.RGBA {
+	if !p.ColourCorrection {
+		return BGR555(value)
+	}
+
+	r := uint32(value & 0x1F)
+	g := uint32(value >> 5 & 0x1F)
+	b := uint32(value >> 10 & 0x1F)
+
+	// the same channel mix used by higan/bsnes for the CGB LCD
+	correctedR := min(960, r*26+g*4+b*2) >> 2
+	correctedG := min(960, g*24+b*8) >> 2
+	correctedB := min(960, r*6+g*4+b*22) >> 2
+
+	return color.RGBA{R: byte(correctedR), G: byte(correctedG), B: byte(correctedB), A: 0xFF}
+}
+
+// vramBank reads a byte from the given VRAM bank
+func (p *PPU) vramBank(bank byte, address uint16) byte {
+	return p.mem.VideoRamBanks()[bank&1][address-memory.VideoRamStartAddress]
+}
@@ -0,0 +1,75 @@
+package ppu
+
+import (
+	"image/color"
+	"testing"
+
+	"gb-emulator/internal/memory"
+)
+
+func TestCGBColour(t *testing.T) {
+	tests := []struct {
+		value      uint16
+		correction bool
+		want       color.RGBA
+	}{
+		{0x7FFF, false, testWhite},
+		{0x0000, false, testBlack},
+		{0x001F, false, testRed},
+		{0x7FFF, true, color.RGBA{240, 240, 240, 0xFF}},
+		{0x001F, true, color.RGBA{201, 0, 46, 0xFF}},
+	}
+
+	for _, test := range tests {
+		p := NewPPU(&memory.Memory{})
+		p.ColourCorrection = test.correction
+		if got := p.cgbColour(test.value); got != test.want {
+			t.Errorf("colour %04X with correction %v = %v, want %v", test.value, test.correction, got, test.want)
+		}
+	}
+}
+
+func TestCompatibilityColours(t *testing.T) {
+	p := NewPPU(&memory.Memory{})
+	p.EnableCompatibility(0)
+
+	// the compatibility colours are converted when drawn, so the colour
+	// correction applies to them too
+	plain := p.dmgColour(layerBG, 0)
+	if want := BGR555(compatibilityColours[compatibilityCombinations[0].bg]); plain != want {
+		t.Errorf("compatibility colour = %v, want %v", plain, want)
+	}
+	p.ColourCorrection = true
+	if corrected := p.dmgColour(layerBG, 0); corrected == plain {
+		t.Errorf("compatibility colour %v not corrected", corrected)
+	}
+}
+
+func TestCGBPaletteRAM(t *testing.T) {
+	mem := &memory.Memory{}
+	p := NewPPU(mem)
+	p.EnableCGB()
+
+	// auto increment from colour 1 of palette 2
+	mem.Write(BCPSAddress, paletteAutoIncrease|0x12)
+	mem.Write(BCPDAddress, 0x1F)
+	mem.Write(BCPDAddress, 0x00)
+
+	if spec := mem.Read(BCPSAddress); spec != paletteAutoIncrease|0x40|0x14 {
+		t.Errorf("BCPS %02X after two writes, want %02X", spec, paletteAutoIncrease|0x40|0x14)
+	}
+	if colour := p.bgPalettes.colour(2, 1); colour != 0x001F {
+		t.Errorf("colour %04X, want 001F", colour)
+	}
+
+	// without auto increment the index stays
+	mem.Write(OCPSAddress, 0x3F)
+	mem.Write(OCPDAddress, 0x7F)
+	mem.Write(OCPDAddress, 0x55)
+	if spec := mem.Read(OCPSAddress); spec != 0x40|0x3F {
+		t.Errorf("OCPS %02X, want %02X", spec, 0x40|0x3F)
+	}
+	if value := mem.Read(OCPDAddress); value != 0x55 {
+		t.Errorf("OCPD %02X, want 55", value)
+	}
+}
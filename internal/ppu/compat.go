@@ -0,0 +1,178 @@
+package ppu
+
+// Documentation
+// * https://gbdev.io/pandocs/Power_Up_Sequence.html#compatibility-palettes
+// * https://github.com/LIJI32/SameBoy/blob/master/BootROMs/cgb_boot.asm
+
+// When a CGB runs an original Game Boy cartridge, the boot ROM colourizes it:
+// the sum of the title bytes (plus the 4th letter for ambiguous sums) selects
+// a combination of three palettes for OBJ0, OBJ1 and BG. Unknown games and
+// games not published by Nintendo get combination 0.
+
+// compatibilityColours holds the raw BGR555 palettes of the boot ROM, four colours each.
+// Some combinations start in the middle of a palette, so they are addressed by colour
+var compatibilityColours = [...]uint16{
+	0x7FFF, 0x32BF, 0x00D0, 0x0000,
+	0x639F, 0x4279, 0x15B0, 0x04CB,
+	0x7FFF, 0x6E31, 0x454A, 0x0000,
+	0x7FFF, 0x1BEF, 0x0200, 0x0000,
+	0x7FFF, 0x421F, 0x1CF2, 0x0000,
+	0x7FFF, 0x5294, 0x294A, 0x0000,
+	0x7FFF, 0x03FF, 0x012F, 0x0000,
+	0x7FFF, 0x03EF, 0x01D6, 0x0000,
+	0x7FFF, 0x42B5, 0x3DC8, 0x0000,
+	0x7E74, 0x03FF, 0x0180, 0x0000,
+	0x67FF, 0x77AC, 0x1A13, 0x2D6B,
+	0x7ED6, 0x4BFF, 0x2175, 0x0000,
+	0x53FF, 0x4A5F, 0x7E52, 0x0000,
+	0x4FFF, 0x7ED2, 0x3A4C, 0x1CE0,
+	0x03ED, 0x7FFF, 0x255F, 0x0000,
+	0x036A, 0x021F, 0x03FF, 0x7FFF,
+	0x7FFF, 0x01DF, 0x0112, 0x0000,
+	0x231F, 0x035F, 0x00F2, 0x0009,
+	0x7FFF, 0x03EA, 0x011F, 0x0000,
+	0x299F, 0x001A, 0x000C, 0x0000,
+	0x7FFF, 0x027F, 0x001F, 0x0000,
+	0x7FFF, 0x03E0, 0x0206, 0x0120,
+	0x7FFF, 0x7EEB, 0x001F, 0x7C00,
+	0x7FFF, 0x3FFF, 0x7E00, 0x001F,
+	0x7FFF, 0x03FF, 0x001F, 0x0000,
+	0x03FF, 0x001F, 0x000C, 0x0000,
+	0x7FFF, 0x033F, 0x0193, 0x0000,
+	0x0000, 0x4200, 0x037F, 0x7FFF,
+	0x7FFF, 0x7E8C, 0x7C00, 0x0000,
+	0x7FFF, 0x1BEF, 0x6180, 0x0000,
+}
+
+// compatibilityCombination points to the first colour of the OBJ0, OBJ1 and BG palettes
+type compatibilityCombination struct {
+	obj0, obj1, bg int
+}
+
+// comb builds a combination from palette numbers
+func comb(obj0, obj1, bg int) compatibilityCombination {
+	return compatibilityCombination{obj0: obj0 * 4, obj1: obj1 * 4, bg: bg * 4}
+}
+
+// compatibilityCombinations are the palette combinations selectable by the boot ROM
+var compatibilityCombinations = [...]compatibilityCombination{
+	comb(4, 4, 29),
+	comb(18, 18, 18),
+	comb(20, 20, 20),
+	comb(24, 24, 24),
+	comb(9, 9, 9),
+	comb(0, 0, 0),
+	comb(27, 27, 27),
+	comb(5, 5, 5),
+	comb(12, 12, 12),
+	comb(26, 26, 26),
+	comb(16, 8, 8),
+	comb(4, 28, 28),
+	comb(4, 2, 2),
+	comb(3, 4, 4),
+	comb(4, 29, 29),
+	comb(28, 4, 28),
+	comb(2, 17, 2),
+	comb(16, 16, 8),
+	comb(4, 4, 7),
+	comb(4, 4, 18),
+	comb(4, 4, 20),
+	comb(19, 19, 9),
+	{obj0: 4*4 - 1, obj1: 4*4 - 1, bg: 11 * 4},
+	comb(17, 17, 2),
+	comb(4, 4, 2),
+	comb(4, 4, 3),
+	comb(28, 28, 0),
+	comb(3, 3, 0),
+	comb(0, 0, 1),
+	comb(18, 22, 18),
+	comb(20, 22, 20),
+	comb(24, 22, 24),
+	comb(16, 22, 8),
+	comb(17, 4, 13),
+	{obj0: 28*4 - 1, obj1: 0 * 4, bg: 14 * 4},
+	{obj0: 28*4 - 1, obj1: 4 * 4, bg: 15 * 4},
+	comb(19, 22, 9),
+	comb(16, 28, 10),
+	comb(4, 23, 28),
+	comb(17, 22, 2),
+	comb(4, 0, 2),
+	comb(4, 28, 3),
+	comb(28, 3, 0),
+	comb(3, 28, 4),
+	comb(21, 28, 4),
+	comb(3, 28, 0),
+	comb(25, 3, 28),
+	comb(0, 28, 8),
+	comb(4, 3, 28),
+	comb(28, 3, 6),
+	comb(4, 28, 29),
+}
+
+// compatibilityTitleChecksums are the title checksums known by the boot ROM.
+// From compatibilityFirstDuplicate on, the same checksum is shared by several
+// games and the 4th letter of the title tells them apart
+var compatibilityTitleChecksums = [...]byte{
+	0x00, 0x88, 0x16, 0x36, 0xD1, 0xDB, 0xF2, 0x3C, 0x8C, 0x92, 0x3D, 0x5C, 0x58, 0xC9, 0x3E, 0x70,
+	0x1D, 0x59, 0x69, 0x19, 0x35, 0xA8, 0x14, 0xAA, 0x75, 0x95, 0x99, 0x34, 0x6F, 0x15, 0xFF, 0x97,
+	0x4B, 0x90, 0x17, 0x10, 0x39, 0xF7, 0xF6, 0xA2, 0x49, 0x4E, 0x43, 0x68, 0xE0, 0x8B, 0xF0, 0xCE,
+	0x0C, 0x29, 0xE8, 0xB7, 0x86, 0x9A, 0x52, 0x01, 0x9D, 0x71, 0x9C, 0xBD, 0x5D, 0x6D, 0x67, 0x3F,
+	0x6B,
+	// 4th letter needed
+	0xB3, 0x46, 0x28, 0xA5, 0xC6, 0xD3, 0x27, 0x61, 0x18, 0x66, 0x6A, 0xBF, 0x0D, 0xF4, 0xB3, 0x46,
+	0x28, 0xA5, 0xC6, 0xD3, 0x27, 0x61, 0x18, 0x66, 0x6A, 0xBF, 0x0D, 0xF4, 0xB3,
+}
+
+const compatibilityFirstDuplicate = 65
+
+// compatibilityFourthLetters disambiguates the checksums from compatibilityFirstDuplicate on
+const compatibilityFourthLetters = "BEFAARBEKEK R-URAR INAILICE R"
+
+// compatibilityCombinationPerChecksum is the combination used by each entry of compatibilityTitleChecksums
+var compatibilityCombinationPerChecksum = [...]byte{
+	0, 4, 5, 35, 34, 3, 31, 15, 10, 5, 19, 36, 7, 37, 30, 44,
+	21, 32, 31, 20, 5, 33, 13, 14, 5, 29, 5, 18, 9, 3, 2, 26,
+	25, 25, 41, 42, 26, 45, 42, 45, 36, 38, 26, 42, 30, 41, 34, 34,
+	5, 42, 6, 5, 33, 25, 42, 42, 40, 2, 16, 25, 42, 42, 5, 0,
+	39,
+	36, 22, 25, 6, 32, 12, 36, 11, 39, 18, 39, 24, 31, 50, 17, 46,
+	6, 27, 0, 47, 41, 41, 0, 0, 19, 34, 23, 18, 29,
+}
+
+// CompatibilityCombination returns the palette combination the CGB boot ROM
+// selects for a Nintendo published game, from its title checksum and the
+// 4th letter of its title
+func CompatibilityCombination(titleChecksum byte, fourthLetter byte) int {
+	for i, checksum := range compatibilityTitleChecksums {
+		if checksum != titleChecksum {
+			continue
+		}
+
+		if i < compatibilityFirstDuplicate || compatibilityFourthLetters[i-compatibilityFirstDuplicate] == fourthLetter {
+			return int(compatibilityCombinationPerChecksum[i])
+		}
+	}
+
+	return 0
+}
+
+// CompatibilityCombinations returns how many palette combinations exist
+func CompatibilityCombinations() int {
+	return len(compatibilityCombinations)
+}
+
+// EnableCompatibility colourizes an original Game Boy game with one of the
+// boot ROM palette combinations, the way a CGB does. The colours are kept in
+// BGR555 and converted when a pixel is drawn, so ColourCorrection applies to
+// them as it does to CGB games
+func (p *PPU) EnableCompatibility(combination int) {
+	if combination < 0 || combination >= len(compatibilityCombinations) {
+		combination = 0
+	}
+
+	selected := compatibilityCombinations[combination]
+	for layer, firstColour := range [...]int{layerBG: selected.bg, layerOBJ0: selected.obj0, layerOBJ1: selected.obj1} {
+		copy(p.bootColours[layer][:], compatibilityColours[firstColour:])
+	}
+	p.Compatibility = true
+}
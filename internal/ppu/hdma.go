@@ -0,0 +1,140 @@
+package ppu
+
+import "gb-emulator/internal/memory"
+
+// Documentation
+// * https://gbdev.io/pandocs/CGB_Registers.html#lcd-vram-dma-transfers
+
+// VRAM DMA registers (CGB only)
+const (
+	HDMA1Address = 0xFF51 // source high
+	HDMA2Address = 0xFF52 // source low
+	HDMA3Address = 0xFF53 // destination high
+	HDMA4Address = 0xFF54 // destination low
+	HDMA5Address = 0xFF55 // length, mode and start
+)
+
+const (
+	hdmaBlockSize       = 0x10
+	hdmaHBlankMode      = 1 << 7
+	hdmaInactive        = 1 << 7 // HDMA5 bit 7 reads 1 when no transfer is running
+	hdmaBlockCycles     = 8      // M-cycles the CPU is halted per block at normal speed
+	hdmaDestinationMask = 0x1FF0
+)
+
+// HDMA copies data into VRAM, either all at once (general purpose DMA) or
+// 16 bytes at the start of every HBlank (HBlank DMA). The CPU is halted while
+// the data is moved
+type HDMA struct {
+	mem        *memory.Memory
+	lcdEnabled func() bool
+
+	source      uint16
+	destination uint16
+	blocks      int  // blocks of 16 bytes left to copy
+	active      bool // an HBlank transfer is running
+	stall       int  // M-cycles the CPU has to wait for the transfers already done
+}
+
+// TakeStall returns the M-cycles the CPU must stay halted for the transfers
+// done since the last call
+func (h *HDMA) TakeStall() int {
+	stall := h.stall
+	h.stall = 0
+	return stall
+}
+
+// Active reports whether an HBlank transfer is in progress
+func (h *HDMA) Active() bool {
+	return h.active
+}
+
+// ReadIO implements memory.IODevice
+func (h *HDMA) ReadIO(address uint16) byte {
+	if address != HDMA5Address {
+		return 0xFF // HDMA1-HDMA4 are write only
+	}
+
+	if h.blocks == 0 {
+		return 0xFF
+	}
+
+	length := byte(h.blocks-1) & 0x7F
+	if !h.active {
+		return hdmaInactive | length // cancelled HBlank transfer
+	}
+	return length
+}
+
+// WriteIO implements memory.IODevice
+func (h *HDMA) WriteIO(address uint16, value byte) {
+	switch address {
+	case HDMA1Address:
+		h.source = uint16(value)<<8 | h.source&0x00FF
+	case HDMA2Address:
+		h.source = h.source&0xFF00 | uint16(value&0xF0)
+	case HDMA3Address:
+		h.destination = uint16(value)<<8 | h.destination&0x00FF
+	case HDMA4Address:
+		h.destination = h.destination&0xFF00 | uint16(value&0xF0)
+	case HDMA5Address:
+		h.start(value)
+	}
+}
+
+func (h *HDMA) start(value byte) {
+	// writing bit 7 = 0 during an HBlank transfer cancels it
+	if h.active && value&hdmaHBlankMode == 0 {
+		h.active = false
+		return
+	}
+
+	h.blocks = int(value&0x7F) + 1
+
+	if value&hdmaHBlankMode == 0 {
+		// general purpose DMA, the whole transfer happens now
+		for h.blocks > 0 {
+			h.copyBlock()
+		}
+		return
+	}
+
+	h.active = true
+
+	// with the LCD off there is no HBlank, the first block is copied right away
+	if !h.lcdEnabled() {
+		h.hblank()
+	}
+}
+
+// hblank is called by the PPU when it enters HBlank on a visible line, or
+// when an HBlank transfer is started while the LCD is off
+func (h *HDMA) hblank() {
+	if !h.active {
+		return
+	}
+
+	h.copyBlock()
+	if h.blocks == 0 {
+		h.active = false
+	}
+}
+
+// copyBlock moves 16 bytes into the VRAM bank selected by VBK
+func (h *HDMA) copyBlock() {
+	for i := range uint16(hdmaBlockSize) {
+		value := h.mem.Read(h.source + i)
+		h.mem.Write(memory.VideoRamStartAddress|(h.destination&hdmaDestinationMask+i)&0x1FFF, value)
+	}
+
+	h.source += hdmaBlockSize
+	h.destination += hdmaBlockSize
+	h.blocks--
+
+	// the copy takes the same time at both speeds, so twice the M-cycles in double speed
+	if h.mem.DoubleSpeed {
+		h.stall += hdmaBlockCycles * 2
+	} else {
+		h.stall += hdmaBlockCycles
+	}
+}
@@ -0,0 +1,140 @@
+package ppu
+
+import (
+	"testing"
+
+	"gb-emulator/internal/memory"
+)
+
+// newHDMATest returns a CGB PPU with the LCD on or off and 0x40 bytes of
+// numbered data at 0xC000
+func newHDMATest(lcdOn bool) (*PPU, *memory.Memory) {
+	mem := memory.New()
+	mem.Boot = false
+	mem.EnableCGB()
+	p := NewPPU(mem)
+	p.EnableCGB()
+	if lcdOn {
+		p.LCDC |= lcdcDisplayEnabled
+	}
+
+	for i := range uint16(0x40) {
+		mem.Write(memory.InternalRamStartAddress+i, byte(i+1))
+	}
+
+	// source 0xC000, destination 0x8100, the low nibbles are ignored
+	mem.Write(HDMA1Address, 0xC0)
+	mem.Write(HDMA2Address, 0x0F)
+	mem.Write(HDMA3Address, 0x81)
+	mem.Write(HDMA4Address, 0x0F)
+	return p, mem
+}
+
+// copied returns how many bytes of the data reached 0x8100 of the VRAM bank
+func copied(vram *[memory.VideoRamSize]byte) int {
+	count := 0
+	for i := range 0x40 {
+		if vram[0x100+i] == byte(i+1) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestGeneralPurposeDMA(t *testing.T) {
+	tests := []struct {
+		doubleSpeed bool
+		wantStall   int
+	}{
+		{false, 2 * hdmaBlockCycles},
+		{true, 4 * hdmaBlockCycles},
+	}
+
+	for _, test := range tests {
+		p, mem := newHDMATest(true)
+		mem.DoubleSpeed = test.doubleSpeed
+
+		mem.Write(HDMA5Address, 0x01) // two blocks
+
+		if count := copied(&mem.VideoRam); count != 0x20 {
+			t.Errorf("%d bytes copied, want 32", count)
+		}
+		if value := mem.Read(HDMA5Address); value != 0xFF {
+			t.Errorf("HDMA5 %02X after the transfer, want FF", value)
+		}
+		if stall := p.HDMA.TakeStall(); stall != test.wantStall {
+			t.Errorf("double speed %v: CPU halted %d M-cycles, want %d", test.doubleSpeed, stall, test.wantStall)
+		}
+	}
+}
+
+func TestGeneralPurposeDMAToBank1(t *testing.T) {
+	_, mem := newHDMATest(true)
+	mem.Write(memory.VBKAddress, 1)
+
+	mem.Write(HDMA5Address, 0x00)
+
+	if count := copied(&mem.VideoRamBank1); count != 0x10 {
+		t.Errorf("%d bytes copied to bank 1, want 16", count)
+	}
+	if count := copied(&mem.VideoRam); count != 0 {
+		t.Errorf("%d bytes copied to bank 0, want 0", count)
+	}
+}
+
+func TestHBlankDMA(t *testing.T) {
+	p, mem := newHDMATest(true)
+
+	mem.Write(HDMA5Address, hdmaHBlankMode|0x02) // three blocks
+	if count := copied(&mem.VideoRam); count != 0 {
+		t.Fatalf("%d bytes copied before the HBlank, want 0", count)
+	}
+
+	for block := 1; block <= 3; block++ {
+		p.setMode(ModeHBlank)
+		if count := copied(&mem.VideoRam); count != block*hdmaBlockSize {
+			t.Errorf("HBlank %d: %d bytes copied, want %d", block, count, block*hdmaBlockSize)
+		}
+		// HDMA5 holds the blocks left minus one while the transfer runs
+		if left := 3 - block; left > 0 {
+			if value := mem.Read(HDMA5Address); value != byte(left-1) {
+				t.Errorf("HBlank %d: HDMA5 %02X, want %02X", block, value, left-1)
+			}
+		}
+	}
+
+	if value := mem.Read(HDMA5Address); value != 0xFF {
+		t.Errorf("HDMA5 %02X after the transfer, want FF", value)
+	}
+	if p.HDMA.Active() {
+		t.Error("transfer still active")
+	}
+}
+
+func TestHBlankDMACancel(t *testing.T) {
+	p, mem := newHDMATest(true)
+
+	mem.Write(HDMA5Address, hdmaHBlankMode|0x02)
+	p.setMode(ModeHBlank)
+	mem.Write(HDMA5Address, 0x00)
+
+	// bit 7 set and the blocks left
+	if value := mem.Read(HDMA5Address); value != hdmaInactive|0x01 {
+		t.Errorf("HDMA5 %02X after cancelling, want %02X", value, hdmaInactive|0x01)
+	}
+
+	p.setMode(ModeHBlank)
+	if count := copied(&mem.VideoRam); count != hdmaBlockSize {
+		t.Errorf("%d bytes copied, want only the first block", count)
+	}
+}
+
+func TestHBlankDMAWithLCDOff(t *testing.T) {
+	_, mem := newHDMATest(false)
+
+	mem.Write(HDMA5Address, hdmaHBlankMode|0x01)
+
+	if count := copied(&mem.VideoRam); count != hdmaBlockSize {
+		t.Errorf("%d bytes copied with the LCD off, want the first block", count)
+	}
+}
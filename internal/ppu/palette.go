@@ -0,0 +1,199 @@
+package ppu
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Documentation
+// * https://gbdev.io/pandocs/Palettes.html
+
+// Palette maps the four DMG shades (0 = lightest, 3 = darkest) to RGB colours
+type Palette [4]color.RGBA
+
+// DMGPalette holds separate colours for the background/window and for both
+// object palettes, the same way the CGB colourizes original Game Boy games
+type DMGPalette struct {
+	BG   Palette
+	OBJ0 Palette
+	OBJ1 Palette
+}
+
+// DefaultPreset is the palette used when nothing else is selected
+const DefaultPreset = "green"
+
+// Presets contains the built-in palettes, selectable by name
+var Presets = map[string]DMGPalette{
+	"green":    uniformPalette(Palette{rgb(0x9BBC0F), rgb(0x8BAC0F), rgb(0x306230), rgb(0x0F380F)}), // original DMG screen
+	"pocket":   uniformPalette(Palette{rgb(0xC4CFA1), rgb(0x8B956D), rgb(0x4D533C), rgb(0x1F1F1F)}), // Game Boy Pocket grey
+	"light":    uniformPalette(Palette{rgb(0x00B581), rgb(0x009A71), rgb(0x00694A), rgb(0x004F3B)}), // Game Boy Light backlight
+	"contrast": uniformPalette(Palette{rgb(0xFFFFFF), rgb(0xA8A8A8), rgb(0x545454), rgb(0x000000)}), // high contrast
+}
+
+// rgb builds an opaque colour from a 0xRRGGBB value
+func rgb(value uint32) color.RGBA {
+	return color.RGBA{R: byte(value >> 16), G: byte(value >> 8), B: byte(value), A: 0xFF}
+}
+
+// uniformPalette uses the same colours for the background and both object palettes
+func uniformPalette(palette Palette) DMGPalette {
+	return DMGPalette{BG: palette, OBJ0: palette, OBJ1: palette}
+}
+
+// ParsePalette parses a custom palette written as four comma separated RGB
+// hex colours ordered from lightest to darkest, e.g. "#E0F8D0,#88C070,#346856,#081820"
+func ParsePalette(s string) (Palette, error) {
+	var palette Palette
+
+	colours := strings.Split(s, ",")
+	if len(colours) != len(palette) {
+		return palette, fmt.Errorf("palette %q must have %d colours, got %d", s, len(palette), len(colours))
+	}
+
+	for i, colour := range colours {
+		colour = strings.TrimPrefix(strings.TrimSpace(colour), "#")
+		if len(colour) != 6 {
+			return palette, fmt.Errorf("invalid colour %q: expected RRGGBB", colours[i])
+		}
+
+		value, err := strconv.ParseUint(colour, 16, 32)
+		if err != nil {
+			return palette, fmt.Errorf("invalid colour %q: %w", colours[i], err)
+		}
+
+		palette[i] = rgb(uint32(value))
+	}
+
+	return palette, nil
+}
+
+// ParseDMGPalette accepts the name of a preset, a single custom palette used
+// for every layer, or three custom palettes separated by ';' for BG, OBJ0 and OBJ1
+func ParseDMGPalette(s string) (DMGPalette, error) {
+	if preset, exists := Presets[strings.ToLower(strings.TrimSpace(s))]; exists {
+		return preset, nil
+	}
+
+	layers := strings.Split(s, ";")
+	switch len(layers) {
+	case 1:
+		palette, err := ParsePalette(layers[0])
+		if err != nil {
+			return DMGPalette{}, err
+		}
+		return uniformPalette(palette), nil
+	case 3:
+		var palettes [3]Palette
+		for i, layer := range layers {
+			palette, err := ParsePalette(layer)
+			if err != nil {
+				return DMGPalette{}, err
+			}
+			palettes[i] = palette
+		}
+		return DMGPalette{BG: palettes[0], OBJ0: palettes[1], OBJ1: palettes[2]}, nil
+	default:
+		return DMGPalette{}, fmt.Errorf("unknown palette %q: use a preset name, one palette or three palettes separated by ';'", s)
+	}
+}
+
+// PaletteOverrides selects a palette per game. Games are identified by the
+// title of the cartridge, by its header checksum, or by the title and the
+// global checksum to tell apart versions and different games sharing a title.
+// When several entries match, the title with the global checksum wins over
+// the header checksum, and that one over the title
+type PaletteOverrides struct {
+	byTitle    map[string]DMGPalette
+	byChecksum map[byte]DMGPalette
+	byGame     map[gameKey]DMGPalette
+}
+
+// gameKey identifies a single cartridge
+type gameKey struct {
+	title          string
+	globalChecksum uint16
+}
+
+// NewPaletteOverrides creates an empty set of per-game palettes
+func NewPaletteOverrides() *PaletteOverrides {
+	return &PaletteOverrides{
+		byTitle:    make(map[string]DMGPalette),
+		byChecksum: make(map[byte]DMGPalette),
+		byGame:     make(map[gameKey]DMGPalette),
+	}
+}
+
+// ParsePaletteOverrides reads per-game palettes written as "TITLE", "#XX"
+// with the header checksum in hexadecimal, or "TITLE:XXXX" with the global
+// checksum in hexadecimal, mapped to a palette in the format of ParseDMGPalette
+func ParsePaletteOverrides(entries map[string]string) (*PaletteOverrides, error) {
+	overrides := NewPaletteOverrides()
+
+	for game, value := range entries {
+		palette, err := ParseDMGPalette(value)
+		if err != nil {
+			return nil, fmt.Errorf("palette of %q: %w", game, err)
+		}
+
+		if checksum, found := strings.CutPrefix(game, "#"); found {
+			headerChecksum, err := strconv.ParseUint(checksum, 16, 8)
+			if err != nil || len(checksum) != 2 {
+				return nil, fmt.Errorf("invalid game %q: expected TITLE, #XX or TITLE:XXXX", game)
+			}
+			overrides.SetChecksum(byte(headerChecksum), palette)
+			continue
+		}
+
+		title, checksum, found := strings.Cut(game, ":")
+		if !found {
+			overrides.SetTitle(title, palette)
+			continue
+		}
+
+		globalChecksum, err := strconv.ParseUint(checksum, 16, 16)
+		if err != nil || len(checksum) != 4 {
+			return nil, fmt.Errorf("invalid game %q: expected TITLE, #XX or TITLE:XXXX", game)
+		}
+		overrides.SetGame(title, uint16(globalChecksum), palette)
+	}
+
+	return overrides, nil
+}
+
+// SetTitle assigns a palette to every cartridge with the given header title
+func (o *PaletteOverrides) SetTitle(title string, palette DMGPalette) {
+	o.byTitle[strings.ToUpper(title)] = palette
+}
+
+// SetChecksum assigns a palette to every cartridge with the given header
+// checksum
+func (o *PaletteOverrides) SetChecksum(headerChecksum byte, palette DMGPalette) {
+	o.byChecksum[headerChecksum] = palette
+}
+
+// SetGame assigns a palette to the cartridge with the given title and global
+// checksum
+func (o *PaletteOverrides) SetGame(title string, globalChecksum uint16, palette DMGPalette) {
+	o.byGame[gameKey{strings.ToUpper(title), globalChecksum}] = palette
+}
+
+// Lookup returns the palette configured for a cartridge, if any
+func (o *PaletteOverrides) Lookup(title string, headerChecksum byte, globalChecksum uint16) (DMGPalette, bool) {
+	title = strings.ToUpper(title)
+	if palette, exists := o.byGame[gameKey{title, globalChecksum}]; exists {
+		return palette, true
+	}
+	if palette, exists := o.byChecksum[headerChecksum]; exists {
+		return palette, true
+	}
+
+	palette, exists := o.byTitle[title]
+	return palette, exists
+}
+
+// decodeShade applies a BGP/OBP0/OBP1 register to a 2-bit colour index
+func decodeShade(register byte, colourIndex byte) byte {
+	return (register >> (colourIndex * 2)) & 0x03
+}
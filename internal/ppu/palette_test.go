@@ -0,0 +1,135 @@
+package ppu
+
+import (
+	"image/color"
+	"testing"
+
+	"gb-emulator/internal/memory"
+)
+
+var (
+	testWhite = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	testBlack = color.RGBA{0x00, 0x00, 0x00, 0xFF}
+	testRed   = color.RGBA{0xFF, 0x00, 0x00, 0xFF}
+)
+
+func TestParseDMGPalette(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    DMGPalette
+		wantErr bool
+	}{
+		{input: "pocket", want: Presets["pocket"]},
+		{input: " Contrast ", want: Presets["contrast"]},
+		{input: "#FFFFFF,#FF0000,#FF0000,#000000", want: uniformPalette(Palette{testWhite, testRed, testRed, testBlack})},
+		{
+			input: "FFFFFF,FFFFFF,FFFFFF,FFFFFF;000000,000000,000000,000000;FF0000,FF0000,FF0000,FF0000",
+			want: DMGPalette{
+				BG:   Palette{testWhite, testWhite, testWhite, testWhite},
+				OBJ0: Palette{testBlack, testBlack, testBlack, testBlack},
+				OBJ1: Palette{testRed, testRed, testRed, testRed},
+			},
+		},
+		{input: "sepia", wantErr: true},
+		{input: "#FFFFFF,#000000", wantErr: true},
+		{input: "#FFFFFF,#000000,#000000,#GGGGGG", wantErr: true},
+		{input: "#FFF,#000,#000,#000", wantErr: true},
+		{input: "FFFFFF,FFFFFF,FFFFFF,FFFFFF;000000,000000,000000,000000", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseDMGPalette(test.input)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("ParseDMGPalette(%q) succeeded, want an error", test.input)
+		case !test.wantErr && err != nil:
+			t.Errorf("ParseDMGPalette(%q): %v", test.input, err)
+		case got != test.want:
+			t.Errorf("ParseDMGPalette(%q) = %v, want %v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestPaletteOverrides(t *testing.T) {
+	overrides, err := ParsePaletteOverrides(map[string]string{
+		"tetris":           "contrast",
+		"POKEMON RED":      "green",
+		"POKEMON RED:91E6": "pocket",
+		"#9D":              "light",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		title          string
+		headerChecksum byte
+		globalChecksum uint16
+		want           string // preset, empty when no palette is found
+	}{
+		{"TETRIS", 0x0A, 0x1234, "contrast"},
+		{"POKEMON RED", 0x20, 0x91E6, "pocket"},
+		{"POKEMON RED", 0x20, 0x0000, "green"},
+		{"POKEMON BLUE", 0xD3, 0x91E6, ""},
+		{"DR.MARIO", 0x9D, 0x0000, "light"},
+		{"TETRIS", 0x9D, 0x1234, "light"},       // the header checksum wins over the title
+		{"POKEMON RED", 0x9D, 0x91E6, "pocket"}, // and the global checksum over both
+	}
+
+	for _, test := range tests {
+		palette, exists := overrides.Lookup(test.title, test.headerChecksum, test.globalChecksum)
+		switch {
+		case exists != (test.want != ""):
+			t.Errorf("%s %02X %04X found %v, want %v", test.title, test.headerChecksum, test.globalChecksum, exists, test.want != "")
+		case exists && palette != Presets[test.want]:
+			t.Errorf("%s %02X %04X got %v, want the %s preset", test.title, test.headerChecksum, test.globalChecksum, palette, test.want)
+		}
+	}
+}
+
+func TestParsePaletteOverridesErrors(t *testing.T) {
+	for _, entries := range []map[string]string{
+		{"TETRIS": "sepia"},
+		{"TETRIS:12": "green"},
+		{"TETRIS:12345": "green"},
+		{"TETRIS:XYZW": "green"},
+		{"#9": "green"},
+		{"#9D0": "green"},
+		{"#XY": "green"},
+	} {
+		if _, err := ParsePaletteOverrides(entries); err == nil {
+			t.Errorf("ParsePaletteOverrides(%v) succeeded, want an error", entries)
+		}
+	}
+}
+
+func TestDecodeShade(t *testing.T) {
+	const register = 0b11_10_01_00 // identity
+	for colourIndex := range byte(4) {
+		if shade := decodeShade(register, colourIndex); shade != colourIndex {
+			t.Errorf("identity register: index %d gives shade %d", colourIndex, shade)
+		}
+	}
+
+	const inverted = 0b00_01_10_11
+	for colourIndex := range byte(4) {
+		if shade := decodeShade(inverted, colourIndex); shade != 3-colourIndex {
+			t.Errorf("inverted register: index %d gives shade %d, want %d", colourIndex, shade, 3-colourIndex)
+		}
+	}
+}
+
+func TestDMGColour(t *testing.T) {
+	p := NewPPU(&memory.Memory{})
+	p.Palette = DMGPalette{
+		BG:   Palette{testWhite, testWhite, testWhite, testWhite},
+		OBJ0: Palette{testBlack, testBlack, testBlack, testBlack},
+		OBJ1: Palette{testRed, testRed, testRed, testRed},
+	}
+
+	for layer, want := range map[int]color.RGBA{layerBG: testWhite, layerOBJ0: testBlack, layerOBJ1: testRed} {
+		if got := p.dmgColour(layer, 1); got != want {
+			t.Errorf("layer %d = %v, want %v", layer, got, want)
+		}
+	}
+}
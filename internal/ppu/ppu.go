@@ -0,0 +1,344 @@
+// Package ppu implements the Game Boy Picture Processing Unit
+package ppu
+
+import (
+	"image"
+
+	"gb-emulator/internal/memory"
+)
+
+// Documentation
+// * https://gbdev.io/pandocs/Rendering.html
+// * https://gbdev.io/pandocs/LCDC.html
+// * https://gbdev.io/pandocs/STAT.html
+
+const (
+	ScreenWidth  = 160
+	ScreenHeight = 144
+
+	dotsPerLine    = 456
+	linesPerFrame  = 154
+	DotsPerFrame   = dotsPerLine * linesPerFrame // 70224
+	oamScanDots    = 80
+	drawingDots    = 172
+	vBlankStartsAt = ScreenHeight
+)
+
+// I/O registers owned by the PPU
+const (
+	LCDCAddress = 0xFF40
+	STATAddress = 0xFF41
+	SCYAddress  = 0xFF42
+	SCXAddress  = 0xFF43
+	LYAddress   = 0xFF44
+	LYCAddress  = 0xFF45
+	DMAAddress  = 0xFF46
+	BGPAddress  = 0xFF47
+	OBP0Address = 0xFF48
+	OBP1Address = 0xFF49
+	WYAddress   = 0xFF4A
+	WXAddress   = 0xFF4B
+)
+
+// LCDC bits
+const (
+	lcdcBGEnable       byte = 1 << 0 // DMG: BG and window display, CGB: BG/window master priority
+	lcdcOBJEnable      byte = 1 << 1
+	lcdcOBJSize        byte = 1 << 2 // 0: 8x8, 1: 8x16
+	lcdcBGTileMap      byte = 1 << 3 // 0: 0x9800, 1: 0x9C00
+	lcdcTileData       byte = 1 << 4 // 0: 0x8800 signed, 1: 0x8000 unsigned
+	lcdcWindowEnable   byte = 1 << 5
+	lcdcWindowTileMap  byte = 1 << 6 // 0: 0x9800, 1: 0x9C00
+	lcdcDisplayEnabled byte = 1 << 7
+)
+
+// STAT interrupt sources
+const (
+	statHBlankInterrupt byte = 1 << 3
+	statVBlankInterrupt byte = 1 << 4
+	statOAMInterrupt    byte = 1 << 5
+	statLYCInterrupt    byte = 1 << 6
+	statLYCEqualsLY     byte = 1 << 2
+)
+
+// Mode is the current PPU mode, reported in the lower bits of STAT
+type Mode byte
+
+const (
+	ModeHBlank  Mode = 0
+	ModeVBlank  Mode = 1
+	ModeOAMScan Mode = 2
+	ModeDrawing Mode = 3
+)
+
+// PPU holds the LCD registers and renders the picture one scanline at a time
+type PPU struct {
+	mem *memory.Memory
+
+	LCDC byte
+	STAT byte // only the interrupt enable bits are stored, mode and LYC=LY are computed
+	SCY  byte
+	SCX  byte
+	LY   byte
+	LYC  byte
+	DMA  byte
+	BGP  byte
+	OBP0 byte
+	OBP1 byte
+	WY   byte
+	WX   byte
+
+	// Palette maps the decoded DMG shades of each layer to RGB
+	Palette DMGPalette
+
+	// Compatibility draws the DMG shades with the colours of the CGB boot
+	// ROM picked by EnableCompatibility instead of Palette
+	Compatibility bool
+	bootColours   [3][4]uint16 // BG, OBJ0 and OBJ1 in BGR555
+
+	// CGB mode: VRAM bank 1 attributes and 15-bit colour palettes
+	CGB              bool
+	OPRI             byte // 0: CGB OAM priority, 1: DMG X coordinate priority
+	ColourCorrection bool // mimic the colours of the CGB LCD
+	bgPalettes       cgbPaletteRAM
+	objPalettes      cgbPaletteRAM
+	HDMA             HDMA // VRAM DMA, mapped in CGB mode
+
+	mode       Mode
+	dot        int  // dot inside the current scanline
+	windowLine int  // internal window line counter
+	statLine   bool // STAT interrupt line, interrupts fire on its rising edge
+
+	back        *image.RGBA // frame being drawn
+	front       *image.RGBA // last complete frame
+	backShades  []byte      // DMG shades of the frame being drawn
+	frontShades []byte      // DMG shades of the last complete frame
+	frameReady  bool
+
+	// OnFrame is called when a frame is completed, after Frame and Shades
+	// return it. The SGB colourizes the frame from here
+	OnFrame func()
+}
+
+// NewPPU creates a PPU and maps its registers into memory
+func NewPPU(mem *memory.Memory) *PPU {
+	p := &PPU{
+		mem:     mem,
+		Palette: Presets[DefaultPreset],
+		back:    image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight)),
+		front:   image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight)),
+
+		backShades:  make([]byte, ScreenWidth*ScreenHeight),
+		frontShades: make([]byte, ScreenWidth*ScreenHeight),
+	}
+
+	p.HDMA = HDMA{
+		mem:        mem,
+		lcdEnabled: func() bool { return p.LCDC&lcdcDisplayEnabled != 0 },
+	}
+
+	mem.MapIO(LCDCAddress, WXAddress, p)
+
+	return p
+}
+
+// Step advances the PPU by the given number of dots (T-cycles at normal speed)
+func (p *PPU) Step(dots int) {
+	if p.LCDC&lcdcDisplayEnabled == 0 {
+		return
+	}
+
+	for ; dots > 0; dots-- {
+		p.tick()
+	}
+}
+
+func (p *PPU) tick() {
+	p.dot++
+
+	switch {
+	case p.mode == ModeOAMScan && p.dot == oamScanDots:
+		p.setMode(ModeDrawing)
+	case p.mode == ModeDrawing && p.dot == oamScanDots+drawingDots:
+		p.renderScanline()
+		p.setMode(ModeHBlank)
+	case p.dot == dotsPerLine:
+		p.dot = 0
+		p.nextLine()
+	}
+}
+
+// nextLine moves LY to the next scanline, entering and leaving VBlank as needed
+func (p *PPU) nextLine() {
+	p.LY++
+
+	switch {
+	case p.LY == vBlankStartsAt:
+		p.setMode(ModeVBlank)
+		p.mem.RequestInterrupt(memory.InterruptVBlank)
+		p.back, p.front = p.front, p.back
+		p.backShades, p.frontShades = p.frontShades, p.backShades
+		p.frameReady = true
+		if p.OnFrame != nil {
+			p.OnFrame()
+		}
+	case p.LY == linesPerFrame:
+		p.LY = 0
+		p.windowLine = 0
+		p.setMode(ModeOAMScan)
+	case p.LY < vBlankStartsAt:
+		p.setMode(ModeOAMScan)
+	}
+
+	p.updateStatLine()
+}
+
+func (p *PPU) setMode(mode Mode) {
+	p.mode = mode
+	p.updateStatLine()
+
+	if mode == ModeHBlank && p.CGB {
+		p.HDMA.hblank()
+	}
+}
+
+// updateStatLine recomputes the STAT interrupt line and requests the LCD
+// interrupt on a low to high transition
+func (p *PPU) updateStatLine() {
+	line := (p.STAT&statLYCInterrupt != 0 && p.LY == p.LYC) ||
+		(p.STAT&statHBlankInterrupt != 0 && p.mode == ModeHBlank) ||
+		(p.STAT&statVBlankInterrupt != 0 && p.mode == ModeVBlank) ||
+		(p.STAT&statOAMInterrupt != 0 && p.mode == ModeOAMScan)
+
+	if line && !p.statLine {
+		p.mem.RequestInterrupt(memory.InterruptLCD)
+	}
+	p.statLine = line
+}
+
+// Mode returns the current PPU mode
+func (p *PPU) Mode() Mode {
+	return p.mode
+}
+
+// FrameReady reports whether a new frame was completed since the last call
+func (p *PPU) FrameReady() bool {
+	ready := p.frameReady
+	p.frameReady = false
+	return ready
+}
+
+// Frame returns the last complete frame
+func (p *PPU) Frame() *image.RGBA {
+	return p.front
+}
+
+// Shades returns the DMG shades (0 to 3, after BGP/OBP0/OBP1) of the last
+// complete frame, one byte per pixel from left to right and top to bottom.
+// They are what an original Game Boy sends to the LCD, unused in CGB mode
+func (p *PPU) Shades() []byte {
+	return p.frontShades
+}
+
+// ReadIO implements memory.IODevice
+func (p *PPU) ReadIO(address uint16) byte {
+	if address >= BCPSAddress {
+		return p.readCGBIO(address)
+	}
+
+	switch address {
+	case LCDCAddress:
+		return p.LCDC
+	case STATAddress:
+		stat := 0x80 | p.STAT | byte(p.mode)
+		if p.LY == p.LYC {
+			stat |= statLYCEqualsLY
+		}
+		return stat
+	case SCYAddress:
+		return p.SCY
+	case SCXAddress:
+		return p.SCX
+	case LYAddress:
+		return p.LY
+	case LYCAddress:
+		return p.LYC
+	case DMAAddress:
+		return p.DMA
+	case BGPAddress:
+		return p.BGP
+	case OBP0Address:
+		return p.OBP0
+	case OBP1Address:
+		return p.OBP1
+	case WYAddress:
+		return p.WY
+	case WXAddress:
+		return p.WX
+	}
+
+	return 0xFF
+}
+
+// WriteIO implements memory.IODevice
+func (p *PPU) WriteIO(address uint16, value byte) {
+	if address >= BCPSAddress {
+		p.writeCGBIO(address, value)
+		return
+	}
+
+	switch address {
+	case LCDCAddress:
+		p.writeLCDC(value)
+	case STATAddress:
+		p.STAT = value & 0x78
+		p.updateStatLine()
+	case SCYAddress:
+		p.SCY = value
+	case SCXAddress:
+		p.SCX = value
+	case LYAddress:
+		// read only
+	case LYCAddress:
+		p.LYC = value
+		p.updateStatLine()
+	case DMAAddress:
+		p.DMA = value
+		p.oamDMA(value)
+	case BGPAddress:
+		p.BGP = value
+	case OBP0Address:
+		p.OBP0 = value
+	case OBP1Address:
+		p.OBP1 = value
+	case WYAddress:
+		p.WY = value
+	case WXAddress:
+		p.WX = value
+	}
+}
+
+func (p *PPU) writeLCDC(value byte) {
+	wasEnabled := p.LCDC&lcdcDisplayEnabled != 0
+	p.LCDC = value
+
+	switch enabled := value&lcdcDisplayEnabled != 0; {
+	case wasEnabled && !enabled:
+		// turning the LCD off resets LY and leaves the PPU in HBlank
+		p.LY = 0
+		p.dot = 0
+		p.windowLine = 0
+		p.mode = ModeHBlank
+	case !wasEnabled && enabled:
+		p.setMode(ModeOAMScan)
+	}
+}
+
+// oamDMA copies 160 bytes from source*0x100 into OAM.
+// The copy happens at once instead of one byte per M-cycle
+func (p *PPU) oamDMA(source byte) {
+	base := uint16(source) << 8
+	for i := range uint16(memory.OAMSize) {
+		p.mem.OAM[i] = p.mem.Read(base + i)
+	}
+}
@@ -0,0 +1,290 @@
+package ppu
+
+import (
+	"image/color"
+	"sort"
+
+	"gb-emulator/internal/memory"
+)
+
+// Documentation
+// * https://gbdev.io/pandocs/Tile_Data.html
+// * https://gbdev.io/pandocs/Tile_Maps.html
+// * https://gbdev.io/pandocs/OAM.html
+
+const (
+	tileMap0Address        = 0x9800
+	tileMap1Address        = 0x9C00
+	tileDataUnsignedBase   = 0x8000
+	tileDataSignedBase     = 0x9000
+	bytesPerTile           = 16
+	spritesPerLine         = 10
+	spriteCount            = memory.OAMSize / 4
+	spriteAttrBGPriority   = 1 << 7
+	spriteAttrYFlip        = 1 << 6
+	spriteAttrXFlip        = 1 << 5
+	spriteAttrDMGPalette   = 1 << 4
+	windowXOffset          = 7
+	windowMaxVisibleColumn = ScreenWidth + windowXOffset - 1
+)
+
+// sprite is an OAM entry selected for the current scanline
+type sprite struct {
+	y, x       int
+	tile       byte
+	attributes byte
+	oamIndex   int
+}
+
+// bgPixel keeps what the OBJ priority logic needs to know about a BG/window pixel
+type bgPixel struct {
+	colourIndex byte
+	priority    bool // CGB BG map attribute bit 7
+}
+
+// renderScanline draws the line LY into the back buffer
+func (p *PPU) renderScanline() {
+	var bgPixels [ScreenWidth]bgPixel
+
+	p.renderBackground(&bgPixels)
+	p.renderSprites(&bgPixels)
+}
+
+// renderBackground draws the background and window layers
+func (p *PPU) renderBackground(bgPixels *[ScreenWidth]bgPixel) {
+	// on CGB, LCDC bit 0 only removes the BG priority, the layers are still drawn
+	if !p.CGB && p.LCDC&lcdcBGEnable == 0 {
+		for x := range ScreenWidth {
+			p.setPixel(x, p.dmgColour(layerBG, 0))
+			p.setShade(x, 0)
+		}
+		return
+	}
+
+	windowVisible := p.LCDC&lcdcWindowEnable != 0 && p.LY >= p.WY && p.WX <= windowMaxVisibleColumn
+
+	for x := range ScreenWidth {
+		var mapAddress uint16
+		var pixelX, pixelY byte
+
+		if windowVisible && x+windowXOffset >= int(p.WX) {
+			mapAddress = p.tileMapAddress(lcdcWindowTileMap)
+			pixelX = byte(x + windowXOffset - int(p.WX))
+			pixelY = byte(p.windowLine)
+		} else {
+			mapAddress = p.tileMapAddress(lcdcBGTileMap)
+			pixelX = byte(x) + p.SCX
+			pixelY = p.LY + p.SCY
+		}
+
+		mapEntry := mapAddress + uint16(pixelY/8)*32 + uint16(pixelX/8)
+		tileIndex := p.vramBank(0, mapEntry)
+
+		var attributes byte
+		if p.CGB {
+			attributes = p.vramBank(1, mapEntry)
+		}
+
+		tileX, tileY := pixelX%8, pixelY%8
+		if attributes&bgAttrXFlip != 0 {
+			tileX = 7 - tileX
+		}
+		if attributes&bgAttrYFlip != 0 {
+			tileY = 7 - tileY
+		}
+
+		bank := attributes & bgAttrBank >> 3
+		colourIndex := p.tilePixel(bank, p.bgTileAddress(tileIndex), tileX, tileY)
+
+		bgPixels[x] = bgPixel{colourIndex: colourIndex, priority: attributes&bgAttrPriority != 0}
+		p.setPixel(x, p.bgColour(attributes&bgAttrPalette, colourIndex))
+		p.setShade(x, decodeShade(p.BGP, colourIndex))
+	}
+
+	if windowVisible {
+		p.windowLine++
+	}
+}
+
+// renderSprites draws the objects that intersect the current scanline
+func (p *PPU) renderSprites(bgPixels *[ScreenWidth]bgPixel) {
+	if p.LCDC&lcdcOBJEnable == 0 {
+		return
+	}
+
+	height := 8
+	if p.LCDC&lcdcOBJSize != 0 {
+		height = 16
+	}
+
+	sprites := p.selectSprites(height)
+
+	// on DMG the object with the smaller X wins and ties are resolved by OAM
+	// order, CGB games use the OAM order alone unless OPRI asks otherwise
+	if !p.CGB || p.OPRI&0x01 != 0 {
+		sort.SliceStable(sprites, func(i, j int) bool {
+			return sprites[i].x < sprites[j].x
+		})
+	}
+
+	// CGB: with LCDC bit 0 cleared objects are always drawn over the BG
+	bgMasterPriority := !p.CGB || p.LCDC&lcdcBGEnable != 0
+
+	var covered [ScreenWidth]bool
+	for _, s := range sprites {
+		row := int(p.LY) - s.y
+		if s.attributes&spriteAttrYFlip != 0 {
+			row = height - 1 - row
+		}
+
+		tile := s.tile
+		if height == 16 {
+			tile &= 0xFE
+		}
+		tileAddress := tileDataUnsignedBase + uint16(tile)*bytesPerTile
+
+		var bank byte
+		if p.CGB {
+			bank = s.attributes & spriteAttrBank >> 3
+		}
+
+		for column := range 8 {
+			x := s.x + column
+			if x < 0 || x >= ScreenWidth || covered[x] {
+				continue
+			}
+
+			tileColumn := column
+			if s.attributes&spriteAttrXFlip != 0 {
+				tileColumn = 7 - column
+			}
+
+			colourIndex := p.tilePixel(bank, tileAddress, byte(tileColumn), byte(row))
+			if colourIndex == 0 {
+				continue // transparent
+			}
+			covered[x] = true
+
+			bg := bgPixels[x]
+			behindBG := s.attributes&spriteAttrBGPriority != 0 || bg.priority
+			if bgMasterPriority && behindBG && bg.colourIndex != 0 {
+				continue
+			}
+
+			p.setPixel(x, p.objColour(s.attributes, colourIndex))
+			p.setShade(x, decodeShade(p.objRegister(s.attributes), colourIndex))
+		}
+	}
+}
+
+// bgColour maps a BG/window colour index to RGB
+func (p *PPU) bgColour(cgbPalette byte, colourIndex byte) color.RGBA {
+	if p.CGB {
+		return p.cgbColour(p.bgPalettes.colour(cgbPalette, colourIndex))
+	}
+
+	return p.dmgColour(layerBG, decodeShade(p.BGP, colourIndex))
+}
+
+// objColour maps an object colour index to RGB using the palette selected
+// by the OAM attributes
+func (p *PPU) objColour(attributes byte, colourIndex byte) color.RGBA {
+	switch {
+	case p.CGB:
+		return p.cgbColour(p.objPalettes.colour(attributes&spriteAttrCGBPal, colourIndex))
+	case attributes&spriteAttrDMGPalette != 0:
+		return p.dmgColour(layerOBJ1, decodeShade(p.OBP1, colourIndex))
+	default:
+		return p.dmgColour(layerOBJ0, decodeShade(p.OBP0, colourIndex))
+	}
+}
+
+// DMG layers, in the order of the compatibility colours
+const (
+	layerBG = iota
+	layerOBJ0
+	layerOBJ1
+)
+
+// dmgColour maps a shade of a DMG layer to RGB, with the boot ROM colours
+// in compatibility mode
+func (p *PPU) dmgColour(layer int, shade byte) color.RGBA {
+	if p.Compatibility {
+		return p.cgbColour(p.bootColours[layer][shade])
+	}
+
+	switch layer {
+	case layerOBJ0:
+		return p.Palette.OBJ0[shade]
+	case layerOBJ1:
+		return p.Palette.OBJ1[shade]
+	}
+	return p.Palette.BG[shade]
+}
+
+// objRegister returns the DMG palette register selected by the OAM attributes
+func (p *PPU) objRegister(attributes byte) byte {
+	if attributes&spriteAttrDMGPalette != 0 {
+		return p.OBP1
+	}
+	return p.OBP0
+}
+
+// selectSprites returns the first ten OAM entries that intersect LY
+func (p *PPU) selectSprites(height int) []sprite {
+	sprites := make([]sprite, 0, spritesPerLine)
+
+	for i := 0; i < spriteCount && len(sprites) < spritesPerLine; i++ {
+		entry := p.mem.OAM[i*4 : i*4+4]
+		y := int(entry[0]) - 16
+
+		if int(p.LY) >= y && int(p.LY) < y+height {
+			sprites = append(sprites, sprite{
+				y:          y,
+				x:          int(entry[1]) - 8,
+				tile:       entry[2],
+				attributes: entry[3],
+				oamIndex:   i,
+			})
+		}
+	}
+
+	return sprites
+}
+
+func (p *PPU) tileMapAddress(lcdcBit byte) uint16 {
+	if p.LCDC&lcdcBit != 0 {
+		return tileMap1Address
+	}
+	return tileMap0Address
+}
+
+// bgTileAddress resolves a BG/window tile index using the LCDC addressing mode
+func (p *PPU) bgTileAddress(tileIndex byte) uint16 {
+	if p.LCDC&lcdcTileData != 0 {
+		return tileDataUnsignedBase + uint16(tileIndex)*bytesPerTile
+	}
+	return uint16(int(tileDataSignedBase) + int(int8(tileIndex))*bytesPerTile)
+}
+
+// tilePixel returns the 2-bit colour index of a pixel inside a tile
+func (p *PPU) tilePixel(bank byte, tileAddress uint16, x byte, y byte) byte {
+	low := p.vramBank(bank, tileAddress+uint16(y)*2)
+	high := p.vramBank(bank, tileAddress+uint16(y)*2+1)
+	bit := 7 - x
+
+	return (high>>bit&1)<<1 | low>>bit&1
+}
+
+func (p *PPU) setPixel(x int, colour color.RGBA) {
+	offset := int(p.LY)*p.back.Stride + x*4
+	pixel := p.back.Pix[offset : offset+4 : offset+4]
+	pixel[0] = colour.R
+	pixel[1] = colour.G
+	pixel[2] = colour.B
+	pixel[3] = colour.A
+}
+
+func (p *PPU) setShade(x int, shade byte) {
+	p.backShades[int(p.LY)*ScreenWidth+x] = shade
+}
@@ -0,0 +1,79 @@
+package ppu
+
+import "gb-emulator/internal/savestate"
+
+// SaveState writes the registers, the CGB palettes, the VRAM DMA and the
+// position inside the frame, with the frame being drawn and the last one.
+// The DMG palette and the colour correction are settings, not state
+func (p *PPU) SaveState(e *savestate.Encoder) {
+	e.Bytes([]byte{p.LCDC, p.STAT, p.SCY, p.SCX, p.LY, p.LYC, p.DMA, p.BGP, p.OBP0, p.OBP1, p.WY, p.WX})
+
+	e.Bool(p.CGB)
+	e.Uint8(p.OPRI)
+	p.bgPalettes.saveState(e)
+	p.objPalettes.saveState(e)
+	p.HDMA.saveState(e)
+
+	e.Uint8(byte(p.mode))
+	e.Int(p.dot)
+	e.Int(p.windowLine)
+	e.Bool(p.statLine)
+
+	e.Bytes(p.back.Pix)
+	e.Bytes(p.front.Pix)
+	e.Bytes(p.backShades)
+	e.Bytes(p.frontShades)
+	e.Bool(p.frameReady)
+}
+
+// LoadState restores the state written by SaveState
+func (p *PPU) LoadState(d *savestate.Decoder) {
+	for _, register := range []*byte{&p.LCDC, &p.STAT, &p.SCY, &p.SCX, &p.LY, &p.LYC, &p.DMA, &p.BGP, &p.OBP0, &p.OBP1, &p.WY, &p.WX} {
+		*register = d.Uint8()
+	}
+
+	p.CGB = d.Bool()
+	p.OPRI = d.Uint8()
+	p.bgPalettes.loadState(d)
+	p.objPalettes.loadState(d)
+	p.HDMA.loadState(d)
+
+	p.mode = Mode(d.Uint8())
+	p.dot = d.Int()
+	p.windowLine = d.Int()
+	p.statLine = d.Bool()
+
+	d.Bytes(p.back.Pix)
+	d.Bytes(p.front.Pix)
+	d.Bytes(p.backShades)
+	d.Bytes(p.frontShades)
+	p.frameReady = d.Bool()
+}
+
+func (r *cgbPaletteRAM) saveState(e *savestate.Encoder) {
+	e.Bytes(r.data[:])
+	e.Uint8(r.index)
+	e.Bool(r.autoIncrement)
+}
+
+func (r *cgbPaletteRAM) loadState(d *savestate.Decoder) {
+	d.Bytes(r.data[:])
+	r.index = d.Index(len(r.data))
+	r.autoIncrement = d.Bool()
+}
+
+func (h *HDMA) saveState(e *savestate.Encoder) {
+	e.Uint16(h.source)
+	e.Uint16(h.destination)
+	e.Int(h.blocks)
+	e.Bool(h.active)
+	e.Int(h.stall)
+}
+
+func (h *HDMA) loadState(d *savestate.Decoder) {
+	h.source = d.Uint16()
+	h.destination = d.Uint16()
+	h.blocks = d.Int()
+	h.active = d.Bool()
+	h.stall = d.Int()
+}
@@ -0,0 +1,360 @@
+// Package printer emulates the Game Boy Printer connected to the serial port
+package printer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gb-emulator/internal/apu"
+)
+
+// Documentation
+// * https://gbdev.io/pandocs/Gameboy_Printer.html
+// * https://github.com/mofosyne/arduino-gameboy-printer-emulator
+
+const (
+	magic1 = 0x88
+	magic2 = 0x33
+
+	// commands
+	commandInit   = 0x01
+	commandPrint  = 0x02
+	commandData   = 0x04
+	commandBreak  = 0x08
+	commandStatus = 0x0F
+
+	// status bits
+	statusChecksumError = 1 << 0
+	statusBusy          = 1 << 1 // printing
+	statusFull          = 1 << 2 // the image buffer is full
+	statusUnprocessed   = 1 << 3 // data received and not printed yet
+	statusPacketError   = 1 << 4
+
+	// aliveResponse is the answer to the first byte after the checksum, it
+	// tells the game a printer is connected
+	aliveResponse = 0x81
+
+	// Width is the width of the paper in pixels, 20 tiles
+	Width = 160
+
+	tilesPerRow   = Width / 8
+	tileBytes     = 16
+	bandRows      = 16                          // pixel rows of a data packet
+	bandBytes     = tilesPerRow * 2 * tileBytes // 0x280, two rows of tiles
+	maxBands      = 9                           // a full screen
+	bufferSize    = bandBytes * maxBands
+	marginRows    = bandRows                // paper fed by a margin unit
+	bandDuration  = apu.ClockFrequency / 16 // printing time of a band, in T-cycles
+	defaultShades = 0xE4                    // palette used when the game sends 0
+)
+
+// paperShades are the grey levels printed for the shades 0 to 3
+var paperShades = [4]byte{0xFF, 0xAA, 0x55, 0x00}
+
+// receiveState is the part of the packet expected next
+type receiveState int
+
+const (
+	stateMagic1 receiveState = iota
+	stateMagic2
+	stateCommand
+	stateCompression
+	stateLengthLow
+	stateLengthHigh
+	stateData
+	stateChecksumLow
+	stateChecksumHigh
+	stateAlive
+	stateStatus
+)
+
+// Printer is a serial.ClockedPeer receiving the printer packets. Every job,
+// the prints until the paper is fed out, is saved as a PNG file
+type Printer struct {
+	dir string
+
+	state       receiveState
+	command     byte
+	compressed  bool
+	length      int
+	data        []byte
+	checksum    uint16 // received checksum
+	sum         uint16 // computed checksum
+	status      byte
+	busy        int // T-cycles until the print completes
+	buffer      []byte
+	page        *image.Gray // current job, nil when the paper is clean
+	pageCounter int
+
+	// OnSave is called after a job was written, with the error if it failed
+	OnSave func(path string, err error)
+}
+
+// New creates a printer saving its jobs in dir
+func New(dir string) *Printer {
+	return &Printer{dir: dir}
+}
+
+// Exchange implements serial.SerialPeer
+func (p *Printer) Exchange(out byte) byte {
+	response := byte(0x00)
+
+	switch p.state {
+	case stateMagic1:
+		if out == magic1 {
+			p.state = stateMagic2
+		}
+		return response
+	case stateMagic2:
+		if out == magic2 {
+			p.state = stateCommand
+		} else {
+			p.state = stateMagic1
+		}
+		return response
+	case stateCommand:
+		p.command = out
+		p.sum = uint16(out)
+		p.state = stateCompression
+	case stateCompression:
+		p.compressed = out&0x01 != 0
+		p.sum += uint16(out)
+		p.state = stateLengthLow
+	case stateLengthLow:
+		p.length = int(out)
+		p.sum += uint16(out)
+		p.state = stateLengthHigh
+	case stateLengthHigh:
+		p.length |= int(out) << 8
+		p.sum += uint16(out)
+		p.data = p.data[:0]
+		p.state = stateData
+		if p.length == 0 {
+			p.state = stateChecksumLow
+		}
+	case stateData:
+		p.data = append(p.data, out)
+		p.sum += uint16(out)
+		if len(p.data) == p.length {
+			p.state = stateChecksumLow
+		}
+	case stateChecksumLow:
+		p.checksum = uint16(out)
+		p.state = stateChecksumHigh
+	case stateChecksumHigh:
+		p.checksum |= uint16(out) << 8
+		p.state = stateAlive
+	case stateAlive:
+		p.state = stateStatus
+		return aliveResponse
+	case stateStatus:
+		p.process()
+		p.state = stateMagic1
+		return p.status
+	}
+
+	return response
+}
+
+// Step implements serial.ClockedPeer, it completes the print in progress
+func (p *Printer) Step(cycles int) {
+	if p.busy <= 0 {
+		return
+	}
+
+	p.busy -= cycles
+	if p.busy <= 0 {
+		p.status &^= statusBusy
+	}
+}
+
+// process runs the command of the packet just received
+func (p *Printer) process() {
+	if p.checksum != p.sum {
+		p.status |= statusChecksumError
+		return
+	}
+	p.status &^= statusChecksumError | statusPacketError
+
+	switch p.command {
+	case commandInit:
+		p.buffer = p.buffer[:0]
+		p.status = 0
+	case commandData:
+		p.receiveData()
+	case commandPrint:
+		if len(p.data) != 4 {
+			p.status |= statusPacketError
+			return
+		}
+		p.print(p.data[0], p.data[1]>>4, p.data[1]&0x0F, p.data[2])
+	case commandBreak:
+		p.buffer = p.buffer[:0]
+		p.status &^= statusUnprocessed | statusFull
+	case commandStatus:
+	default:
+		p.status |= statusPacketError
+	}
+}
+
+// receiveData adds the image data of the packet to the buffer. A packet
+// without data marks the end of the image
+func (p *Printer) receiveData() {
+	if len(p.data) == 0 {
+		return
+	}
+
+	data := p.data
+	if p.compressed {
+		data = decompress(data)
+	}
+
+	p.buffer = append(p.buffer, data[:min(len(data), bufferSize-len(p.buffer))]...)
+	p.status |= statusUnprocessed
+	if len(p.buffer) >= bufferSize {
+		p.status |= statusFull
+	}
+}
+
+// decompress expands the run-length encoding of the data packets: a byte
+// with bit 7 set repeats the next byte (n & 0x7F) + 2 times, otherwise the
+// next n + 1 bytes are copied
+func decompress(data []byte) []byte {
+	var out []byte
+	for i := 0; i < len(data); {
+		control := data[i]
+		i++
+
+		if control&0x80 != 0 {
+			if i >= len(data) {
+				break
+			}
+			for range int(control&0x7F) + 2 {
+				out = append(out, data[i])
+			}
+			i++
+			continue
+		}
+
+		count := min(int(control)+1, len(data)-i)
+		out = append(out, data[i:i+count]...)
+		i += count
+	}
+	return out
+}
+
+// print renders the buffer with the palette, between the margins given in
+// line feeds. The job is saved when the paper is fed out after the print
+func (p *Printer) print(sheets byte, marginBefore byte, marginAfter byte, palette byte) {
+	p.status &^= statusUnprocessed | statusFull
+
+	if palette == 0 {
+		palette = defaultShades
+	}
+
+	bands := len(p.buffer) / bandBytes
+	if sheets > 0 && bands > 0 {
+		p.feed(int(marginBefore) * marginRows)
+		p.appendImage(p.render(bands, palette))
+		p.status |= statusBusy
+		p.busy = bands * bandDuration
+	}
+	p.buffer = p.buffer[:0]
+
+	if marginAfter > 0 {
+		p.feed(int(marginAfter) * marginRows)
+		p.Flush()
+	}
+}
+
+// render decodes the 2bpp tiles of the buffer, laid out as rows of 20 tiles
+func (p *Printer) render(bands int, palette byte) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, Width, bands*bandRows))
+
+	for tile := range bands * bandBytes / tileBytes {
+		tileX := tile % tilesPerRow * 8
+		tileY := tile / tilesPerRow * 8
+		data := p.buffer[tile*tileBytes:]
+
+		for row := range 8 {
+			low, high := data[row*2], data[row*2+1]
+			for bit := range 8 {
+				index := (low>>(7-bit))&1 | ((high>>(7-bit))&1)<<1
+				shade := (palette >> (index * 2)) & 0x03
+				img.SetGray(tileX+bit, tileY+row, color.Gray{Y: paperShades[shade]})
+			}
+		}
+	}
+
+	return img
+}
+
+// feed adds blank paper to the current job
+func (p *Printer) feed(rows int) {
+	if rows == 0 || p.page == nil {
+		return
+	}
+
+	blank := image.NewGray(image.Rect(0, 0, Width, rows))
+	for i := range blank.Pix {
+		blank.Pix[i] = paperShades[0]
+	}
+	p.appendImage(blank)
+}
+
+// appendImage adds an image below the current job
+func (p *Printer) appendImage(img *image.Gray) {
+	if p.page == nil {
+		p.page = img
+		return
+	}
+
+	height := p.page.Bounds().Dy()
+	page := image.NewGray(image.Rect(0, 0, Width, height+img.Bounds().Dy()))
+	copy(page.Pix, p.page.Pix)
+	copy(page.Pix[len(p.page.Pix):], img.Pix)
+	p.page = page
+}
+
+// Flush saves the current job, if anything was printed
+func (p *Printer) Flush() {
+	if p.page == nil {
+		return
+	}
+
+	page := p.page
+	p.page = nil
+	p.pageCounter++
+
+	name := fmt.Sprintf("gbprinter-%s-%d.png", time.Now().Format("20060102-150405"), p.pageCounter)
+	path := filepath.Join(p.dir, name)
+	err := savePNG(path, page)
+
+	if p.OnSave != nil {
+		p.OnSave(path, err)
+	}
+}
+
+func savePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot create the print: %w", err)
+	}
+
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot write the print: %w", err)
+	}
+	return file.Close()
+}
+
+// Close saves the job in progress
+func (p *Printer) Close() error {
+	p.Flush()
+	return nil
+}
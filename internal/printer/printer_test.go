@@ -0,0 +1,119 @@
+package printer
+
+import (
+	"bytes"
+	"testing"
+)
+
+// sendPacket sends a packet with the given checksum and returns the status
+// answered in its last byte, after checking the alive answer
+func sendPacket(t *testing.T, p *Printer, command byte, compressed bool, data []byte, checksum uint16) byte {
+	t.Helper()
+
+	compression := byte(0)
+	if compressed {
+		compression = 1
+	}
+	packet := []byte{magic1, magic2, command, compression, byte(len(data)), byte(len(data) >> 8)}
+	packet = append(packet, data...)
+	packet = append(packet, byte(checksum), byte(checksum>>8))
+	for _, value := range packet {
+		p.Exchange(value)
+	}
+
+	if alive := p.Exchange(0); alive != aliveResponse {
+		t.Fatalf("alive answer %02X, want %02X", alive, aliveResponse)
+	}
+	return p.Exchange(0)
+}
+
+// packetSum is the checksum of a packet: the sum of every byte after the magic
+func packetSum(command byte, compressed bool, data []byte) uint16 {
+	sum := uint16(command) + uint16(len(data)&0xFF) + uint16(len(data)>>8)
+	if compressed {
+		sum++
+	}
+	for _, value := range data {
+		sum += uint16(value)
+	}
+	return sum
+}
+
+func TestChecksum(t *testing.T) {
+	p := New(t.TempDir())
+	data := bytes.Repeat([]byte{0xFF}, 0x280)
+
+	status := sendPacket(t, p, commandData, false, data, packetSum(commandData, false, data)+1)
+	if status&statusChecksumError == 0 {
+		t.Errorf("status %02X with a wrong checksum, want the checksum error", status)
+	}
+	if len(p.buffer) != 0 {
+		t.Errorf("buffer of %d bytes after a wrong checksum, want empty", len(p.buffer))
+	}
+
+	// the sum of 0x280 bytes 0xFF overflows 16 bits and wraps like the printer's
+	status = sendPacket(t, p, commandData, false, data, packetSum(commandData, false, data))
+	if status&statusChecksumError != 0 {
+		t.Errorf("status %02X with the right checksum, want no checksum error", status)
+	}
+	if status&statusUnprocessed == 0 {
+		t.Errorf("status %02X after the data, want unprocessed data", status)
+	}
+	if len(p.buffer) != len(data) {
+		t.Errorf("buffer of %d bytes, want %d", len(p.buffer), len(data))
+	}
+}
+
+func TestStatusPacket(t *testing.T) {
+	p := New(t.TempDir())
+
+	if status := sendPacket(t, p, commandInit, false, nil, packetSum(commandInit, false, nil)); status != 0 {
+		t.Errorf("status after init %02X, want 00", status)
+	}
+	if status := sendPacket(t, p, 0x7F, false, nil, packetSum(0x7F, false, nil)); status&statusPacketError == 0 {
+		t.Errorf("status after an unknown command %02X, want the packet error", status)
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"copy", []byte{0x02, 1, 2, 3}, []byte{1, 2, 3}},
+		{"repeat", []byte{0x81, 0xAA}, []byte{0xAA, 0xAA, 0xAA}},
+		{"shortest repeat", []byte{0x80, 0x55}, []byte{0x55, 0x55}},
+		{"mixed", []byte{0x00, 7, 0x82, 9, 0x01, 4, 5}, []byte{7, 9, 9, 9, 9, 4, 5}},
+		{"truncated copy", []byte{0x05, 1, 2}, []byte{1, 2}},
+		{"truncated repeat", []byte{0x00, 1, 0x85}, []byte{1}},
+		{"empty", nil, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := decompress(test.data); !bytes.Equal(got, test.want) {
+				t.Errorf("decompress(% X) = % X, want % X", test.data, got, test.want)
+			}
+		})
+	}
+
+	t.Run("long run", func(t *testing.T) {
+		got := decompress([]byte{0xFF, 0x11})
+		if want := bytes.Repeat([]byte{0x11}, 0x7F+2); !bytes.Equal(got, want) {
+			t.Errorf("decompress of a 129 bytes run returned %d bytes", len(got))
+		}
+	})
+}
+
+func TestCompressedData(t *testing.T) {
+	p := New(t.TempDir())
+	data := []byte{0xFF, 0x00, 0x81, 0x3C, 0x01, 0x12, 0x34}
+
+	sendPacket(t, p, commandData, true, data, packetSum(commandData, true, data))
+
+	want := append(bytes.Repeat([]byte{0x00}, 0x7F+2), 0x3C, 0x3C, 0x3C, 0x12, 0x34)
+	if !bytes.Equal(p.buffer, want) {
+		t.Errorf("buffer of %d bytes, want %d", len(p.buffer), len(want))
+	}
+}
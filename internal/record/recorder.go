@@ -0,0 +1,130 @@
+package record
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"gb-emulator/internal/apu"
+)
+
+// Recorder writes the mixed APU output, and optionally one stem per channel,
+// to WAV files
+type Recorder struct {
+	apu    *apu.APU
+	tracks []track
+
+	// Limit stops the recording after this many samples, 0 means no limit
+	Limit int
+
+	// FadeOut is the number of samples before Limit over which the volume
+	// goes down to silence
+	FadeOut int
+}
+
+// track is a WAV file fed by an APU tap
+type track struct {
+	tap    *apu.Tap
+	writer *WAVWriter
+}
+
+// Start starts recording the APU to path at the given sample rate. With stems
+// every channel is also written to its own file, next to path with the
+// channel name as suffix (song-square1.wav, song-wave.wav...)
+func Start(a *apu.APU, path string, sampleRate int, stems bool) (*Recorder, error) {
+	r := &Recorder{apu: a}
+
+	if err := r.addTrack(path, sampleRate); err != nil {
+		return nil, err
+	}
+
+	if stems {
+		for channel := range apu.Channel(apu.ChannelCount) {
+			if err := r.addTrack(StemPath(path, channel), sampleRate, channel); err != nil {
+				r.Close()
+				return nil, err
+			}
+		}
+	}
+
+	return r, nil
+}
+
+func (r *Recorder) addTrack(path string, sampleRate int, channels ...apu.Channel) error {
+	writer, err := CreateWAV(path, sampleRate)
+	if err != nil {
+		return err
+	}
+
+	r.tracks = append(r.tracks, track{tap: r.apu.AddTap(sampleRate, channels...), writer: writer})
+	return nil
+}
+
+// StemPath returns the file a channel stem of path is written to
+func StemPath(path string, channel apu.Channel) string {
+	extension := filepath.Ext(path)
+	return strings.TrimSuffix(path, extension) + "-" + channel.String() + extension
+}
+
+// Flush writes the samples produced by the APU since the last call
+func (r *Recorder) Flush() error {
+	var err error
+
+	for _, t := range r.tracks {
+		samples := t.tap.TakeSamples()
+		if r.Limit > 0 {
+			samples = samples[:min(len(samples), r.Limit-t.writer.Samples())]
+			r.fade(samples, t.writer.Samples())
+		}
+
+		err = errors.Join(err, t.writer.Write(samples))
+	}
+
+	return err
+}
+
+// fade applies the fade-out to samples, position is the index of the first one
+func (r *Recorder) fade(samples []apu.Sample, position int) {
+	if r.FadeOut <= 0 {
+		return
+	}
+
+	fadeStart := r.Limit - r.FadeOut
+	for i := range samples {
+		if position+i < fadeStart {
+			continue
+		}
+
+		gain := float64(r.Limit-position-i) / float64(r.FadeOut)
+		samples[i].Left = int16(float64(samples[i].Left) * gain)
+		samples[i].Right = int16(float64(samples[i].Right) * gain)
+	}
+}
+
+// Done reports whether the sample limit has been reached
+func (r *Recorder) Done() bool {
+	return r.Limit > 0 && r.Samples() >= r.Limit
+}
+
+// Samples returns the number of samples written to the mixed output
+func (r *Recorder) Samples() int {
+	if len(r.tracks) == 0 {
+		return 0
+	}
+	return r.tracks[0].writer.Samples()
+}
+
+// Close writes the pending samples, stops the taps and closes the files
+func (r *Recorder) Close() error {
+	for _, t := range r.tracks {
+		r.apu.RemoveTap(t.tap)
+	}
+
+	err := r.Flush()
+	for _, t := range r.tracks {
+		err = errors.Join(err, t.writer.Close())
+	}
+
+	r.tracks = nil
+	return err
+}
@@ -0,0 +1,179 @@
+package record
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"unicode/utf16"
+
+	"gb-emulator/internal/apu"
+)
+
+// Documentation
+// * https://vgmrips.net/wiki/VGM_Specification
+// * https://vgmrips.net/wiki/GD3_Specification
+
+const (
+	vgmVersion    = 0x00000161
+	vgmSampleRate = 44100
+	vgmHeaderSize = 0x100
+
+	// header fields
+	vgmEOFOffset     = 0x04
+	vgmVersionOffset = 0x08
+	vgmGD3Offset     = 0x14
+	vgmTotalSamples  = 0x18
+	vgmLoopOffset    = 0x1C
+	vgmLoopSamples   = 0x20
+	vgmDataOffset    = 0x34
+	vgmDMGClock      = 0x80
+
+	// commands
+	vgmGameBoyWrite = 0xB3
+	vgmWait         = 0x61
+	vgmWaitNTSC     = 0x62 // 735 samples
+	vgmWaitPAL      = 0x63 // 882 samples
+	vgmWaitShort    = 0x70 // 0x7n waits n+1 samples
+	vgmEnd          = 0x66
+
+	gd3Version = 0x00000100
+)
+
+// GD3 is the tag stored at the end of a VGM file
+type GD3 struct {
+	Track   string
+	Game    string
+	System  string
+	Author  string
+	Date    string
+	Creator string // who ripped the file
+	Notes   string
+}
+
+// VGMRecorder logs the APU register writes to a VGM file
+type VGMRecorder struct {
+	apu   *apu.APU
+	path  string
+	tag   GD3
+	start uint64 // APU cycle of the first sample
+
+	commands    bytes.Buffer
+	samples     uint64 // position of the command stream
+	loopOffset  int    // offset of the loop start in the commands, -1 without loop
+	loopSamples uint64 // position of the loop start
+}
+
+// StartVGM starts logging the APU writes. The current register state is
+// written first, so the log can start in the middle of a song
+func StartVGM(a *apu.APU, path string, tag GD3) *VGMRecorder {
+	v := &VGMRecorder{apu: a, path: path, tag: tag, start: a.Cycles(), loopOffset: -1}
+
+	for _, write := range a.StateWrites() {
+		v.writeRegister(write.Address, write.Value)
+	}
+
+	a.OnWrite = v.log
+	return v
+}
+
+// log adds a register write at the given APU cycle
+func (v *VGMRecorder) log(cycle uint64, address uint16, value byte) {
+	v.waitUntil(cycle)
+	v.writeRegister(address, value)
+}
+
+func (v *VGMRecorder) writeRegister(address uint16, value byte) {
+	v.commands.Write([]byte{vgmGameBoyWrite, byte(address - apu.NR10Address), value})
+}
+
+// waitUntil adds the waits up to the sample of the given APU cycle
+func (v *VGMRecorder) waitUntil(cycle uint64) {
+	target := (cycle - v.start) * vgmSampleRate / apu.ClockFrequency
+
+	for v.samples < target {
+		wait := min(target-v.samples, 0xFFFF)
+
+		switch {
+		case wait == 735:
+			v.commands.WriteByte(vgmWaitNTSC)
+		case wait == 882:
+			v.commands.WriteByte(vgmWaitPAL)
+		case wait <= 16:
+			v.commands.WriteByte(vgmWaitShort + byte(wait-1))
+		default:
+			v.commands.Write([]byte{vgmWait, byte(wait), byte(wait >> 8)})
+		}
+
+		v.samples += wait
+	}
+}
+
+// MarkLoop sets the loop start at the current APU cycle, the song loops
+// from there to the end of the recording
+func (v *VGMRecorder) MarkLoop() {
+	v.waitUntil(v.apu.Cycles())
+	v.loopOffset = v.commands.Len()
+	v.loopSamples = v.samples
+}
+
+// Looped reports whether a loop start was set
+func (v *VGMRecorder) Looped() bool {
+	return v.loopOffset >= 0
+}
+
+// Duration returns the number of 44.1 kHz samples logged until now
+func (v *VGMRecorder) Duration() uint64 {
+	return (v.apu.Cycles() - v.start) * vgmSampleRate / apu.ClockFrequency
+}
+
+// Close stops logging and writes the file
+func (v *VGMRecorder) Close() error {
+	v.apu.OnWrite = nil
+
+	v.waitUntil(v.apu.Cycles())
+	v.commands.WriteByte(vgmEnd)
+
+	header := make([]byte, vgmHeaderSize)
+	copy(header, "Vgm ")
+
+	gd3 := v.tag.encode()
+	dataEnd := vgmHeaderSize + v.commands.Len()
+	fileSize := dataEnd + len(gd3)
+
+	binary.LittleEndian.PutUint32(header[vgmEOFOffset:], uint32(fileSize-vgmEOFOffset))
+	binary.LittleEndian.PutUint32(header[vgmVersionOffset:], vgmVersion)
+	binary.LittleEndian.PutUint32(header[vgmGD3Offset:], uint32(dataEnd-vgmGD3Offset))
+	binary.LittleEndian.PutUint32(header[vgmTotalSamples:], uint32(v.samples))
+	if v.Looped() {
+		binary.LittleEndian.PutUint32(header[vgmLoopOffset:], uint32(vgmHeaderSize+v.loopOffset-vgmLoopOffset))
+		binary.LittleEndian.PutUint32(header[vgmLoopSamples:], uint32(v.samples-v.loopSamples))
+	}
+	binary.LittleEndian.PutUint32(header[vgmDataOffset:], vgmHeaderSize-vgmDataOffset)
+	binary.LittleEndian.PutUint32(header[vgmDMGClock:], apu.ClockFrequency)
+
+	file := append(header, v.commands.Bytes()...)
+	file = append(file, gd3...)
+
+	if err := os.WriteFile(v.path, file, 0o644); err != nil {
+		return fmt.Errorf("cannot write the VGM file: %w", err)
+	}
+	return nil
+}
+
+// encode returns the GD3 block. Every field has an English and a Japanese
+// version, only the English one is filled
+func (t GD3) encode() []byte {
+	var data []byte
+	for _, field := range []string{t.Track, "", t.Game, "", t.System, "", t.Author, "", t.Date, t.Creator, t.Notes} {
+		for _, unit := range utf16.Encode([]rune(field)) {
+			data = binary.LittleEndian.AppendUint16(data, unit)
+		}
+		data = binary.LittleEndian.AppendUint16(data, 0)
+	}
+
+	block := []byte("Gd3 ")
+	block = binary.LittleEndian.AppendUint32(block, gd3Version)
+	block = binary.LittleEndian.AppendUint32(block, uint32(len(data)))
+	return append(block, data...)
+}
@@ -0,0 +1,131 @@
+package record
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"unicode/utf16"
+
+	"gb-emulator/internal/apu"
+	"gb-emulator/internal/memory"
+)
+
+// cyclesOf returns the first APU cycle of a 44.1 kHz sample
+func cyclesOf(samples uint64) uint64 {
+	return (samples*apu.ClockFrequency + vgmSampleRate - 1) / vgmSampleRate
+}
+
+func TestVGMWaits(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples uint64
+		want    []byte
+	}{
+		{"none", 0, nil},
+		{"shortest", 1, []byte{0x70}},
+		{"longest short", 16, []byte{0x7F}},
+		{"after the short ones", 17, []byte{vgmWait, 17, 0}},
+		{"NTSC frame", 735, []byte{vgmWaitNTSC}},
+		{"PAL frame", 882, []byte{vgmWaitPAL}},
+		{"longest", 0xFFFF, []byte{vgmWait, 0xFF, 0xFF}},
+		{"split", 0x10000, []byte{vgmWait, 0xFF, 0xFF, 0x70}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := &VGMRecorder{}
+			v.waitUntil(cyclesOf(test.samples))
+
+			if got := v.commands.Bytes(); !bytes.Equal(got, test.want) {
+				t.Errorf("commands % X, want % X", got, test.want)
+			}
+			if v.samples != test.samples {
+				t.Errorf("position %d, want %d", v.samples, test.samples)
+			}
+		})
+	}
+}
+
+func TestVGMFile(t *testing.T) {
+	a := apu.New(memory.New(), apu.DefaultSampleRate)
+	path := filepath.Join(t.TempDir(), "test.vgm")
+	tag := GD3{Track: "Título ♪", Game: "Game", System: "Nintendo Game Boy", Author: "Someone", Date: "2026", Creator: "test", Notes: "notes"}
+
+	v := StartVGM(a, path, tag)
+	start := v.commands.Len() // the writes of the current state
+	a.Step(apu.ClockFrequency)
+	v.MarkLoop()
+	a.Step(apu.ClockFrequency / 2)
+	if err := v.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	field := func(offset int) int {
+		return int(binary.LittleEndian.Uint32(file[offset:]))
+	}
+
+	if got := string(file[:4]); got != "Vgm " {
+		t.Errorf("magic %q, want \"Vgm \"", got)
+	}
+	if got := field(vgmEOFOffset) + vgmEOFOffset; got != len(file) {
+		t.Errorf("end of file at %d, want %d", got, len(file))
+	}
+
+	samples := []struct {
+		name   string
+		offset int
+		want   int
+	}{
+		{"total samples", vgmTotalSamples, vgmSampleRate * 3 / 2},
+		{"loop samples", vgmLoopSamples, vgmSampleRate / 2},
+	}
+	for _, test := range samples {
+		if got := field(test.offset); got != test.want {
+			t.Errorf("%s %d, want %d", test.name, got, test.want)
+		}
+	}
+
+	// one wait of a second, the loop starts at the wait of half a second
+	data := field(vgmDataOffset) + vgmDataOffset
+	loop := field(vgmLoopOffset) + vgmLoopOffset
+	if want := data + start + 3; loop != want {
+		t.Errorf("loop at %X, want %X", loop, want)
+	}
+	if want := []byte{vgmWait, 0x22, 0x56, vgmEnd}; !bytes.Equal(file[loop:loop+4], want) {
+		t.Errorf("commands after the loop % X, want % X", file[loop:loop+4], want)
+	}
+
+	gd3 := field(vgmGD3Offset) + vgmGD3Offset
+	if got := string(file[gd3 : gd3+4]); got != "Gd3 " {
+		t.Fatalf("GD3 magic %q, want \"Gd3 \"", got)
+	}
+	if got := field(gd3 + 4); got != gd3Version {
+		t.Errorf("GD3 version %X, want %X", got, gd3Version)
+	}
+	if got := field(gd3+8) + gd3 + 12; got != len(file) {
+		t.Errorf("GD3 ends at %d, want %d", got, len(file))
+	}
+
+	var fields []string
+	var text []uint16
+	for i := gd3 + 12; i+1 < len(file); i += 2 {
+		unit := binary.LittleEndian.Uint16(file[i:])
+		if unit == 0 {
+			fields = append(fields, string(utf16.Decode(text)))
+			text = nil
+			continue
+		}
+		text = append(text, unit)
+	}
+	want := []string{tag.Track, "", tag.Game, "", tag.System, "", tag.Author, "", tag.Date, tag.Creator, tag.Notes}
+	if !slices.Equal(fields, want) {
+		t.Errorf("GD3 fields %q, want %q", fields, want)
+	}
+}
@@ -0,0 +1,121 @@
+// Package record captures the emulator output to files
+package record
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"gb-emulator/internal/apu"
+)
+
+// Documentation
+// * http://soundfile.sapp.org/doc/WaveFormat/
+
+const (
+	wavHeaderSize    = 44
+	wavChannels      = 2
+	wavBitsPerSample = 16
+	wavBlockAlign    = wavChannels * wavBitsPerSample / 8
+)
+
+// WAVWriter writes 16-bit stereo PCM samples to a WAV file. The sizes in the
+// header are filled in by Close
+type WAVWriter struct {
+	file       *os.File
+	writer     *bufio.Writer
+	sampleRate int
+	samples    int
+	buffer     []byte
+}
+
+// CreateWAV creates the file and writes a provisional header
+func CreateWAV(path string, sampleRate int) (*WAVWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create the WAV file: %w", err)
+	}
+
+	w := &WAVWriter{file: file, writer: bufio.NewWriter(file), sampleRate: sampleRate}
+	if err := w.writeHeader(w.writer); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *WAVWriter) writeHeader(out io.Writer) error {
+	dataSize := uint32(w.samples * wavBlockAlign)
+
+	header := struct {
+		RIFF          [4]byte
+		ChunkSize     uint32
+		WAVE          [4]byte
+		Fmt           [4]byte
+		FmtSize       uint32
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataSize      uint32
+	}{
+		RIFF:          [4]byte{'R', 'I', 'F', 'F'},
+		ChunkSize:     wavHeaderSize - 8 + dataSize,
+		WAVE:          [4]byte{'W', 'A', 'V', 'E'},
+		Fmt:           [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		AudioFormat:   1, // PCM
+		Channels:      wavChannels,
+		SampleRate:    uint32(w.sampleRate),
+		ByteRate:      uint32(w.sampleRate * wavBlockAlign),
+		BlockAlign:    wavBlockAlign,
+		BitsPerSample: wavBitsPerSample,
+		Data:          [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      dataSize,
+	}
+
+	return binary.Write(out, binary.LittleEndian, &header)
+}
+
+// Write appends samples to the file
+func (w *WAVWriter) Write(samples []apu.Sample) error {
+	w.buffer = w.buffer[:0]
+	for _, sample := range samples {
+		w.buffer = binary.LittleEndian.AppendUint16(w.buffer, uint16(sample.Left))
+		w.buffer = binary.LittleEndian.AppendUint16(w.buffer, uint16(sample.Right))
+	}
+
+	if _, err := w.writer.Write(w.buffer); err != nil {
+		return fmt.Errorf("cannot write the WAV file: %w", err)
+	}
+
+	w.samples += len(samples)
+	return nil
+}
+
+// Samples returns the number of stereo samples written
+func (w *WAVWriter) Samples() int {
+	return w.samples
+}
+
+// Close completes the header and closes the file
+func (w *WAVWriter) Close() error {
+	err := w.writer.Flush()
+	if err == nil {
+		_, err = w.file.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		err = w.writeHeader(w.file)
+	}
+
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+package record
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gb-emulator/internal/apu"
+)
+
+func TestWAVHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.wav")
+	w, err := CreateWAV(path, 32768)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]apu.Sample{{Left: 1, Right: -1}, {Left: 0x1234, Right: 0x5678}, {}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file) != wavHeaderSize+3*wavBlockAlign {
+		t.Fatalf("file of %d bytes, want %d", len(file), wavHeaderSize+3*wavBlockAlign)
+	}
+
+	tags := []struct {
+		offset int
+		want   string
+	}{
+		{0, "RIFF"},
+		{8, "WAVE"},
+		{12, "fmt "},
+		{36, "data"},
+	}
+	for _, tag := range tags {
+		if got := string(file[tag.offset : tag.offset+4]); got != tag.want {
+			t.Errorf("tag at %d %q, want %q", tag.offset, got, tag.want)
+		}
+	}
+
+	fields := []struct {
+		name   string
+		offset int
+		size   int
+		want   uint32
+	}{
+		{"chunk size", 4, 4, 36 + 12},
+		{"fmt size", 16, 4, 16},
+		{"format", 20, 2, 1},
+		{"channels", 22, 2, 2},
+		{"sample rate", 24, 4, 32768},
+		{"byte rate", 28, 4, 32768 * 4},
+		{"block align", 32, 2, 4},
+		{"bits per sample", 34, 2, 16},
+		{"data size", 40, 4, 12},
+	}
+	for _, field := range fields {
+		var got uint32
+		if field.size == 2 {
+			got = uint32(binary.LittleEndian.Uint16(file[field.offset:]))
+		} else {
+			got = binary.LittleEndian.Uint32(file[field.offset:])
+		}
+		if got != field.want {
+			t.Errorf("%s %d, want %d", field.name, got, field.want)
+		}
+	}
+
+	want := []byte{0x01, 0x00, 0xFF, 0xFF, 0x34, 0x12, 0x78, 0x56, 0, 0, 0, 0}
+	if got := file[wavHeaderSize:]; !bytes.Equal(got, want) {
+		t.Errorf("samples % X, want % X", got, want)
+	}
+}
+
+func TestStemPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		channel apu.Channel
+		want    string
+	}{
+		{"song.wav", apu.ChannelSquare1, "song-square1.wav"},
+		{filepath.Join("dir", "song.wav"), apu.ChannelWave, filepath.Join("dir", "song-wave.wav")},
+		{"song", apu.ChannelNoise, "song-noise"},
+	}
+
+	for _, test := range tests {
+		if got := StemPath(test.path, test.channel); got != test.want {
+			t.Errorf("StemPath(%q, %v) = %q, want %q", test.path, test.channel, got, test.want)
+		}
+	}
+}
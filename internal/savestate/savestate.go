@@ -0,0 +1,385 @@
+// Package savestate implements the binary format of the save states: a
+// header that identifies the game, followed by tagged sections written by
+// every piece of hardware
+package savestate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"time"
+)
+
+// File layout, little-endian:
+//
+//	magic    "GBSS"
+//	version  uint16
+//	header   ROM header checksum (uint8), ROM global checksum (uint16),
+//	         model (uint8), time in Unix seconds (int64)
+//	sections tag [4]byte, length uint32, data
+//
+// Sections are read by tag, so a reader skips the ones it doesn't know and
+// leaves the hardware whose section is missing as it is. A change inside a
+// section increases Version, and LoadState methods read the fields added
+// after the version of the file only when Decoder.Version is high enough:
+// old files keep loading, the new fields stay with their current value
+
+const (
+	magic = "GBSS"
+
+	// Version is the format written by this build
+	Version = 1
+
+	thumbnailTag = "THMB"
+)
+
+var (
+	// ErrFormat is returned for data that isn't a save state or is damaged
+	ErrFormat = errors.New("not a valid save state")
+	// ErrVersion is returned for files written by a newer build
+	ErrVersion = errors.New("the state was saved by a newer version of the emulator")
+)
+
+// Stater is the hardware that keeps its state in a section
+type Stater interface {
+	SaveState(e *Encoder)
+	LoadState(d *Decoder)
+}
+
+// Header identifies the game and the hardware of a save state
+type Header struct {
+	Version        int
+	HeaderChecksum byte
+	GlobalChecksum uint16
+	Model          byte
+	Time           time.Time
+}
+
+// Writer builds a save state
+type Writer struct {
+	buffer bytes.Buffer
+}
+
+// NewWriter starts a save state with the header, Version is always written
+func NewWriter(header Header) *Writer {
+	w := &Writer{}
+	w.buffer.WriteString(magic)
+
+	e := &Encoder{buffer: &w.buffer}
+	e.Uint16(Version)
+	e.Uint8(header.HeaderChecksum)
+	e.Uint16(header.GlobalChecksum)
+	e.Uint8(header.Model)
+	e.Int64(header.Time.Unix())
+
+	return w
+}
+
+// Section writes a section with the data encoded by write
+func (w *Writer) Section(tag string, write func(e *Encoder)) {
+	var data bytes.Buffer
+	write(&Encoder{buffer: &data})
+
+	w.buffer.WriteString(sectionTag(tag))
+	binary.Write(&w.buffer, binary.LittleEndian, uint32(data.Len()))
+	w.buffer.Write(data.Bytes())
+}
+
+// Save writes the section of the hardware
+func (w *Writer) Save(tag string, s Stater) {
+	w.Section(tag, s.SaveState)
+}
+
+// Bytes returns the save state
+func (w *Writer) Bytes() []byte {
+	return w.buffer.Bytes()
+}
+
+// Thumbnail stores a picture of the screen at half its size, shown by the
+// frontend next to every slot
+func (w *Writer) Thumbnail(screen *image.RGBA) {
+	bounds := screen.Bounds()
+	width, height := bounds.Dx()/2, bounds.Dy()/2
+
+	w.Section(thumbnailTag, func(e *Encoder) {
+		e.Uint16(uint16(width))
+		e.Uint16(uint16(height))
+		for y := range height {
+			for x := range width {
+				// the top left pixel of every 2x2 block is enough for a preview
+				offset := screen.PixOffset(bounds.Min.X+x*2, bounds.Min.Y+y*2)
+				e.Bytes(screen.Pix[offset : offset+4])
+			}
+		}
+	})
+}
+
+// sectionTag pads a tag to its 4 bytes
+func sectionTag(tag string) string {
+	return fmt.Sprintf("%-4.4s", tag)
+}
+
+// Reader gives access to the sections of a save state
+type Reader struct {
+	Header   Header
+	sections map[string][]byte
+}
+
+// NewReader checks the header and splits the sections
+func NewReader(data []byte) (*Reader, error) {
+	if len(data) < len(magic) || string(data[:len(magic)]) != magic {
+		return nil, ErrFormat
+	}
+
+	d := &Decoder{data: data[len(magic):]}
+	header := Header{Version: int(d.Uint16())}
+	header.HeaderChecksum = d.Uint8()
+	header.GlobalChecksum = d.Uint16()
+	header.Model = d.Uint8()
+	header.Time = time.Unix(d.Int64(), 0)
+	if d.Err() != nil {
+		return nil, ErrFormat
+	}
+	if header.Version > Version {
+		return nil, ErrVersion
+	}
+
+	r := &Reader{Header: header, sections: make(map[string][]byte)}
+	for len(d.data) > 0 {
+		tag := string(d.take(4))
+		length := d.Uint32()
+		section := d.take(int(length))
+		if d.Err() != nil {
+			return nil, ErrFormat
+		}
+		r.sections[tag] = section
+	}
+
+	return r, nil
+}
+
+// Section decodes a section, it reports false when the file doesn't have it
+func (r *Reader) Section(tag string, read func(d *Decoder)) (bool, error) {
+	data, exists := r.sections[sectionTag(tag)]
+	if !exists {
+		return false, nil
+	}
+
+	d := &Decoder{data: data, version: r.Header.Version}
+	read(d)
+	if d.Err() != nil {
+		return true, fmt.Errorf("section %q: %w", tag, d.Err())
+	}
+	return true, nil
+}
+
+// ReadFile reads a save state file without loading it, to show its header
+// and its thumbnail
+func ReadFile(path string) (*Reader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(data)
+}
+
+// Thumbnail returns the picture stored by Writer.Thumbnail, nil without one
+func (r *Reader) Thumbnail() *image.RGBA {
+	var thumbnail *image.RGBA
+	r.Section(thumbnailTag, func(d *Decoder) {
+		width, height := int(d.Uint16()), int(d.Uint16())
+		picture := image.NewRGBA(image.Rect(0, 0, width, height))
+		d.Bytes(picture.Pix)
+		if d.Err() == nil {
+			thumbnail = picture
+		}
+	})
+	return thumbnail
+}
+
+// Load restores the hardware from its section, if the file has it
+func (r *Reader) Load(tag string, s Stater) error {
+	_, err := r.Section(tag, s.LoadState)
+	return err
+}
+
+// Encoder writes the fields of a section
+type Encoder struct {
+	buffer *bytes.Buffer
+}
+
+func (e *Encoder) Bool(value bool) {
+	if value {
+		e.buffer.WriteByte(1)
+	} else {
+		e.buffer.WriteByte(0)
+	}
+}
+
+func (e *Encoder) Uint8(value byte) {
+	e.buffer.WriteByte(value)
+}
+
+func (e *Encoder) Uint16(value uint16) {
+	e.buffer.Write(binary.LittleEndian.AppendUint16(nil, value))
+}
+
+func (e *Encoder) Uint32(value uint32) {
+	e.buffer.Write(binary.LittleEndian.AppendUint32(nil, value))
+}
+
+func (e *Encoder) Uint64(value uint64) {
+	e.buffer.Write(binary.LittleEndian.AppendUint64(nil, value))
+}
+
+func (e *Encoder) Int64(value int64) {
+	e.Uint64(uint64(value))
+}
+
+// Int writes an int as 64 bits, the size doesn't depend on the platform
+func (e *Encoder) Int(value int) {
+	e.Int64(int64(value))
+}
+
+func (e *Encoder) Float64(value float64) {
+	e.Uint64(math.Float64bits(value))
+}
+
+// Bytes writes a block of fixed size, the reader knows its length
+func (e *Encoder) Bytes(data []byte) {
+	e.buffer.Write(data)
+}
+
+// Uint16s writes a block of 16-bit values of fixed size
+func (e *Encoder) Uint16s(values []uint16) {
+	for _, value := range values {
+		e.Uint16(value)
+	}
+}
+
+// Slice writes a block whose length is stored before it
+func (e *Encoder) Slice(data []byte) {
+	e.Uint32(uint32(len(data)))
+	e.Bytes(data)
+}
+
+// Decoder reads the fields of a section. The first error is kept and every
+// read after it returns zero, so LoadState methods check it once at the end
+type Decoder struct {
+	data    []byte
+	version int
+	err     error
+}
+
+// Version returns the format version of the file
+func (d *Decoder) Version() int {
+	return d.version
+}
+
+// Err returns the first error found while reading
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// take returns the next n bytes
+func (d *Decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.data) {
+		d.err = ErrFormat
+		return nil
+	}
+
+	data := d.data[:n]
+	d.data = d.data[n:]
+	return data
+}
+
+func (d *Decoder) Bool() bool {
+	return d.Uint8() != 0
+}
+
+func (d *Decoder) Uint8() byte {
+	if data := d.take(1); data != nil {
+		return data[0]
+	}
+	return 0
+}
+
+func (d *Decoder) Uint16() uint16 {
+	if data := d.take(2); data != nil {
+		return binary.LittleEndian.Uint16(data)
+	}
+	return 0
+}
+
+func (d *Decoder) Uint32() uint32 {
+	if data := d.take(4); data != nil {
+		return binary.LittleEndian.Uint32(data)
+	}
+	return 0
+}
+
+func (d *Decoder) Uint64() uint64 {
+	if data := d.take(8); data != nil {
+		return binary.LittleEndian.Uint64(data)
+	}
+	return 0
+}
+
+func (d *Decoder) Int64() int64 {
+	return int64(d.Uint64())
+}
+
+func (d *Decoder) Int() int {
+	return int(d.Int64())
+}
+
+func (d *Decoder) Float64() float64 {
+	return math.Float64frombits(d.Uint64())
+}
+
+// Bytes fills data with a block written by Encoder.Bytes
+func (d *Decoder) Bytes(data []byte) {
+	copy(data, d.take(len(data)))
+}
+
+// Uint16s fills values with a block written by Encoder.Uint16s
+func (d *Decoder) Uint16s(values []uint16) {
+	for i := range values {
+		values[i] = d.Uint16()
+	}
+}
+
+// Index reads a byte that selects one of count entries. A larger value only
+// comes from a damaged file, it fails with ErrFormat and reads as 0
+func (d *Decoder) Index(count int) byte {
+	value := d.Uint8()
+	if d.err == nil && int(value) >= count {
+		d.err = ErrFormat
+		return 0
+	}
+	return value
+}
+
+// IntRange reads an int that must be between low and high, both included.
+// Any other value fails with ErrFormat and reads as low
+func (d *Decoder) IntRange(low, high int) int {
+	value := d.Int()
+	if d.err == nil && (value < low || value > high) {
+		d.err = ErrFormat
+		return low
+	}
+	return value
+}
+
+// Slice reads a block written by Encoder.Slice
+func (d *Decoder) Slice() []byte {
+	length := d.Uint32()
+	return bytes.Clone(d.take(int(length)))
+}
@@ -0,0 +1,238 @@
+package savestate
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+var testHeader = Header{
+	Version:        Version,
+	HeaderChecksum: 0x9D,
+	GlobalChecksum: 0x1234,
+	Model:          2,
+	Time:           time.Unix(1700000000, 0),
+}
+
+func TestHeaderRoundTrip(t *testing.T) {
+	r, err := NewReader(NewWriter(testHeader).Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Header != testHeader {
+		t.Errorf("header %+v, want %+v", r.Header, testHeader)
+	}
+}
+
+func TestFieldsRoundTrip(t *testing.T) {
+	w := NewWriter(testHeader)
+	w.Section("TEST", func(e *Encoder) {
+		e.Bool(true)
+		e.Uint8(0xAB)
+		e.Uint16(0xBEEF)
+		e.Uint32(0xDEADBEEF)
+		e.Uint64(0x0123456789ABCDEF)
+		e.Int(-42)
+		e.Float64(1.5)
+		e.Bytes([]byte{1, 2, 3})
+		e.Uint16s([]uint16{0x1111, 0x2222})
+		e.Slice([]byte{4, 5})
+	})
+
+	r, err := NewReader(w.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := r.Section("TEST", func(d *Decoder) {
+		if got := d.Bool(); !got {
+			t.Errorf("Bool %v, want true", got)
+		}
+		if got := d.Uint8(); got != 0xAB {
+			t.Errorf("Uint8 %02X, want AB", got)
+		}
+		if got := d.Uint16(); got != 0xBEEF {
+			t.Errorf("Uint16 %04X, want BEEF", got)
+		}
+		if got := d.Uint32(); got != 0xDEADBEEF {
+			t.Errorf("Uint32 %08X, want DEADBEEF", got)
+		}
+		if got := d.Uint64(); got != 0x0123456789ABCDEF {
+			t.Errorf("Uint64 %016X, want 0123456789ABCDEF", got)
+		}
+		if got := d.Int(); got != -42 {
+			t.Errorf("Int %d, want -42", got)
+		}
+		if got := d.Float64(); got != 1.5 {
+			t.Errorf("Float64 %v, want 1.5", got)
+		}
+		block := make([]byte, 3)
+		if d.Bytes(block); !bytes.Equal(block, []byte{1, 2, 3}) {
+			t.Errorf("Bytes % X, want 01 02 03", block)
+		}
+		values := make([]uint16, 2)
+		if d.Uint16s(values); values[0] != 0x1111 || values[1] != 0x2222 {
+			t.Errorf("Uint16s %04X, want [1111 2222]", values)
+		}
+		if got := d.Slice(); !bytes.Equal(got, []byte{4, 5}) {
+			t.Errorf("Slice % X, want 04 05", got)
+		}
+		if d.Version() != Version {
+			t.Errorf("version %d, want %d", d.Version(), Version)
+		}
+	})
+	if !found || err != nil {
+		t.Errorf("section found %v error %v, want found without error", found, err)
+	}
+}
+
+func TestSections(t *testing.T) {
+	w := NewWriter(testHeader)
+	w.Section("CPU", func(e *Encoder) { e.Uint8(1) })
+	w.Section("NEW!", func(e *Encoder) { e.Uint32(0xFFFFFFFF) }) // unknown to the reader
+	w.Section("PPU", func(e *Encoder) { e.Uint8(2) })
+
+	r, err := NewReader(w.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for tag, want := range map[string]byte{"CPU": 1, "PPU": 2} {
+		var got byte
+		if found, err := r.Section(tag, func(d *Decoder) { got = d.Uint8() }); !found || err != nil {
+			t.Errorf("section %q found %v error %v", tag, found, err)
+		}
+		if got != want {
+			t.Errorf("section %q value %d, want %d", tag, got, want)
+		}
+	}
+
+	// a missing section leaves the hardware as it is
+	called := false
+	if found, err := r.Section("APU", func(d *Decoder) { called = true }); found || err != nil || called {
+		t.Errorf("missing section found %v error %v called %v", found, err, called)
+	}
+}
+
+func TestShortSection(t *testing.T) {
+	w := NewWriter(testHeader)
+	w.Section("CPU", func(e *Encoder) { e.Uint16(1) })
+
+	r, err := NewReader(w.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var value uint32
+	_, err = r.Section("CPU", func(d *Decoder) {
+		value = d.Uint32()
+		if d.Uint8() != 0 {
+			t.Error("read after an error isn't zero")
+		}
+	})
+	if !errors.Is(err, ErrFormat) {
+		t.Errorf("error %v, want %v", err, ErrFormat)
+	}
+	if value != 0 {
+		t.Errorf("value %d, want 0", value)
+	}
+}
+
+func TestNewReaderErrors(t *testing.T) {
+	valid := NewWriter(testHeader)
+	valid.Section("CPU", func(e *Encoder) { e.Uint32(1) })
+	data := valid.Bytes()
+
+	newer := bytes.Clone(data)
+	newer[len(magic)] = Version + 1
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrFormat},
+		{"magic", append([]byte("GBSX"), data[len(magic):]...), ErrFormat},
+		{"short header", data[:len(magic)+4], ErrFormat},
+		{"short section", data[:len(data)-1], ErrFormat},
+		{"newer version", newer, ErrVersion},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewReader(test.data); err != test.want {
+				t.Errorf("error %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	screen := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := range 4 {
+		screen.SetRGBA(x, 0, color.RGBA{R: byte(x * 10), A: 0xFF})
+		screen.SetRGBA(x, 1, color.RGBA{G: 0xFF, A: 0xFF})
+	}
+
+	w := NewWriter(testHeader)
+	w.Thumbnail(screen)
+	r, err := NewReader(w.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thumbnail := r.Thumbnail()
+	if thumbnail == nil {
+		t.Fatal("no thumbnail")
+	}
+	if bounds := thumbnail.Bounds(); bounds.Dx() != 2 || bounds.Dy() != 1 {
+		t.Fatalf("thumbnail size %dx%d, want 2x1", bounds.Dx(), bounds.Dy())
+	}
+	for x, want := range []color.RGBA{{R: 0, A: 0xFF}, {R: 20, A: 0xFF}} {
+		if got := thumbnail.RGBAAt(x, 0); got != want {
+			t.Errorf("thumbnail pixel %d %v, want %v", x, got, want)
+		}
+	}
+
+	r, _ = NewReader(NewWriter(testHeader).Bytes())
+	if r.Thumbnail() != nil {
+		t.Error("thumbnail of a state without one")
+	}
+}
+
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(e *Encoder)
+		read  func(d *Decoder) int
+		want  int
+		err   error
+	}{
+		{"index", func(e *Encoder) { e.Uint8(3) }, func(d *Decoder) int { return int(d.Index(4)) }, 3, nil},
+		{"index out of range", func(e *Encoder) { e.Uint8(4) }, func(d *Decoder) int { return int(d.Index(4)) }, 0, ErrFormat},
+		{"int", func(e *Encoder) { e.Int(4) }, func(d *Decoder) int { return d.IntRange(1, 4) }, 4, nil},
+		{"int too low", func(e *Encoder) { e.Int(0) }, func(d *Decoder) int { return d.IntRange(1, 4) }, 1, ErrFormat},
+		{"int too high", func(e *Encoder) { e.Int(5) }, func(d *Decoder) int { return d.IntRange(1, 4) }, 1, ErrFormat},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := NewWriter(testHeader)
+			w.Section("TEST", test.write)
+			r, err := NewReader(w.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got int
+			_, err = r.Section("TEST", func(d *Decoder) { got = test.read(d) })
+			if got != test.want || !errors.Is(err, test.err) {
+				t.Errorf("read %d error %v, want %d error %v", got, err, test.want, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Package serial implements the Game Boy serial port and the link cable
+package serial
+
+import (
+	"io"
+
+	"gb-emulator/internal/memory"
+)
+
+// Documentation
+// * https://gbdev.io/pandocs/Serial_Data_Transfer_(Link_Cable).html
+
+const (
+	SBAddress = 0xFF01 // serial transfer data
+	SCAddress = 0xFF02 // serial transfer control
+
+	scTransfer      byte = 1 << 7 // a transfer is requested or in progress
+	scFastClock     byte = 1 << 1 // CGB only, 262144 Hz internal clock
+	scInternalClock byte = 1 << 0 // this side drives the clock
+
+	scReadMask    byte = 0x7E // unused bits read as 1
+	scReadMaskCGB byte = 0x7C
+
+	// M-cycles per bit with the internal clock, 8192 Hz and 262144 Hz. Both
+	// are derived from the CPU clock, so they double in CGB double speed
+	bitCycles     = 128
+	fastBitCycles = 4
+
+	// Disconnected is the byte shifted in when nothing drives the input line
+	Disconnected byte = 0xFF
+)
+
+// SerialPeer is the device at the other end of the link cable
+type SerialPeer interface {
+	// Exchange is called when this side, using its internal clock, has shifted
+	// out a whole byte. It returns the byte shifted in from the peer
+	Exchange(out byte) byte
+}
+
+// ClockedPeer is a SerialPeer that runs along the emulation, for partners
+// that keep their own time or drive the clock of this side
+type ClockedPeer interface {
+	SerialPeer
+
+	// Step is called with the T-cycles at normal speed run by this side
+	Step(cycles int)
+}
+
+// Serial holds the SB/SC registers and the transfer in progress
+type Serial struct {
+	mem *memory.Memory
+
+	sb        byte
+	sc        byte
+	remaining int // M-cycles until the internal clock transfer completes
+
+	peer    SerialPeer
+	clocked ClockedPeer // peer, when it runs along the emulation
+
+	// Output receives every byte sent with the internal clock, test ROMs print
+	// their results this way
+	Output io.Writer
+}
+
+// New creates the serial port and maps its registers
+func New(mem *memory.Memory) *Serial {
+	s := &Serial{mem: mem}
+	mem.MapIO(SBAddress, SCAddress, s)
+	return s
+}
+
+// Connect plugs a link partner, nil disconnects the cable
+func (s *Serial) Connect(peer SerialPeer) {
+	s.peer = peer
+	s.clocked, _ = peer.(ClockedPeer)
+}
+
+// Peer returns the connected link partner, nil when nothing is connected
+func (s *Serial) Peer() SerialPeer {
+	return s.peer
+}
+
+// Data returns SB, the byte the next transfer sends
+func (s *Serial) Data() byte {
+	return s.sb
+}
+
+// Waiting reports whether a transfer using the external clock was requested
+// and waits for the partner
+func (s *Serial) Waiting() bool {
+	return s.sc&(scTransfer|scInternalClock) == scTransfer
+}
+
+// Active reports whether a transfer using the internal clock is in progress
+func (s *Serial) Active() bool {
+	return s.sc&(scTransfer|scInternalClock) == scTransfer|scInternalClock
+}
+
+// Step advances the internal clock transfer by the given number of M-cycles
+func (s *Serial) Step(cycles int) {
+	if s.clocked != nil {
+		s.clocked.Step(s.dots(cycles))
+	}
+
+	if !s.Active() {
+		return
+	}
+
+	s.remaining -= cycles
+	if s.remaining > 0 {
+		return
+	}
+
+	out := s.sb
+	in := Disconnected
+	if s.peer != nil {
+		in = s.peer.Exchange(out)
+	}
+	if s.Output != nil {
+		s.Output.Write([]byte{out})
+	}
+
+	s.complete(in)
+}
+
+// Receive is called by a partner driving the clock. When this side waits with
+// the external clock, the transfer completes with the given byte and the
+// sent one is returned with ok set. Otherwise nothing changes, the partner
+// reads the idle line
+func (s *Serial) Receive(in byte) (out byte, ok bool) {
+	if !s.Waiting() {
+		return Disconnected, false
+	}
+
+	out = s.sb
+	s.complete(in)
+	return out, true
+}
+
+// complete stores the received byte and raises the serial interrupt
+func (s *Serial) complete(in byte) {
+	s.sb = in
+	s.sc &^= scTransfer
+	s.remaining = 0
+	s.mem.RequestInterrupt(memory.InterruptSerial)
+}
+
+// dots converts M-cycles to T-cycles at normal speed
+func (s *Serial) dots(cycles int) int {
+	if s.mem.DoubleSpeed {
+		return cycles * 2
+	}
+	return cycles * 4
+}
+
+// transferCycles returns the M-cycles of an 8-bit internal clock transfer
+func (s *Serial) transferCycles() int {
+	if s.mem.CGB && s.sc&scFastClock != 0 {
+		return 8 * fastBitCycles
+	}
+	return 8 * bitCycles
+}
+
+// ReadIO implements memory.IODevice
+func (s *Serial) ReadIO(address uint16) byte {
+	if address == SBAddress {
+		return s.sb
+	}
+
+	if s.mem.CGB {
+		return s.sc | scReadMaskCGB
+	}
+	return s.sc | scReadMask
+}
+
+// WriteIO implements memory.IODevice
+func (s *Serial) WriteIO(address uint16, value byte) {
+	if address == SBAddress {
+		s.sb = value
+		return
+	}
+
+	s.sc = value & (scTransfer | scFastClock | scInternalClock)
+	if s.Active() {
+		s.remaining = s.transferCycles()
+	}
+}
@@ -0,0 +1,171 @@
+package serial
+
+import (
+	"bytes"
+	"testing"
+
+	"gb-emulator/internal/memory"
+)
+
+// testPeer answers every byte with in and keeps the bytes it received
+type testPeer struct {
+	in       byte
+	received []byte
+	cycles   int
+}
+
+func (p *testPeer) Exchange(out byte) byte {
+	p.received = append(p.received, out)
+	return p.in
+}
+
+func (p *testPeer) Step(cycles int) {
+	p.cycles += cycles
+}
+
+func serialRequested(mem *memory.Memory) bool {
+	return mem.Read(memory.IFAddress)&memory.InterruptSerial != 0
+}
+
+func TestInternalClockTransfer(t *testing.T) {
+	tests := []struct {
+		name   string
+		cgb    bool
+		sc     byte
+		cycles int // M-cycles of the transfer
+	}{
+		{"DMG", false, 0x81, 8 * bitCycles},
+		{"DMG ignores the fast clock", false, 0x83, 8 * bitCycles},
+		{"CGB", true, 0x81, 8 * bitCycles},
+		{"CGB fast clock", true, 0x83, 8 * fastBitCycles},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mem := memory.New()
+			mem.CGB = test.cgb
+			s := New(mem)
+			var output bytes.Buffer
+			s.Output = &output
+
+			s.WriteIO(SBAddress, 0x42)
+			s.WriteIO(SCAddress, test.sc)
+
+			s.Step(test.cycles - 1)
+			if !s.Active() || serialRequested(mem) {
+				t.Fatal("transfer completed one cycle early")
+			}
+			s.Step(1)
+			if s.Active() || !serialRequested(mem) {
+				t.Fatal("transfer not completed")
+			}
+
+			if got := s.ReadIO(SBAddress); got != Disconnected {
+				t.Errorf("SB %02X without a peer, want %02X", got, Disconnected)
+			}
+			if got := output.Bytes(); !bytes.Equal(got, []byte{0x42}) {
+				t.Errorf("output % X, want 42", got)
+			}
+		})
+	}
+}
+
+func TestPeer(t *testing.T) {
+	mem := memory.New()
+	s := New(mem)
+	peer := &testPeer{in: 0x99}
+	s.Connect(peer)
+
+	s.WriteIO(SBAddress, 0x42)
+	s.WriteIO(SCAddress, 0x81)
+	s.Step(8 * bitCycles)
+
+	if got := s.ReadIO(SBAddress); got != 0x99 {
+		t.Errorf("SB %02X, want 99", got)
+	}
+	if !bytes.Equal(peer.received, []byte{0x42}) {
+		t.Errorf("peer received % X, want 42", peer.received)
+	}
+
+	// a clocked peer runs along in T-cycles at normal speed
+	if peer.cycles != 8*bitCycles*4 {
+		t.Errorf("peer stepped %d T-cycles, want %d", peer.cycles, 8*bitCycles*4)
+	}
+	mem.DoubleSpeed = true
+	s.Step(10)
+	if peer.cycles != 8*bitCycles*4+20 {
+		t.Errorf("peer stepped %d T-cycles in double speed, want %d", peer.cycles, 8*bitCycles*4+20)
+	}
+
+	s.Connect(nil)
+	if s.Peer() != nil {
+		t.Error("peer still connected")
+	}
+}
+
+func TestReceive(t *testing.T) {
+	tests := []struct {
+		name string
+		sc   byte
+		ok   bool
+	}{
+		{"waiting with the external clock", 0x80, true},
+		{"no transfer", 0x00, false},
+		{"internal clock", 0x81, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mem := memory.New()
+			s := New(mem)
+			s.WriteIO(SBAddress, 0x42)
+			s.WriteIO(SCAddress, test.sc)
+
+			out, ok := s.Receive(0x99)
+			if ok != test.ok {
+				t.Fatalf("received %v, want %v", ok, test.ok)
+			}
+			if !ok {
+				if out != Disconnected || s.ReadIO(SBAddress) != 0x42 || serialRequested(mem) {
+					t.Errorf("sent %02X SB %02X, want FF and SB unchanged", out, s.ReadIO(SBAddress))
+				}
+				return
+			}
+
+			if out != 0x42 {
+				t.Errorf("sent %02X, want 42", out)
+			}
+			if got := s.ReadIO(SBAddress); got != 0x99 {
+				t.Errorf("SB %02X, want 99", got)
+			}
+			if s.Waiting() || !serialRequested(mem) {
+				t.Error("transfer not completed")
+			}
+		})
+	}
+}
+
+func TestSCReadMask(t *testing.T) {
+	tests := []struct {
+		cgb   bool
+		value byte
+		want  byte
+	}{
+		{false, 0x00, 0x7E},
+		{false, 0x83, 0xFF},
+		{true, 0x00, 0x7C},
+		{true, 0x81, 0xFD},
+		{true, 0x83, 0xFF},
+	}
+
+	for _, test := range tests {
+		mem := memory.New()
+		mem.CGB = test.cgb
+		s := New(mem)
+
+		s.WriteIO(SCAddress, test.value)
+		if got := s.ReadIO(SCAddress); got != test.want {
+			t.Errorf("CGB %v: SC %02X after writing %02X, want %02X", test.cgb, got, test.value, test.want)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package serial
+
+import "gb-emulator/internal/savestate"
+
+// SaveState writes the registers and the transfer in progress. The peer
+// isn't part of the state
+func (s *Serial) SaveState(e *savestate.Encoder) {
+	e.Uint8(s.sb)
+	e.Uint8(s.sc)
+	e.Int(s.remaining)
+}
+
+// LoadState restores the state written by SaveState
+func (s *Serial) LoadState(d *savestate.Decoder) {
+	s.sb = d.Uint8()
+	s.sc = d.Uint8()
+	s.remaining = d.Int()
+}
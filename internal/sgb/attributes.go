@@ -0,0 +1,121 @@
+package sgb
+
+// attributeBlocks runs ATTR_BLK: every data set colours the inside, the
+// border and the outside of a rectangle of tiles
+func (s *SGB) attributeBlocks(data []byte) {
+	count := min(int(data[1]&0x1F), (len(data)-2)/6)
+
+	for i := range count {
+		set := data[2+i*6 : 8+i*6]
+		control := set[0] & 0x07
+		inside, border, outside := set[1]&0x03, set[1]>>2&0x03, set[1]>>4&0x03
+		left, top := int(set[2]&0x1F), int(set[3]&0x1F)
+		right, bottom := int(set[4]&0x1F), int(set[5]&0x1F)
+
+		// with only the inside or the outside selected the border follows it
+		switch control {
+		case 0x01:
+			control, border = 0x03, inside
+		case 0x04:
+			control, border = 0x06, outside
+		}
+
+		for row := range rows {
+			for column := range columns {
+				switch {
+				case column > left && column < right && row > top && row < bottom:
+					if control&0x01 != 0 {
+						s.attributes[row][column] = inside
+					}
+				case column >= left && column <= right && row >= top && row <= bottom:
+					if control&0x02 != 0 {
+						s.attributes[row][column] = border
+					}
+				default:
+					if control&0x04 != 0 {
+						s.attributes[row][column] = outside
+					}
+				}
+			}
+		}
+	}
+}
+
+// attributeLines runs ATTR_LIN: every data byte colours a whole row (bit 7
+// set) or column of tiles
+func (s *SGB) attributeLines(data []byte) {
+	count := min(int(data[1]), len(data)-2)
+
+	for _, line := range data[2 : 2+count] {
+		number := int(line & 0x1F)
+		palette := line >> 5 & 0x03
+
+		if line&0x80 != 0 {
+			if number < rows {
+				for column := range columns {
+					s.attributes[number][column] = palette
+				}
+			}
+			continue
+		}
+
+		if number < columns {
+			for row := range rows {
+				s.attributes[row][number] = palette
+			}
+		}
+	}
+}
+
+// attributeDivide runs ATTR_DIV: the screen is split in two by a row (bit 6
+// set) or a column of tiles, each part and the line get their own palette
+func (s *SGB) attributeDivide(data []byte) {
+	after, before, line := data[1]&0x03, data[1]>>2&0x03, data[1]>>4&0x03
+	horizontal := data[1]&0x40 != 0
+	split := int(data[2] & 0x1F)
+
+	for row := range rows {
+		for column := range columns {
+			position := column
+			if horizontal {
+				position = row
+			}
+
+			switch {
+			case position < split:
+				s.attributes[row][column] = before
+			case position == split:
+				s.attributes[row][column] = line
+			default:
+				s.attributes[row][column] = after
+			}
+		}
+	}
+}
+
+// attributeCharacters runs ATTR_CHR: a palette for every tile from a start
+// position, four per byte, from left to right or from top to bottom
+func (s *SGB) attributeCharacters(data []byte) {
+	column, row := int(data[1]), int(data[2])
+	count := min(int(word(data, 3)), rows*columns, (len(data)-6)*4)
+	vertical := data[5]&0x01 != 0
+
+	for i := range count {
+		palette := data[6+i/4] >> (6 - i%4*2) & 0x03
+		if column < columns && row < rows {
+			s.attributes[row][column] = palette
+		}
+
+		if vertical {
+			if row++; row >= rows {
+				row = 0
+				column++
+			}
+		} else {
+			if column++; column >= columns {
+				column = 0
+				row++
+			}
+		}
+	}
+}
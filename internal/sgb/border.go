@@ -0,0 +1,107 @@
+package sgb
+
+import (
+	"image"
+
+	"gb-emulator/internal/ppu"
+)
+
+// Documentation
+// * https://gbdev.io/pandocs/SGB_Command_Border.html
+
+const (
+	// BorderWidth and BorderHeight are the size of the SNES picture, the
+	// game screen is shown in the middle of the border
+	BorderWidth  = 256
+	BorderHeight = 224
+	screenX      = (BorderWidth - ppu.ScreenWidth) / 2
+	screenY      = (BorderHeight - ppu.ScreenHeight) / 2
+
+	borderTileBytes = 32 // SNES 4bpp tile
+	borderTiles     = 256
+	borderMapSize   = 32 // tiles per row of the border map
+	borderPalettes  = 4  // SNES palettes 4 to 7
+	borderColours   = 16
+	borderMapBytes  = borderMapSize * borderMapSize * 2
+
+	borderTilePalette = 0x1C00 // map entry bits 10-12
+	borderTileXFlip   = 0x4000
+	borderTileYFlip   = 0x8000
+)
+
+// border holds the tiles loaded by CHR_TRN and the map and palettes loaded
+// by PCT_TRN
+type border struct {
+	tiles    [borderTiles * borderTileBytes]byte
+	tileMap  [borderMapSize * borderMapSize]uint16
+	palettes [borderPalettes][borderColours]uint16
+}
+
+// loadTiles stores half of the tiles, 128 of them fit in a transfer
+func (b *border) loadTiles(half int, data []byte) {
+	copy(b.tiles[half*transferSize:], data)
+}
+
+// loadMap stores the map of 32x32 tiles followed by the palettes
+func (b *border) loadMap(data []byte) {
+	for i := range b.tileMap {
+		b.tileMap[i] = word(data, i*2)
+	}
+	for palette := range b.palettes {
+		for colour := range borderColours {
+			b.palettes[palette][colour] = word(data, borderMapBytes+(palette*borderColours+colour)*2)
+		}
+	}
+}
+
+// pixel returns the palette and the colour index of a pixel of the border,
+// colour 0 is transparent
+func (b *border) pixel(x, y int) (int, byte) {
+	entry := b.tileMap[y/8*borderMapSize+x/8]
+	tileX, tileY := x%8, y%8
+	if entry&borderTileXFlip != 0 {
+		tileX = 7 - tileX
+	}
+	if entry&borderTileYFlip != 0 {
+		tileY = 7 - tileY
+	}
+
+	// the palette number goes from 4 to 7, only those palettes are loaded
+	palette := int(entry&borderTilePalette>>10) & (borderPalettes - 1)
+
+	tile := b.tiles[int(entry&0xFF)*borderTileBytes:]
+	bit := 7 - tileX
+	colour := tile[tileY*2]>>bit&1 |
+		tile[tileY*2+1]>>bit&1<<1 |
+		tile[16+tileY*2]>>bit&1<<2 |
+		tile[16+tileY*2+1]>>bit&1<<3
+
+	return palette, colour
+}
+
+// Border returns the picture of the SNES: the game screen in the middle of
+// the border. The transparent parts of the border show the game inside the
+// screen area and colour 0 outside it
+func (s *SGB) Border() *image.RGBA {
+	frame := s.ppu.Frame()
+	backdrop := ppu.BGR555(s.palettes[0][0])
+
+	for y := range BorderHeight {
+		for x := range BorderWidth {
+			gameX, gameY := x-screenX, y-screenY
+			inside := gameX >= 0 && gameX < ppu.ScreenWidth && gameY >= 0 && gameY < ppu.ScreenHeight
+
+			palette, colour := s.border.pixel(x, y)
+			switch {
+			case colour != 0:
+				s.output.SetRGBA(x, y, ppu.BGR555(s.border.palettes[palette][colour]))
+			case inside:
+				s.output.SetRGBA(x, y, frame.RGBAAt(gameX, gameY))
+			default:
+				s.output.SetRGBA(x, y, backdrop)
+			}
+		}
+	}
+
+	return s.output
+}
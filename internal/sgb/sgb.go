@@ -0,0 +1,297 @@
+// Package sgb implements the Super Game Boy: the command packets sent
+// through the joypad register, the palettes and the attribute maps that
+// colourize the screen and the border around it
+package sgb
+
+import (
+	"image"
+	"image/color"
+
+	"gb-emulator/internal/joypad"
+	"gb-emulator/internal/ppu"
+)
+
+// Documentation
+// * https://gbdev.io/pandocs/SGB_Functions.html
+// * https://gbdev.io/pandocs/SGB_Command_Packet.html
+// * https://gbdev.io/pandocs/SGB_Command_Palettes.html
+// * https://gbdev.io/pandocs/SGB_Command_Attribute.html
+
+const (
+	packetSize     = 16
+	packetBits     = packetSize * 8
+	columns        = ppu.ScreenWidth / 8 // attribute map size, in tiles
+	rows           = ppu.ScreenHeight / 8
+	palettes       = 4
+	systemPalettes = 512
+	attributeFiles = 45
+
+	// P1 selections driving the packet transfer
+	pulseReset = 0x00 // P14 and P15 low
+	pulseZero  = 0x20 // P14 low
+	pulseOne   = 0x10 // P15 low
+	pulseIdle  = 0x30 // both high, between two bits
+)
+
+// Command codes, the upper 5 bits of the first byte of a packet
+const (
+	commandPal01   = 0x00
+	commandPal23   = 0x01
+	commandPal03   = 0x02
+	commandPal12   = 0x03
+	commandAttrBlk = 0x04
+	commandAttrLin = 0x05
+	commandAttrDiv = 0x06
+	commandAttrChr = 0x07
+	commandPalSet  = 0x0A
+	commandPalTrn  = 0x0B
+	commandMltReq  = 0x11
+	commandChrTrn  = 0x13
+	commandPctTrn  = 0x14
+	commandAttrTrn = 0x15
+	commandAttrSet = 0x16
+	commandMaskEn  = 0x17
+)
+
+// Mask is the MASK_EN mode, used to hide the screen while the game prepares it
+type Mask byte
+
+const (
+	MaskCancel  Mask = iota // show the game
+	MaskFreeze              // keep the last frame
+	MaskBlack               // black screen
+	MaskColour0             // screen filled with colour 0
+)
+
+// defaultPalette is the palette shown before the game sets one, 1-A of the
+// SGB menu
+var defaultPalette = [4]uint16{0x67BF, 0x265B, 0x10B5, 0x2866}
+
+// SGB decodes the packets sent by the game and colourizes the frames of the
+// PPU with its four palettes, selected by an attribute map of 20x18 tiles
+type SGB struct {
+	ppu    *ppu.PPU
+	joypad *joypad.Joypad
+
+	// packet receiver
+	receiving bool
+	previous  byte // last P1 selection
+	bits      int  // bits received of the current packet
+	packet    [packetSize]byte
+	command   []byte // packets of the current command
+	packets   int    // packets the command is made of
+
+	palettes       [palettes][4]uint16
+	systemPalettes [systemPalettes][4]uint16 // loaded by PAL_TRN, selected by PAL_SET
+	attributes     [rows][columns]byte       // palette of every tile
+	attributeFiles [attributeFiles][rows][columns]byte
+	mask           Mask
+
+	transfer          transfer // VRAM transfer waiting for its frame
+	transferCountdown int      // frames until the transfer is read
+	screen            []byte   // shades of the frame shown, kept while frozen
+
+	border border
+	output *image.RGBA // game screen inside the border
+}
+
+// New attaches an SGB to the joypad, which receives the packets, and to the
+// PPU, whose frames are colourized
+func New(p *ppu.PPU, j *joypad.Joypad) *SGB {
+	s := &SGB{
+		ppu:    p,
+		joypad: j,
+		screen: make([]byte, ppu.ScreenWidth*ppu.ScreenHeight),
+		output: image.NewRGBA(image.Rect(0, 0, BorderWidth, BorderHeight)),
+	}
+	for i := range s.palettes {
+		s.palettes[i] = defaultPalette
+	}
+
+	j.OnWrite = s.WriteP1
+	p.OnFrame = s.Frame
+
+	return s
+}
+
+// Palette returns the colours of one of the four palettes, in BGR555
+func (s *SGB) Palette(palette int) [4]uint16 {
+	return s.palettes[palette]
+}
+
+// Attribute returns the palette used by the tile at the given column and row
+func (s *SGB) Attribute(column, row int) byte {
+	return s.attributes[row][column]
+}
+
+// Mask returns the current MASK_EN mode
+func (s *SGB) Mask() Mask {
+	return s.mask
+}
+
+// WriteP1 receives the packet bits from the P14/P15 selection written to P1.
+// Both lines low start a packet, then every pulse of one of them followed by
+// both high sends a bit, least significant first. A 129th bit, always 0,
+// ends the packet
+func (s *SGB) WriteP1(selection byte) {
+	previous := s.previous
+	s.previous = selection
+
+	switch selection {
+	case pulseReset:
+		s.receiving = true
+		s.bits = 0
+		s.packet = [packetSize]byte{}
+	case pulseZero, pulseOne:
+		if !s.receiving || previous != pulseIdle {
+			return
+		}
+
+		if s.bits == packetBits {
+			s.receiving = false
+			if selection == pulseZero {
+				s.receivePacket()
+			}
+			return
+		}
+
+		if selection == pulseOne {
+			s.packet[s.bits/8] |= 1 << (s.bits % 8)
+		}
+		s.bits++
+	}
+}
+
+// receivePacket adds a packet to the current command, the first one holds
+// the command code and the number of packets
+func (s *SGB) receivePacket() {
+	if len(s.command) == 0 {
+		s.packets = max(1, int(s.packet[0]&0x07)) // up to 7
+	}
+	s.command = append(s.command, s.packet[:]...)
+
+	if len(s.command) < s.packets*packetSize {
+		return
+	}
+
+	command := s.command
+	s.command = nil
+	s.execute(command)
+}
+
+// execute runs a complete command, the unsupported ones are ignored
+func (s *SGB) execute(data []byte) {
+	switch data[0] >> 3 {
+	case commandPal01:
+		s.setPalettes(0, 1, data)
+	case commandPal23:
+		s.setPalettes(2, 3, data)
+	case commandPal03:
+		s.setPalettes(0, 3, data)
+	case commandPal12:
+		s.setPalettes(1, 2, data)
+	case commandAttrBlk:
+		s.attributeBlocks(data)
+	case commandAttrLin:
+		s.attributeLines(data)
+	case commandAttrDiv:
+		s.attributeDivide(data)
+	case commandAttrChr:
+		s.attributeCharacters(data)
+	case commandPalSet:
+		s.setSystemPalettes(data)
+	case commandPalTrn:
+		s.startTransfer(transferPalettes)
+	case commandAttrTrn:
+		s.startTransfer(transferAttributes)
+	case commandChrTrn:
+		s.startTransfer(transferBorderTiles + transfer(data[1]&0x01))
+	case commandPctTrn:
+		s.startTransfer(transferBorderMap)
+	case commandAttrSet:
+		s.applyAttributeFile(data[1])
+		s.cancelMask(data[1])
+	case commandMltReq:
+		s.joypad.SetPlayers(int(data[1]&0x03) + 1)
+	case commandMaskEn:
+		s.mask = Mask(data[1] & 0x03)
+	}
+}
+
+// word reads a little endian 16-bit value
+func word(data []byte, offset int) uint16 {
+	return uint16(data[offset]) | uint16(data[offset+1])<<8
+}
+
+// setPalettes loads colours 1 to 3 of two palettes and the colour 0 shared by
+// every palette
+func (s *SGB) setPalettes(first, second int, data []byte) {
+	s.setColour0(word(data, 1))
+	for i := range 3 {
+		s.palettes[first][i+1] = word(data, 3+i*2)
+		s.palettes[second][i+1] = word(data, 9+i*2)
+	}
+}
+
+func (s *SGB) setColour0(colour uint16) {
+	for i := range s.palettes {
+		s.palettes[i][0] = colour
+	}
+}
+
+// setSystemPalettes copies four of the palettes loaded by PAL_TRN, optionally
+// applying an attribute file and cancelling the mask
+func (s *SGB) setSystemPalettes(data []byte) {
+	for i := range s.palettes {
+		s.palettes[i] = s.systemPalettes[word(data, 1+i*2)%systemPalettes]
+	}
+	s.setColour0(s.palettes[0][0])
+
+	if data[9]&0x80 != 0 {
+		s.applyAttributeFile(data[9])
+	}
+	s.cancelMask(data[9])
+}
+
+// applyAttributeFile copies the map loaded by ATTR_TRN given by the lower 6
+// bits of the parameter
+func (s *SGB) applyAttributeFile(parameter byte) {
+	if file := int(parameter & 0x3F); file < attributeFiles {
+		s.attributes = s.attributeFiles[file]
+	}
+}
+
+// cancelMask shows the game again when bit 6 of the parameter is set
+func (s *SGB) cancelMask(parameter byte) {
+	if parameter&0x40 != 0 {
+		s.mask = MaskCancel
+	}
+}
+
+// Frame colourizes the frame just completed by the PPU: every shade is drawn
+// with the palette of its tile. It also runs the VRAM transfers
+func (s *SGB) Frame() {
+	shades := s.ppu.Shades()
+	s.runTransfer(shades)
+
+	if s.mask != MaskFreeze {
+		copy(s.screen, shades)
+	}
+
+	frame := s.ppu.Frame()
+	for y := range ppu.ScreenHeight {
+		for x := range ppu.ScreenWidth {
+			var colour color.RGBA
+			switch s.mask {
+			case MaskBlack:
+				colour = color.RGBA{A: 0xFF}
+			case MaskColour0:
+				colour = ppu.BGR555(s.palettes[0][0])
+			default:
+				palette := s.attributes[y/8][x/8]
+				colour = ppu.BGR555(s.palettes[palette][s.screen[y*ppu.ScreenWidth+x]])
+			}
+			frame.SetRGBA(x, y, colour)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package sgb
+
+import (
+	"testing"
+
+	"gb-emulator/internal/joypad"
+	"gb-emulator/internal/memory"
+	"gb-emulator/internal/ppu"
+)
+
+func newTestSGB() (*SGB, *joypad.Joypad) {
+	mem := memory.New()
+	j := joypad.New(mem)
+	return New(ppu.NewPPU(mem), j), j
+}
+
+// sendPacket writes a packet to P1 like a game does: the reset pulse, the
+// 128 bits least significant first and the stop bit
+func sendPacket(j *joypad.Joypad, packet [packetSize]byte, stop byte) {
+	pulse := func(selection byte) {
+		j.WriteIO(joypad.P1Address, selection)
+		j.WriteIO(joypad.P1Address, pulseIdle)
+	}
+
+	pulse(pulseReset)
+	for bit := range packetBits {
+		if packet[bit/8]&(1<<(bit%8)) != 0 {
+			pulse(pulseOne)
+		} else {
+			pulse(pulseZero)
+		}
+	}
+	pulse(stop)
+}
+
+func command(code byte, packets int, data ...byte) [][packetSize]byte {
+	result := make([][packetSize]byte, packets)
+	result[0][0] = code<<3 | byte(packets)
+	for i, value := range data {
+		result[(i+1)/packetSize][(i+1)%packetSize] = value
+	}
+	return result
+}
+
+func TestPAL01(t *testing.T) {
+	s, j := newTestSGB()
+
+	for _, packet := range command(commandPal01, 1,
+		0x11, 0x11, // colour 0
+		0x01, 0x00, 0x02, 0x00, 0x03, 0x00, // palette 0
+		0x04, 0x00, 0x05, 0x00, 0x06, 0x00, // palette 1
+	) {
+		sendPacket(j, packet, pulseZero)
+	}
+
+	want := [palettes][4]uint16{
+		{0x1111, 0x0001, 0x0002, 0x0003},
+		{0x1111, 0x0004, 0x0005, 0x0006},
+		{0x1111, defaultPalette[1], defaultPalette[2], defaultPalette[3]},
+		{0x1111, defaultPalette[1], defaultPalette[2], defaultPalette[3]},
+	}
+	for palette := range palettes {
+		if got := s.Palette(palette); got != want[palette] {
+			t.Errorf("palette %d %04X, want %04X", palette, got, want[palette])
+		}
+	}
+}
+
+func TestPacketNeedsTheStopBit(t *testing.T) {
+	s, j := newTestSGB()
+
+	for _, packet := range command(commandMaskEn, 1, byte(MaskBlack)) {
+		sendPacket(j, packet, pulseOne)
+	}
+	if got := s.Mask(); got != MaskCancel {
+		t.Errorf("mask %d after a packet ending in 1, want %d", got, MaskCancel)
+	}
+
+	for _, packet := range command(commandMaskEn, 1, byte(MaskBlack)) {
+		sendPacket(j, packet, pulseZero)
+	}
+	if got := s.Mask(); got != MaskBlack {
+		t.Errorf("mask %d, want %d", got, MaskBlack)
+	}
+}
+
+func TestBitsNeedTheIdlePulse(t *testing.T) {
+	s, _ := newTestSGB()
+
+	s.WriteP1(pulseReset)
+	s.WriteP1(pulseIdle)
+	s.WriteP1(pulseOne)
+	s.WriteP1(pulseOne) // no idle pulse in between, not a bit
+	s.WriteP1(pulseIdle)
+	s.WriteP1(pulseZero)
+
+	if s.bits != 2 || s.packet[0] != 0x01 {
+		t.Errorf("received %d bits %08b, want 2 bits 00000001", s.bits, s.packet[0])
+	}
+}
+
+func TestMLTREQ(t *testing.T) {
+	tests := []struct {
+		parameter byte
+		players   int
+	}{
+		{0x00, 1},
+		{0x01, 2},
+		{0x03, 4},
+	}
+
+	for _, test := range tests {
+		_, j := newTestSGB()
+
+		for _, packet := range command(commandMltReq, 1, test.parameter) {
+			sendPacket(j, packet, pulseZero)
+		}
+		if got := j.Players(); got != test.players {
+			t.Errorf("MLT_REQ %02X: %d players, want %d", test.parameter, got, test.players)
+		}
+	}
+}
+
+func TestMultiPacketCommand(t *testing.T) {
+	s, j := newTestSGB()
+
+	// ATTR_LIN with 16 lines takes two packets: columns 0-15 with palette 2
+	lines := []byte{16}
+	for column := range byte(16) {
+		lines = append(lines, 2<<5|column)
+	}
+	packets := command(commandAttrLin, 2, lines...)
+
+	sendPacket(j, packets[0], pulseZero)
+	if got := s.Attribute(0, 0); got != 0 {
+		t.Fatalf("attribute %d before the second packet, want 0", got)
+	}
+	sendPacket(j, packets[1], pulseZero)
+
+	for column := range columns {
+		want := byte(2)
+		if column >= 16 {
+			want = 0
+		}
+		if got := s.Attribute(column, rows-1); got != want {
+			t.Errorf("column %d attribute %d, want %d", column, got, want)
+		}
+	}
+}
+
+func TestATTRBLK(t *testing.T) {
+	s, j := newTestSGB()
+
+	// one block from (2,3) to (5,6): inside 1, border 2, outside 3
+	for _, packet := range command(commandAttrBlk, 1, 1, 0x07, 3<<4|2<<2|1, 2, 3, 5, 6) {
+		sendPacket(j, packet, pulseZero)
+	}
+
+	tests := []struct {
+		column, row int
+		want        byte
+	}{
+		{3, 4, 1},
+		{4, 5, 1},
+		{2, 3, 2},
+		{5, 4, 2},
+		{3, 6, 2},
+		{0, 0, 3},
+		{6, 4, 3},
+		{columns - 1, rows - 1, 3},
+	}
+	for _, test := range tests {
+		if got := s.Attribute(test.column, test.row); got != test.want {
+			t.Errorf("tile (%d,%d) attribute %d, want %d", test.column, test.row, got, test.want)
+		}
+	}
+}
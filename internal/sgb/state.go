@@ -0,0 +1,76 @@
+package sgb
+
+import "gb-emulator/internal/savestate"
+
+// SaveState writes the packet receiver, the palettes, the attribute maps,
+// the pending transfer and the border
+func (s *SGB) SaveState(e *savestate.Encoder) {
+	e.Bool(s.receiving)
+	e.Uint8(s.previous)
+	e.Int(s.bits)
+	e.Bytes(s.packet[:])
+	e.Slice(s.command)
+	e.Int(s.packets)
+
+	for i := range s.palettes {
+		e.Uint16s(s.palettes[i][:])
+	}
+	for i := range s.systemPalettes {
+		e.Uint16s(s.systemPalettes[i][:])
+	}
+	for row := range s.attributes {
+		e.Bytes(s.attributes[row][:])
+	}
+	for file := range s.attributeFiles {
+		for row := range s.attributeFiles[file] {
+			e.Bytes(s.attributeFiles[file][row][:])
+		}
+	}
+	e.Uint8(byte(s.mask))
+
+	e.Int(int(s.transfer))
+	e.Int(s.transferCountdown)
+	e.Bytes(s.screen)
+
+	e.Bytes(s.border.tiles[:])
+	e.Uint16s(s.border.tileMap[:])
+	for i := range s.border.palettes {
+		e.Uint16s(s.border.palettes[i][:])
+	}
+}
+
+// LoadState restores the state written by SaveState
+func (s *SGB) LoadState(d *savestate.Decoder) {
+	s.receiving = d.Bool()
+	s.previous = d.Uint8()
+	s.bits = d.IntRange(0, packetBits)
+	d.Bytes(s.packet[:])
+	s.command = d.Slice()
+	s.packets = d.IntRange(0, 7) // up to 7, 0 between commands
+
+	for i := range s.palettes {
+		d.Uint16s(s.palettes[i][:])
+	}
+	for i := range s.systemPalettes {
+		d.Uint16s(s.systemPalettes[i][:])
+	}
+	for row := range s.attributes {
+		d.Bytes(s.attributes[row][:])
+	}
+	for file := range s.attributeFiles {
+		for row := range s.attributeFiles[file] {
+			d.Bytes(s.attributeFiles[file][row][:])
+		}
+	}
+	s.mask = Mask(d.Uint8())
+
+	s.transfer = transfer(d.Int())
+	s.transferCountdown = d.Int()
+	d.Bytes(s.screen)
+
+	d.Bytes(s.border.tiles[:])
+	d.Uint16s(s.border.tileMap[:])
+	for i := range s.border.palettes {
+		d.Uint16s(s.border.palettes[i][:])
+	}
+}
@@ -0,0 +1,89 @@
+package sgb
+
+import "gb-emulator/internal/ppu"
+
+// Documentation
+// * https://gbdev.io/pandocs/SGB_VRAM_Transfer.html
+
+const (
+	transferSize      = 4096
+	transferTiles     = transferSize / 16
+	transferDelay     = 3 // frames between the command and the frame read
+	attributeFileSize = rows * columns / 4
+)
+
+// transfer is the kind of data expected from a VRAM transfer
+type transfer int
+
+const (
+	transferNone transfer = iota
+	transferPalettes
+	transferAttributes
+	transferBorderTiles     // tiles 0x00-0x7F
+	transferBorderTilesHigh // tiles 0x80-0xFF
+	transferBorderMap
+)
+
+// startTransfer waits for the data of a VRAM transfer. The game shows it as
+// BG tiles and the SGB reads the screen a few frames later
+func (s *SGB) startTransfer(kind transfer) {
+	s.transfer = kind
+	s.transferCountdown = transferDelay
+}
+
+// runTransfer reads the data of the pending transfer once its frame arrives
+func (s *SGB) runTransfer(shades []byte) {
+	if s.transfer == transferNone {
+		return
+	}
+	if s.transferCountdown--; s.transferCountdown > 0 {
+		return
+	}
+
+	data := decodeScreen(shades)
+
+	switch s.transfer {
+	case transferPalettes:
+		for i := range s.systemPalettes {
+			for colour := range 4 {
+				s.systemPalettes[i][colour] = word(data, (i*4+colour)*2)
+			}
+		}
+	case transferAttributes:
+		for file := range s.attributeFiles {
+			for i := range rows * columns {
+				value := data[file*attributeFileSize+i/4] >> (6 - i%4*2) & 0x03
+				s.attributeFiles[file][i/columns][i%columns] = value
+			}
+		}
+	case transferBorderTiles, transferBorderTilesHigh:
+		s.border.loadTiles(int(s.transfer-transferBorderTiles), data)
+	case transferBorderMap:
+		s.border.loadMap(data)
+	}
+
+	s.transfer = transferNone
+}
+
+// decodeScreen turns the screen back into the 4 KiB of tile data the game
+// displays: 256 tiles in rows of 20, whose shades are the 2bpp colour indexes
+func decodeScreen(shades []byte) []byte {
+	data := make([]byte, transferSize)
+
+	for tile := range transferTiles {
+		tileX, tileY := tile%columns*8, tile/columns*8
+
+		for row := range 8 {
+			var low, high byte
+			line := shades[(tileY+row)*ppu.ScreenWidth+tileX:]
+			for bit := range 8 {
+				low |= (line[bit] & 0x01) << (7 - bit)
+				high |= (line[bit] >> 1 & 0x01) << (7 - bit)
+			}
+			data[tile*16+row*2] = low
+			data[tile*16+row*2+1] = high
+		}
+	}
+
+	return data
+}
@@ -0,0 +1,19 @@
+package timer
+
+import "gb-emulator/internal/savestate"
+
+// SaveState writes the divider, the registers and the TIMA reload
+func (t *Timer) SaveState(e *savestate.Encoder) {
+	e.Uint16(t.divider)
+	e.Bytes([]byte{t.tima, t.tma, t.tac})
+	e.Int(int(t.reload))
+}
+
+// LoadState restores the state written by SaveState
+func (t *Timer) LoadState(d *savestate.Decoder) {
+	t.divider = d.Uint16()
+	t.tima = d.Uint8()
+	t.tma = d.Uint8()
+	t.tac = d.Uint8()
+	t.reload = reloadState(d.Int())
+}
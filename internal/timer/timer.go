@@ -0,0 +1,165 @@
+// Package timer implements the Game Boy divider and timer
+package timer
+
+import "gb-emulator/internal/memory"
+
+// Documentation
+// * https://gbdev.io/pandocs/Timer_and_Divider_Registers.html
+// * https://gbdev.io/pandocs/Timer_Obscure_Behaviour.html
+// * https://github.com/Gekkio/mooneye-test-suite/tree/main/acceptance/timer
+
+const (
+	DIVAddress  = 0xFF04
+	TIMAAddress = 0xFF05
+	TMAAddress  = 0xFF06
+	TACAddress  = 0xFF07
+
+	tacEnable    byte = 1 << 2
+	tacClockMask byte = 0x03
+	tacReadMask  byte = 0xF8
+
+	// apuBit is the divider bit whose falling edge clocks the APU frame sequencer
+	apuBit = 1 << 12
+)
+
+// tacBits maps TAC bits 0-1 to the divider bit whose falling edge increments TIMA
+var tacBits = [4]uint16{1 << 9, 1 << 3, 1 << 5, 1 << 7} // 4096, 262144, 65536, 16384 Hz
+
+// reloadState follows TIMA after an overflow
+type reloadState int
+
+const (
+	reloadIdle      reloadState = iota
+	reloadPending               // TIMA overflowed and reads 0, a TIMA write cancels the reload
+	reloadInProcess             // TIMA was just loaded from TMA, TIMA writes are ignored
+)
+
+// Timer holds the 16-bit internal divider and the TIMA/TMA/TAC registers
+type Timer struct {
+	mem *memory.Memory
+
+	divider uint16 // DIV is its upper byte
+	tima    byte
+	tma     byte
+	tac     byte
+	reload  reloadState
+
+	// APUClock is called on the falling edge of divider bit 12 (bit 13 in
+	// double speed), which drives the 512 Hz APU frame sequencer
+	APUClock func()
+}
+
+// New creates the timer and maps its registers
+func New(mem *memory.Memory) *Timer {
+	t := &Timer{mem: mem}
+	mem.MapIO(DIVAddress, TACAddress, t)
+	return t
+}
+
+// Divider returns the internal 16-bit divider
+func (t *Timer) Divider() uint16 {
+	return t.divider
+}
+
+// SetDivider sets the internal divider, the boot ROM leaves it at a model
+// dependent value
+func (t *Timer) SetDivider(value uint16) {
+	t.divider = value
+}
+
+// Step advances the timer by the given number of M-cycles. The divider counts
+// CPU cycles, so it runs twice as fast in CGB double speed
+func (t *Timer) Step(cycles int) {
+	for range cycles {
+		t.tick()
+	}
+}
+
+// tick advances one M-cycle
+func (t *Timer) tick() {
+	switch t.reload {
+	case reloadPending:
+		t.tima = t.tma
+		t.mem.RequestInterrupt(memory.InterruptTimer)
+		t.reload = reloadInProcess
+	case reloadInProcess:
+		t.reload = reloadIdle
+	}
+
+	t.setDivider(t.divider + 4)
+}
+
+// setDivider changes the divider and applies the falling edges it causes
+func (t *Timer) setDivider(value uint16) {
+	previous := t.divider
+	t.divider = value
+
+	if t.timerSignal(previous, t.tac) && !t.timerSignal(value, t.tac) {
+		t.increment()
+	}
+
+	bit := uint16(apuBit)
+	if t.mem.DoubleSpeed {
+		bit <<= 1
+	}
+	if previous&bit != 0 && value&bit == 0 && t.APUClock != nil {
+		t.APUClock()
+	}
+}
+
+// timerSignal is the input of the TIMA falling edge detector: the selected
+// divider bit ANDed with the enable bit
+func (t *Timer) timerSignal(divider uint16, tac byte) bool {
+	return tac&tacEnable != 0 && divider&tacBits[tac&tacClockMask] != 0
+}
+
+func (t *Timer) increment() {
+	t.tima++
+	if t.tima == 0 {
+		// TIMA reads 0 for one M-cycle before the reload
+		t.reload = reloadPending
+	}
+}
+
+// ReadIO implements memory.IODevice
+func (t *Timer) ReadIO(address uint16) byte {
+	switch address {
+	case DIVAddress:
+		return byte(t.divider >> 8)
+	case TIMAAddress:
+		return t.tima
+	case TMAAddress:
+		return t.tma
+	default:
+		return t.tac | tacReadMask
+	}
+}
+
+// WriteIO implements memory.IODevice
+func (t *Timer) WriteIO(address uint16, value byte) {
+	switch address {
+	case DIVAddress:
+		// resetting the divider is a falling edge when the selected bit was set
+		t.setDivider(0)
+	case TIMAAddress:
+		switch t.reload {
+		case reloadPending:
+			t.tima = value
+			t.reload = reloadIdle
+		case reloadIdle:
+			t.tima = value
+		}
+	case TMAAddress:
+		t.tma = value
+		if t.reload == reloadInProcess {
+			t.tima = value
+		}
+	case TACAddress:
+		// disabling the timer or selecting another bit can be a falling edge
+		previous := t.tac
+		t.tac = value & (tacEnable | tacClockMask)
+		if t.timerSignal(t.divider, previous) && !t.timerSignal(t.divider, t.tac) {
+			t.increment()
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package timer
+
+import (
+	"testing"
+
+	"gb-emulator/internal/memory"
+)
+
+func newTimer() (*Timer, *memory.Memory) {
+	mem := memory.New()
+	return New(mem), mem
+}
+
+func timerRequested(mem *memory.Memory) bool {
+	return mem.Read(memory.IFAddress)&memory.InterruptTimer != 0
+}
+
+func TestTIMAIncrementsOnFallingEdge(t *testing.T) {
+	tests := []struct {
+		tac    byte
+		period int // M-cycles between increments
+	}{
+		{0x04, 256}, // 4096 Hz, divider bit 9
+		{0x05, 4},   // 262144 Hz, divider bit 3
+		{0x06, 16},  // 65536 Hz, divider bit 5
+		{0x07, 64},  // 16384 Hz, divider bit 7
+	}
+
+	for _, test := range tests {
+		timer, _ := newTimer()
+		timer.WriteIO(TACAddress, test.tac)
+
+		timer.Step(test.period - 1)
+		if tima := timer.ReadIO(TIMAAddress); tima != 0 {
+			t.Errorf("TAC %02X: TIMA %d one cycle before the edge, want 0", test.tac, tima)
+		}
+		timer.Step(1)
+		if tima := timer.ReadIO(TIMAAddress); tima != 1 {
+			t.Errorf("TAC %02X: TIMA %d on the edge, want 1", test.tac, tima)
+		}
+		timer.Step(test.period)
+		if tima := timer.ReadIO(TIMAAddress); tima != 2 {
+			t.Errorf("TAC %02X: TIMA %d after two periods, want 2", test.tac, tima)
+		}
+	}
+}
+
+func TestTIMAStopsWhenDisabled(t *testing.T) {
+	timer, _ := newTimer()
+	timer.WriteIO(TACAddress, 0x01)
+
+	timer.Step(1024)
+	if tima := timer.ReadIO(TIMAAddress); tima != 0 {
+		t.Errorf("TIMA %d with the timer disabled, want 0", tima)
+	}
+}
+
+func TestTIMAReload(t *testing.T) {
+	const tma = 0x23
+
+	tests := []struct {
+		name          string
+		delay         int // M-cycles after the overflow before the write
+		address       uint16
+		value         byte
+		wantTIMA      byte
+		wantInterrupt bool
+	}{
+		{"no write", 0, 0, 0, tma, true},
+		{"TIMA write cancels the reload", 0, TIMAAddress, 0x42, 0x42, false},
+		{"TIMA write during the reload is ignored", 1, TIMAAddress, 0x42, tma, true},
+		{"TMA write during the reload reaches TIMA", 1, TMAAddress, 0x42, 0x42, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timer, mem := newTimer()
+			timer.WriteIO(TMAAddress, tma)
+			timer.WriteIO(TIMAAddress, 0xFF)
+			timer.WriteIO(TACAddress, 0x05)
+
+			timer.Step(4)
+			if tima := timer.ReadIO(TIMAAddress); tima != 0 {
+				t.Fatalf("TIMA %02X right after the overflow, want 00", tima)
+			}
+			if timerRequested(mem) {
+				t.Fatal("interrupt requested before the reload")
+			}
+
+			timer.Step(test.delay)
+			if test.address != 0 {
+				timer.WriteIO(test.address, test.value)
+			}
+			timer.Step(2 - test.delay)
+
+			if tima := timer.ReadIO(TIMAAddress); tima != test.wantTIMA {
+				t.Errorf("TIMA %02X, want %02X", tima, test.wantTIMA)
+			}
+			if requested := timerRequested(mem); requested != test.wantInterrupt {
+				t.Errorf("interrupt requested %v, want %v", requested, test.wantInterrupt)
+			}
+		})
+	}
+}
+
+func TestWriteGlitches(t *testing.T) {
+	tests := []struct {
+		name     string
+		divider  uint16
+		tac      byte
+		address  uint16
+		value    byte
+		wantTIMA byte
+	}{
+		{"DIV reset with the selected bit set", 0x0008, 0x05, DIVAddress, 0, 1},
+		{"DIV reset with the selected bit clear", 0x0004, 0x05, DIVAddress, 0, 0},
+		{"DIV reset with the timer disabled", 0x0008, 0x01, DIVAddress, 0, 0},
+		{"TAC disable with the selected bit set", 0x0008, 0x05, TACAddress, 0x01, 1},
+		{"TAC disable with the selected bit clear", 0x0004, 0x05, TACAddress, 0x01, 0},
+		{"TAC selects a clear bit", 0x0008, 0x05, TACAddress, 0x06, 1},
+		{"TAC selects another set bit", 0x0028, 0x05, TACAddress, 0x06, 0},
+		{"TAC enable with the selected bit set", 0x0008, 0x01, TACAddress, 0x05, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timer, _ := newTimer()
+			timer.SetDivider(test.divider)
+			timer.WriteIO(TACAddress, test.tac)
+
+			timer.WriteIO(test.address, test.value)
+
+			if tima := timer.ReadIO(TIMAAddress); tima != test.wantTIMA {
+				t.Errorf("TIMA %d, want %d", tima, test.wantTIMA)
+			}
+		})
+	}
+}
+
+func TestDIVResetClocksTheAPU(t *testing.T) {
+	tests := []struct {
+		divider     uint16
+		doubleSpeed bool
+		wantClocks  int
+	}{
+		{0x1000, false, 1},
+		{0x0FFF, false, 0},
+		{0x1000, true, 0},
+		{0x2000, true, 1},
+	}
+
+	for _, test := range tests {
+		timer, mem := newTimer()
+		mem.DoubleSpeed = test.doubleSpeed
+		clocks := 0
+		timer.APUClock = func() { clocks++ }
+		timer.SetDivider(test.divider)
+
+		timer.WriteIO(DIVAddress, 0)
+
+		if clocks != test.wantClocks {
+			t.Errorf("divider %04X, double speed %v: %d APU clocks, want %d", test.divider, test.doubleSpeed, clocks, test.wantClocks)
+		}
+	}
+}